@@ -1,41 +1,88 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"flag"
+	"github.com/caricciy/go-weather/internal/config"
 	"github.com/caricciy/go-weather/internal/infra"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/redact"
 	"github.com/joho/godotenv"
 	"log"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"time"
 )
 
-func init() {
+func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Error loading .env file, using environment variables directly")
 	}
 
-	// Configure logger
+	// Fail fast, reporting every problem of the configuration at once
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Configure logger, its level is changed by the reloads of the configuration.
+	// The secrets that could slip into the records, such as API keys in URLs, are scrubbed.
+	var level slog.LevelVar
+	setLogLevel(&level, cfg)
 	logOpts := &slog.HandlerOptions{
-		Level: slog.LevelDebug,
+		Level:       &level,
+		ReplaceAttr: redact.ReplaceAttr,
 	}
 
 	l := slog.New(slog.NewJSONHandler(os.Stdout, logOpts))
 	l = l.With(slog.String("app_name", "go-weather"))
 	slog.SetDefault(l)
-}
 
-func main() {
+	shutdownTracing, err := infra.SetupTracing(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("Could not set up tracing: %v\n", err)
+	}
+
+	reloader := config.NewReloader(cfg, os.Args[1:])
+	reloader.OnReload(func(cfg *config.Config) {
+		setLogLevel(&level, cfg)
+	})
+
 	router := infra.NewAppRouter()
 
+	// The use cases are shared by the REST and gRPC APIs
+	weatherApiKeys := infra.NewWeatherApiKeyPool(cfg, reloader)
+	weatherUseCases := infra.NewWeatherUseCases(cfg, weatherApiKeys)
+
 	// Initialize handlers
-	weatherHandler := infra.NewWeatherHandler()
+	weatherHandler := infra.NewWeatherHandler(cfg, weatherUseCases, reloader)
 
 	// Define routes
-	router.Get("/weather/{cep}", weatherHandler.HandleGetWeatherByCEP)
+	infra.RegisterWeatherRoutes(router, weatherHandler)
+	liveHub := infra.NewLiveHub(cfg, weatherUseCases)
+	infra.RegisterStreamRoutes(router, infra.NewStreamHandler(cfg, liveHub))
+	infra.RegisterSocketRoutes(router, infra.NewSocketHandler(cfg, liveHub, reloader))
+
+	webhookUseCases, err := infra.NewWebhookUseCases(cfg, weatherUseCases)
+	if err != nil {
+		log.Fatalf("Could not load the webhooks: %v\n", err)
+	}
+	infra.RegisterWebhookRoutes(router, infra.NewWebhookHandler(cfg, webhookUseCases, reloader))
+	stopWebhookEvaluator := infra.StartWebhookEvaluator(cfg, webhookUseCases)
+
+	infra.RegisterGraphQLRoutes(router, infra.NewGraphQLHandler(cfg, weatherUseCases))
+
+	readiness := infra.NewReadinessChecker(cfg, weatherApiKeys)
+	infra.RegisterReadinessRoutes(router, readiness)
 
-	server := infra.NewHttpServer(router)
+	server := infra.NewHttpServer(cfg, router)
 
 	// Start the server in a goroutine so it doesn't block
 	go func() {
@@ -45,5 +92,44 @@ func main() {
 		}
 	}()
 
-	infra.WaitForShutdown(server)
+	metricsServer := infra.NewMetricsServer(cfg)
+
+	go func() {
+		log.Printf("Starting metrics server on %s", metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Could not listen on %s: %v\n", metricsServer.Addr, err)
+		}
+	}()
+
+	grpcServer := infra.NewGrpcServer(cfg, weatherUseCases)
+
+	go func() {
+		lis, err := net.Listen("tcp", grpcServer.Addr)
+		if err != nil {
+			log.Fatalf("Could not listen on %s: %v\n", grpcServer.Addr, err)
+		}
+		log.Printf("Starting gRPC server on port %s", grpcServer.Addr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("Could not serve gRPC on %s: %v\n", grpcServer.Addr, err)
+		}
+	}()
+
+	// The reloads start once every component has registered the settings it applies
+	stopConfigReloader := infra.StartConfigReloader(cfg, reloader)
+
+	// The hub ends the streams so the server doesn't wait for them to shut down
+	infra.WaitForShutdown(cfg, server, readiness, metricsServer.Shutdown, grpcServer.Shutdown, liveHub.Shutdown, stopWebhookEvaluator, stopConfigReloader)
+
+	// The spans are flushed once the servers are stopped, so the last requests are exported too
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Could not flush the spans: %v", err)
+	}
+}
+
+// setLogLevel sets the level of the logs from the configuration, which is validated
+func setLogLevel(level *slog.LevelVar, cfg *config.Config) {
+	parsed, _ := logging.ParseLevel(cfg.Log.Level)
+	level.Set(parsed)
 }
@@ -0,0 +1,561 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/data"
+	"github.com/caricciy/go-weather/internal/handler"
+	"github.com/caricciy/go-weather/internal/health"
+	"github.com/caricciy/go-weather/internal/live"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/units"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the application.
+// It is loaded from, by increasing precedence, the defaults, the YAML file, the environment and the command line flags.
+type Config struct {
+	Server     Server    `yaml:"server"`
+	Log        Log       `yaml:"log"`
+	Tracing    Tracing   `yaml:"tracing"`
+	Providers  Providers `yaml:"providers"`
+	Units      Units     `yaml:"units"`
+	Cache      Cache     `yaml:"cache"`
+	Stream     Stream    `yaml:"stream"`
+	Socket     Socket    `yaml:"socket"`
+	GraphQL    GraphQL   `yaml:"graphql"`
+	Webhook    Webhook   `yaml:"webhook"`
+	Health     Health    `yaml:"health"`
+	Reload     Reload    `yaml:"reload"`
+	AdminToken string    `yaml:"admin_token"`
+
+	// File is the YAML file the configuration was loaded from, empty when there is none
+	File string `yaml:"-"`
+	// SecretFiles are the files the secrets were read from
+	SecretFiles []string `yaml:"-"`
+}
+
+// Server holds the listeners of the application and their timeouts
+type Server struct {
+	Port            string        `yaml:"port"`
+	GrpcPort        string        `yaml:"grpc_port"`
+	GrpcReflection  bool          `yaml:"grpc_reflection"`
+	MetricsAddr     string        `yaml:"metrics_addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+type Tracing struct {
+	Exporter string `yaml:"exporter"`
+	// CEPHashKey keys the hashes of the postal codes of the spans, the instances sharing it have comparable hashes
+	CEPHashKey string `yaml:"cep_hash_key"`
+}
+
+// Providers holds the base URLs of the external providers and their credentials
+type Providers struct {
+	ViaCEPEndpoint     string `yaml:"viacep_endpoint"`
+	ZippopotamEndpoint string `yaml:"zippopotam_endpoint"`
+	WeatherApiEndpoint string `yaml:"weatherapi_endpoint"`
+	WeatherApiKey      string `yaml:"weatherapi_key"`
+	// WeatherApiKeys are more WeatherAPI keys, the calls are spread over every key
+	WeatherApiKeys        []string      `yaml:"weatherapi_keys"`
+	WeatherApiKeyStrategy string        `yaml:"weatherapi_key_strategy"`
+	WeatherApiKeyCooldown time.Duration `yaml:"weatherapi_key_cooldown"`
+}
+
+// WeatherApiKeyPool returns every WeatherAPI key, the single key first
+func (p Providers) WeatherApiKeyPool() []string {
+	if p.WeatherApiKey == "" {
+		return p.WeatherApiKeys
+	}
+	return append([]string{p.WeatherApiKey}, p.WeatherApiKeys...)
+}
+
+type Units struct {
+	Precision int `yaml:"precision"`
+}
+
+type Cache struct {
+	CEPMaxAge       time.Duration `yaml:"cep_max_age"`
+	WeatherInterval time.Duration `yaml:"weather_interval"`
+}
+
+type Stream struct {
+	RefreshInterval   time.Duration `yaml:"refresh_interval"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+}
+
+type Socket struct {
+	PingInterval     time.Duration `yaml:"ping_interval"`
+	MaxSubscriptions int           `yaml:"max_subscriptions"`
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+}
+
+type GraphQL struct {
+	Introspection bool `yaml:"introspection"`
+}
+
+type Webhook struct {
+	StorePath    string        `yaml:"store_path"`
+	EvalInterval time.Duration `yaml:"eval_interval"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	Backoff      time.Duration `yaml:"backoff"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+type Health struct {
+	ProbeInterval time.Duration `yaml:"probe_interval"`
+	ProbeTimeout  time.Duration `yaml:"probe_timeout"`
+	DrainDelay    time.Duration `yaml:"drain_delay"`
+}
+
+// Reload holds how the changes of the configuration files are watched
+type Reload struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Default returns the default configuration, which lacks the WeatherAPI key
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:            "8080",
+			GrpcPort:        "50051",
+			MetricsAddr:     "localhost:9464",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Log:     Log{Level: "info"},
+		Tracing: Tracing{Exporter: tracing.ExporterNone},
+		Providers: Providers{
+			ViaCEPEndpoint:        data.DefaultViaCEPEndpoint,
+			ZippopotamEndpoint:    data.DefaultZippopotamEndpoint,
+			WeatherApiEndpoint:    data.DefaultWeatherApiEndpoint,
+			WeatherApiKeyStrategy: data.KeyStrategyRoundRobin,
+			WeatherApiKeyCooldown: data.DefaultKeyCooldown,
+		},
+		Units: Units{Precision: units.DefaultPrecision},
+		Cache: Cache{CEPMaxAge: handler.DefaultCEPMaxAge, WeatherInterval: handler.DefaultWeatherInterval},
+		Stream: Stream{
+			RefreshInterval:   live.DefaultInterval,
+			HeartbeatInterval: handler.DefaultHeartbeat,
+		},
+		Socket: Socket{PingInterval: handler.DefaultPingInterval, MaxSubscriptions: handler.DefaultMaxSubscriptions},
+		Webhook: Webhook{
+			StorePath:    "webhooks.json",
+			EvalInterval: 5 * time.Minute,
+			MaxAttempts:  usecase.DefaultWebhookMaxAttempts,
+			Backoff:      usecase.DefaultWebhookBackoff,
+			Timeout:      10 * time.Second,
+		},
+		Health: Health{
+			ProbeInterval: health.DefaultProbeInterval,
+			ProbeTimeout:  health.DefaultProbeTimeout,
+			DrainDelay:    5 * time.Second,
+		},
+		Reload: Reload{Interval: 10 * time.Second},
+	}
+}
+
+// Error reports every problem of an invalid configuration
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load loads the configuration from the YAML file of the -config flag or CONFIG_FILE, the environment and args,
+// the command line flags. Secrets may be read from the file named by their variable suffixed with _FILE,
+// e.g. WEATHER_API_KEY_FILE. Every problem of the configuration is reported at once in an *Error.
+// It returns flag.ErrHelp when the usage is requested with -h.
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	// The flags are parsed first to find the configuration file, their values are applied last
+	flags := flag.NewFlagSet("go-weather", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file (CONFIG_FILE)")
+	flagValues := map[*field]string{}
+	for i := range fields {
+		f := &fields[i]
+		name, usage := f.flagName(), f.usage+" ("+f.env+")"
+		if f.secret {
+			name, usage = name+"-file", "file holding the "+f.usage+" ("+f.env+"_FILE)"
+		}
+		flags.Func(name, usage, func(value string) error {
+			flagValues[f] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	cfg.File = *file
+	if cfg.File != "" {
+		if err := cfg.loadFile(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	for i := range fields {
+		f := &fields[i]
+		if err := f.loadEnv(); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if value, ok := flagValues[f]; ok {
+			if err := f.loadFlag(value); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		if f.secretFile != "" {
+			cfg.SecretFiles = append(cfg.SecretFiles, f.secretFile)
+		}
+	}
+
+	for _, f := range fields {
+		if f.validate == nil {
+			continue
+		}
+		if err := f.validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s): %v", f.env, f.key, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// loadFile overrides the configuration with the settings of its YAML file, rejecting unknown settings
+func (c *Config) loadFile() error {
+	content, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", c.File, err)
+	}
+	return nil
+}
+
+// field is a setting of the configuration, set by the environment variable env and the flag derived from its name
+type field struct {
+	env string
+	// key is the path of the setting in the YAML file
+	key   string
+	usage string
+	// secret settings may be read from a file, and are only accepted as a file on the command line
+	secret bool
+	// secretFile is the file the secret was read from
+	secretFile string
+	// reloadable settings are applied by a reload of the configuration, the others require a restart
+	reloadable bool
+	// ptr points to the setting in the configuration
+	ptr      any
+	parse    func(value string) error
+	validate func() error
+}
+
+// flagName derives the flag of a setting from its variable, e.g. WS_PING_INTERVAL is -ws-ping-interval
+func (f *field) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(f.env, "_", "-"))
+}
+
+// loadEnv sets the field from its environment variable, or from the file named by its _FILE variable for a secret
+func (f *field) loadEnv() error {
+	value, ok := os.LookupEnv(f.env)
+	if path, fromFile := os.LookupEnv(f.env + "_FILE"); f.secret && fromFile {
+		if ok {
+			return fmt.Errorf("%s: set either %s or %s_FILE", f.env, f.env, f.env)
+		}
+		secret, err := readSecret(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", f.env, err)
+		}
+		value, ok, f.secretFile = secret, true, path
+	}
+	if !ok || value == "" {
+		return nil
+	}
+
+	if err := f.parse(value); err != nil {
+		return fmt.Errorf("%s: %w", f.env, err)
+	}
+	return nil
+}
+
+// loadFlag sets the field from its flag, which names the file holding the value of a secret
+func (f *field) loadFlag(value string) error {
+	if f.secret {
+		secret, err := readSecret(value)
+		if err != nil {
+			return fmt.Errorf("-%s-file: %w", f.flagName(), err)
+		}
+		value, f.secretFile = secret, value
+	}
+
+	if err := f.parse(value); err != nil {
+		return fmt.Errorf("-%s: %w", f.flagName(), err)
+	}
+	return nil
+}
+
+// readSecret reads a secret from a file, without the trailing new line of the file
+func readSecret(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// fields lists the settings of the configuration
+func (c *Config) fields() []field {
+	return []field{
+		stringField("PORT", "server.port", "port of the HTTP API", &c.Server.Port, validPort),
+		stringField("GRPC_PORT", "server.grpc_port", "port of the gRPC API", &c.Server.GrpcPort, validPort),
+		boolField("GRPC_REFLECTION", "server.grpc_reflection", "enable the gRPC server reflection", &c.Server.GrpcReflection),
+		stringField("METRICS_ADDR", "server.metrics_addr", "address of the Prometheus metrics server", &c.Server.MetricsAddr, validAddr),
+		durationField("SERVER_READ_TIMEOUT", "server.read_timeout", "timeout to read a request", &c.Server.ReadTimeout),
+		durationField("SERVER_WRITE_TIMEOUT", "server.write_timeout", "timeout to write a response", &c.Server.WriteTimeout),
+		durationField("SERVER_IDLE_TIMEOUT", "server.idle_timeout", "timeout of the idle keep-alive connections", &c.Server.IdleTimeout),
+		durationField("SERVER_SHUTDOWN_TIMEOUT", "server.shutdown_timeout", "timeout of the graceful shutdown", &c.Server.ShutdownTimeout),
+		reloadable(stringField("LOG_LEVEL", "log.level", "log level: debug, info, warn or error", &c.Log.Level, validLogLevel)),
+		stringField("TRACING_EXPORTER", "tracing.exporter", "trace exporter: none, stdout or otlp", &c.Tracing.Exporter, validExporter),
+		secretField("TRACING_CEP_HASH_KEY", "tracing.cep_hash_key", "key of the hashes of the postal codes of the spans", &c.Tracing.CEPHashKey, func() error {
+			if c.Tracing.CEPHashKey != "" && len(c.Tracing.CEPHashKey) < 16 {
+				return errors.New("must have at least 16 characters")
+			}
+			return nil
+		}),
+		stringField("VIACEP_ENDPOINT", "providers.viacep_endpoint", "base URL of ViaCEP", &c.Providers.ViaCEPEndpoint, validURL),
+		stringField("ZIPPOPOTAM_ENDPOINT", "providers.zippopotam_endpoint", "base URL of Zippopotam.us", &c.Providers.ZippopotamEndpoint, validURL),
+		stringField("WEATHER_API_ENDPOINT", "providers.weatherapi_endpoint", "base URL of WeatherAPI", &c.Providers.WeatherApiEndpoint, validURL),
+		reloadable(secretField("WEATHER_API_KEY", "providers.weatherapi_key", "WeatherAPI key", &c.Providers.WeatherApiKey, func() error {
+			if len(c.Providers.WeatherApiKeyPool()) == 0 {
+				return errors.New("is required, set it, WEATHER_API_KEY_FILE or WEATHER_API_KEYS")
+			}
+			return nil
+		})),
+		reloadable(secretListField("WEATHER_API_KEYS", "providers.weatherapi_keys", "comma or line separated WeatherAPI keys", &c.Providers.WeatherApiKeys)),
+		stringField("WEATHER_API_KEY_STRATEGY", "providers.weatherapi_key_strategy", "selection of the WeatherAPI key of a call: round_robin or least_used", &c.Providers.WeatherApiKeyStrategy, validKeyStrategy),
+		durationField("WEATHER_API_KEY_COOLDOWN", "providers.weatherapi_key_cooldown", "quarantine of a WeatherAPI key rejected as invalid or out of quota", &c.Providers.WeatherApiKeyCooldown),
+		reloadable(secretField("ADMIN_TOKEN", "admin_token", "admin token of the webhooks and the debug breakdowns", &c.AdminToken, nil)),
+		intField("UNITS_PRECISION", "units.precision", "decimal places of the measurements, negative to disable rounding", &c.Units.Precision, nil),
+		reloadable(durationField("CACHE_CEP_MAX_AGE", "cache.cep_max_age", "lifetime of the CEP lookups", &c.Cache.CEPMaxAge)),
+		reloadable(durationField("CACHE_WEATHER_INTERVAL", "cache.weather_interval", "refresh interval of the weather conditions by the provider", &c.Cache.WeatherInterval)),
+		durationField("STREAM_REFRESH_INTERVAL", "stream.refresh_interval", "refresh interval of the live weather", &c.Stream.RefreshInterval),
+		durationField("STREAM_HEARTBEAT_INTERVAL", "stream.heartbeat_interval", "heartbeat interval of the SSE streams", &c.Stream.HeartbeatInterval),
+		durationField("WS_PING_INTERVAL", "socket.ping_interval", "ping interval of the WebSockets", &c.Socket.PingInterval),
+		reloadable(intField("WS_MAX_SUBSCRIPTIONS", "socket.max_subscriptions", "CEPs followed by a WebSocket at most", &c.Socket.MaxSubscriptions, positive)),
+		listField("WS_ALLOWED_ORIGINS", "socket.allowed_origins", "comma separated hosts of other origins allowed to open a WebSocket", &c.Socket.AllowedOrigins),
+		boolField("GRAPHQL_INTROSPECTION", "graphql.introspection", "enable the GraphQL introspection", &c.GraphQL.Introspection),
+		stringField("WEBHOOK_STORE_PATH", "webhook.store_path", "file storing the webhooks", &c.Webhook.StorePath, required),
+		durationField("WEBHOOK_EVAL_INTERVAL", "webhook.eval_interval", "evaluation interval of the webhook rules", &c.Webhook.EvalInterval),
+		intField("WEBHOOK_MAX_ATTEMPTS", "webhook.max_attempts", "delivery attempts of a webhook", &c.Webhook.MaxAttempts, positive),
+		durationField("WEBHOOK_BACKOFF", "webhook.backoff", "wait before the first retry of a delivery", &c.Webhook.Backoff),
+		durationField("WEBHOOK_TIMEOUT", "webhook.timeout", "timeout of a delivery", &c.Webhook.Timeout),
+		durationField("HEALTH_PROBE_INTERVAL", "health.probe_interval", "interval the result of a readiness probe is reused for", &c.Health.ProbeInterval),
+		durationField("CONFIG_RELOAD_INTERVAL", "reload.interval", "interval the configuration files are checked for changes", &c.Reload.Interval),
+		durationField("HEALTH_PROBE_TIMEOUT", "health.probe_timeout", "timeout of a readiness probe", &c.Health.ProbeTimeout),
+		{
+			env: "HEALTH_DRAIN_DELAY", key: "health.drain_delay", usage: "delay between the shutdown signal and the shutdown of the server",
+			ptr:   &c.Health.DrainDelay,
+			parse: parseDuration(&c.Health.DrainDelay),
+			validate: func() error {
+				if c.Health.DrainDelay < 0 {
+					return errors.New("must not be negative")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// reloadable marks a setting as applied by a reload of the configuration
+func reloadable(f field) field {
+	f.reloadable = true
+	return f
+}
+
+func stringField(env, key, usage string, p *string, check func(string) error) field {
+	f := field{
+		env: env, key: key, usage: usage, ptr: p,
+		parse: func(value string) error {
+			*p = value
+			return nil
+		},
+	}
+	if check != nil {
+		f.validate = func() error { return check(*p) }
+	}
+	return f
+}
+
+func secretField(env, key, usage string, p *string, validate func() error) field {
+	f := stringField(env, key, usage, p, nil)
+	f.secret, f.validate = true, validate
+	return f
+}
+
+func secretListField(env, key, usage string, p *[]string) field {
+	f := listField(env, key, usage, p)
+	f.secret = true
+	return f
+}
+
+func durationField(env, key, usage string, p *time.Duration) field {
+	return field{
+		env: env, key: key, usage: usage, ptr: p,
+		parse: parseDuration(p),
+		validate: func() error {
+			if *p <= 0 {
+				return errors.New("must be positive")
+			}
+			return nil
+		},
+	}
+}
+
+func parseDuration(p *time.Duration) func(string) error {
+	return func(value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = d
+		return nil
+	}
+}
+
+func intField(env, key, usage string, p *int, check func(int) error) field {
+	f := field{
+		env: env, key: key, usage: usage, ptr: p,
+		parse: func(value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not an integer", value)
+			}
+			*p = n
+			return nil
+		},
+	}
+	if check != nil {
+		f.validate = func() error { return check(*p) }
+	}
+	return f
+}
+
+func boolField(env, key, usage string, p *bool) field {
+	return field{
+		env: env, key: key, usage: usage, ptr: p,
+		parse: func(value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a boolean", value)
+			}
+			*p = b
+			return nil
+		},
+	}
+}
+
+func listField(env, key, usage string, p *[]string) field {
+	return field{
+		env: env, key: key, usage: usage, ptr: p,
+		parse: func(value string) error {
+			*p = nil
+			for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+				if item = strings.TrimSpace(item); item != "" {
+					*p = append(*p, item)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func required(value string) error {
+	if value == "" {
+		return errors.New("is required")
+	}
+	return nil
+}
+
+func positive(n int) error {
+	if n <= 0 {
+		return errors.New("must be positive")
+	}
+	return nil
+}
+
+func validPort(value string) error {
+	if port, err := strconv.Atoi(value); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("%q is not a port", value)
+	}
+	return nil
+}
+
+func validAddr(value string) error {
+	if _, port, err := net.SplitHostPort(value); err != nil || validPort(port) != nil {
+		return fmt.Errorf("%q is not a host:port address", value)
+	}
+	return nil
+}
+
+func validLogLevel(value string) error {
+	if _, err := logging.ParseLevel(value); err != nil {
+		return fmt.Errorf("%q is not a log level", value)
+	}
+	return nil
+}
+
+func validExporter(value string) error {
+	switch value {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+		return nil
+	}
+	return fmt.Errorf("%q is not an exporter", value)
+}
+
+func validKeyStrategy(value string) error {
+	switch value {
+	case data.KeyStrategyRoundRobin, data.KeyStrategyLeastUsed:
+		return nil
+	}
+	return fmt.Errorf("%q is not a key strategy", value)
+}
+
+func validURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
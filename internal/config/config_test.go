@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "env-key")
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+
+	expected := Default()
+	expected.Providers.WeatherApiKey = "env-key"
+	assert.Equal(t, expected, cfg)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+server:
+  port: "9000"
+  grpc_port: "9001"
+  read_timeout: 20s
+providers:
+  weatherapi_key: file-key
+socket:
+  allowed_origins: [a.example.com]
+`)
+
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("GRPC_PORT", "9101")
+	t.Setenv("SERVER_READ_TIMEOUT", "30s")
+	t.Setenv("WS_ALLOWED_ORIGINS", "b.example.com, c.example.com")
+
+	cfg, err := Load([]string{"-server-read-timeout", "40s"})
+	require.NoError(t, err)
+
+	type testRow struct {
+		name     string
+		actual   any
+		expected any
+	}
+
+	testTable := []testRow{
+		{name: "file over default", actual: cfg.Server.Port, expected: "9000"},
+		{name: "env over file", actual: cfg.Server.GrpcPort, expected: "9101"},
+		{name: "flag over env", actual: cfg.Server.ReadTimeout, expected: 40 * time.Second},
+		{name: "default", actual: cfg.Server.WriteTimeout, expected: 10 * time.Second},
+		{name: "secret from file", actual: cfg.Providers.WeatherApiKey, expected: "file-key"},
+		{name: "list from env", actual: cfg.Socket.AllowedOrigins, expected: []string{"b.example.com", "c.example.com"}},
+		{name: "config file", actual: cfg.File, expected: file},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, tr.actual)
+		})
+	}
+}
+
+func TestLoadSecretFromFile(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY_FILE", writeFile(t, "weather_api_key", "env-file-key\n"))
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "env-file-key", cfg.Providers.WeatherApiKey)
+
+	cfg, err = Load([]string{"-admin-token-file", writeFile(t, "admin_token", "flag-file-token")})
+	require.NoError(t, err)
+	assert.Equal(t, "flag-file-token", cfg.AdminToken)
+}
+
+func TestLoadCEPHashKey(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "env-key")
+	t.Setenv("TRACING_CEP_HASH_KEY_FILE", writeFile(t, "cep_hash_key", "0123456789abcdef0123\n"))
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef0123", cfg.Tracing.CEPHashKey)
+
+	t.Setenv("TRACING_CEP_HASH_KEY_FILE", writeFile(t, "cep_hash_key", "short"))
+	_, err = Load(nil)
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	assert.Equal(t, []string{"TRACING_CEP_HASH_KEY (tracing.cep_hash_key): must have at least 16 characters"}, cfgErr.Problems)
+}
+
+func TestLoadWeatherApiKeys(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "k1")
+	t.Setenv("WEATHER_API_KEYS_FILE", writeFile(t, "weather_api_keys", "k2\nk3\n"))
+
+	cfg, err := Load([]string{"-weather-api-key-strategy", "least_used"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"k1", "k2", "k3"}, cfg.Providers.WeatherApiKeyPool())
+	assert.Equal(t, "least_used", cfg.Providers.WeatherApiKeyStrategy)
+
+	t.Setenv("WEATHER_API_KEY", "")
+	t.Setenv("WEATHER_API_KEY_STRATEGY", "random")
+	_, err = Load(nil)
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	assert.Equal(t, []string{`WEATHER_API_KEY_STRATEGY (providers.weatherapi_key_strategy): "random" is not a key strategy`}, cfgErr.Problems)
+}
+
+func TestLoadRejectsSecretFlags(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "env-key")
+
+	_, err := Load([]string{"-weather-api-key", "flag-key"})
+	assert.Error(t, err)
+}
+
+func TestLoadHelp(t *testing.T) {
+	_, err := Load([]string{"-h"})
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "env-key")
+	t.Setenv("WEATHER_API_KEY_FILE", writeFile(t, "weather_api_key", "env-file-key"))
+	t.Setenv("PORT", "http")
+	t.Setenv("WEBHOOK_TIMEOUT", "soon")
+	t.Setenv("VIACEP_ENDPOINT", "viacep.com.br")
+
+	_, err := Load([]string{"-log-level", "loud", "-ws-max-subscriptions", "0"})
+
+	var cfgErr *Error
+	require.ErrorAs(t, err, &cfgErr)
+	assert.Equal(t, []string{
+		"WEATHER_API_KEY: set either WEATHER_API_KEY or WEATHER_API_KEY_FILE",
+		`WEBHOOK_TIMEOUT: time: invalid duration "soon"`,
+		`PORT (server.port): "http" is not a port`,
+		`LOG_LEVEL (log.level): "loud" is not a log level`,
+		`VIACEP_ENDPOINT (providers.viacep_endpoint): "viacep.com.br" is not an http(s) URL`,
+		"WEATHER_API_KEY (providers.weatherapi_key): is required, set it, WEATHER_API_KEY_FILE or WEATHER_API_KEYS",
+		"WS_MAX_SUBSCRIPTIONS (socket.max_subscriptions): must be positive",
+	}, cfgErr.Problems)
+	assert.Contains(t, err.Error(), "invalid configuration:\n  - WEATHER_API_KEY: set either")
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	type testRow struct {
+		name     string
+		file     string
+		expected string
+	}
+
+	testTable := []testRow{
+		{name: "unknown setting", file: writeFile(t, "config.yaml", "server:\n  prot: \"9000\"\n"), expected: "field prot not found"},
+		{name: "invalid duration", file: writeFile(t, "config.yaml", "health:\n  probe_timeout: soon\n"), expected: "cannot unmarshal !!str `soon` into time.Duration"},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.yaml"), expected: "config file:"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			t.Setenv("WEATHER_API_KEY", "env-key")
+
+			_, err := Load([]string{"-config", tr.file})
+
+			var cfgErr *Error
+			require.ErrorAs(t, err, &cfgErr)
+			require.Len(t, cfgErr.Problems, 1)
+			assert.Contains(t, cfgErr.Problems[0], tr.expected)
+		})
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "env-key")
+
+	_, err := Load([]string{"-config", writeFile(t, "config.yaml", "")})
+	assert.NoError(t, err)
+}
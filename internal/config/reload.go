@@ -0,0 +1,146 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reloader reloads the configuration while the application runs, applying the reloadable settings to the components.
+// The other settings require a restart, their changes are reported and ignored.
+type Reloader struct {
+	args []string
+
+	mu       sync.Mutex
+	current  *Config
+	apply    []func(cfg *Config)
+	versions map[string]fileVersion
+}
+
+// fileVersion tells the versions of a configuration file apart
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader creates a reloader of cfg, loaded from args, the command line flags
+func NewReloader(cfg *Config, args []string) *Reloader {
+	return &Reloader{args: args, current: cfg, versions: cfg.fileVersions()}
+}
+
+// Config returns the current configuration
+func (r *Reloader) Config() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// OnReload registers apply to be called with the new configuration on every reload.
+// It must be called before the reloader starts watching.
+func (r *Reloader) OnReload(apply func(cfg *Config)) {
+	r.apply = append(r.apply, apply)
+}
+
+// Reload loads the configuration again and applies its reloadable settings.
+// An invalid configuration is rejected, the current one is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The versions are taken before loading, a file changed meanwhile is reloaded again
+	r.versions = r.current.fileVersions()
+	next, err := Load(r.args)
+	if err != nil {
+		slog.Error("Configuration rejected, keeping the current one", "error", err)
+		return err
+	}
+
+	cfg, changed, ignored := r.current.merge(next)
+	if len(ignored) > 0 {
+		slog.Warn("Configuration changes ignored until the next restart", "settings", ignored)
+	}
+	r.current = cfg
+	if len(changed) == 0 {
+		slog.Info("Configuration reloaded, nothing changed")
+		return nil
+	}
+
+	for _, apply := range r.apply {
+		apply(cfg)
+	}
+	slog.Info("Configuration reloaded", "settings", changed)
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP, and when its files change, checked every interval, until ctx is done
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("Received SIGHUP, reloading the configuration")
+			_ = r.Reload()
+		case <-ticker.C:
+			if r.filesChanged() {
+				slog.Info("Configuration files changed, reloading the configuration")
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) filesChanged() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !reflect.DeepEqual(r.versions, r.current.fileVersions())
+}
+
+// fileVersions returns the versions of the YAML file and of the secret files, a missing file has no version
+func (c *Config) fileVersions() map[string]fileVersion {
+	versions := map[string]fileVersion{}
+	for _, path := range append([]string{c.File}, c.SecretFiles...) {
+		if path == "" {
+			continue
+		}
+		// The file is followed through symlinks, as mounted secrets are swapped by replacing their link
+		if info, err := os.Stat(path); err == nil {
+			versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return versions
+}
+
+// merge returns a copy of c with the reloadable settings of next.
+// It reports the reloadable settings that changed, and the other settings that changed and are ignored.
+func (c *Config) merge(next *Config) (_ *Config, changed, ignored []string) {
+	merged := *c
+	mergedFields, nextFields := merged.fields(), next.fields()
+	for i, f := range mergedFields {
+		current, value := reflect.ValueOf(f.ptr).Elem(), reflect.ValueOf(nextFields[i].ptr).Elem()
+		if reflect.DeepEqual(current.Interface(), value.Interface()) {
+			continue
+		}
+		if !f.reloadable {
+			ignored = append(ignored, f.env)
+			continue
+		}
+		current.Set(value)
+		changed = append(changed, f.env)
+	}
+	// A secret may move to another file, which is watched from then on
+	merged.SecretFiles = next.SecretFiles
+	return &merged, changed, ignored
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	file := writeFile(t, "config.yaml", "server:\n  port: \"9000\"\ncache:\n  cep_max_age: 1h\n")
+	keyFile := writeFile(t, "weather_api_key", "old-key")
+	t.Setenv("WEATHER_API_KEY_FILE", keyFile)
+
+	args := []string{"-config", file}
+	cfg, err := Load(args)
+	require.NoError(t, err)
+
+	reloader := NewReloader(cfg, args)
+	var applied *Config
+	reloader.OnReload(func(cfg *Config) {
+		applied = cfg
+	})
+
+	require.NoError(t, os.WriteFile(file, []byte("server:\n  port: \"9001\"\ncache:\n  cep_max_age: 2h\n"), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, []byte("new-key"), 0o600))
+	require.NoError(t, reloader.Reload())
+
+	type testRow struct {
+		name     string
+		actual   any
+		expected any
+	}
+
+	require.NotNil(t, applied)
+	testTable := []testRow{
+		{name: "secret rotated", actual: applied.Providers.WeatherApiKey, expected: "new-key"},
+		{name: "lifetime swapped", actual: applied.Cache.CEPMaxAge, expected: 2 * time.Hour},
+		{name: "port kept until the restart", actual: applied.Server.Port, expected: "9000"},
+		{name: "current configuration", actual: reloader.Config(), expected: applied},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, tr.actual)
+		})
+	}
+
+	// The loaded configuration is not modified by the reload
+	assert.Equal(t, "old-key", cfg.Providers.WeatherApiKey)
+}
+
+func TestReloadRejectsInvalidConfiguration(t *testing.T) {
+	file := writeFile(t, "config.yaml", "providers:\n  weatherapi_key: old-key\n")
+
+	args := []string{"-config", file}
+	cfg, err := Load(args)
+	require.NoError(t, err)
+
+	reloader := NewReloader(cfg, args)
+	var reloads int
+	reloader.OnReload(func(*Config) {
+		reloads++
+	})
+
+	require.NoError(t, os.WriteFile(file, []byte("providers:\n  weatherapi_key: new-key\nlog:\n  level: loud\n"), 0o600))
+
+	var cfgErr *Error
+	require.ErrorAs(t, reloader.Reload(), &cfgErr)
+	assert.Equal(t, 0, reloads)
+	assert.Same(t, cfg, reloader.Config())
+}
+
+func TestWatchReloadsChangedFiles(t *testing.T) {
+	keyFile := writeFile(t, "weather_api_key", "old-key")
+	t.Setenv("WEATHER_API_KEY_FILE", keyFile)
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+
+	reloader := NewReloader(cfg, nil)
+	var key atomic.Value
+	reloader.OnReload(func(cfg *Config) {
+		key.Store(cfg.Providers.WeatherApiKey)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		reloader.Watch(ctx, 10*time.Millisecond)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("rotated-key"), 0o600))
+	assert.Eventually(t, func() bool {
+		return key.Load() == "rotated-key"
+	}, time.Second, 10*time.Millisecond)
+}
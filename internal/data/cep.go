@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/tracing"
 	"io"
 	"net/http"
 )
@@ -25,28 +26,54 @@ type cepDTO struct {
 	Gia         string `json:"gia"`
 	Ddd         string `json:"ddd"`
 	Siafi       string `json:"siafi"`
+	// Erro is set by ViaCEP when the CEP does not exist
+	Erro flexibleBool `json:"erro"`
+}
+
+// flexibleBool decodes booleans sent either as JSON booleans or as strings ("true").
+// ViaCEP has used both representations for the erro field.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = flexibleBool(v)
+	case string:
+		*b = flexibleBool(v == "true")
+	}
+	return nil
 }
 
 type ViaCEPStore struct {
 	targetEndpoint string
 }
 
-// NewViaCEPStore creates a new instance of ViaCEPStore
-func NewViaCEPStore() *ViaCEPStore {
+// DefaultViaCEPEndpoint is the base URL of the ViaCEP API
+const DefaultViaCEPEndpoint = "https://viacep.com.br"
+
+// NewViaCEPStore creates a new instance of ViaCEPStore calling the ViaCEP API at endpoint
+func NewViaCEPStore(endpoint string) *ViaCEPStore {
 	return &ViaCEPStore{
-		targetEndpoint: "https://viacep.com.br/ws/%s/json",
+		targetEndpoint: baseURL(endpoint) + "/ws/%s/json",
 	}
 }
 
 // GetCEP retrieves information for a given CEP
-func (s *ViaCEPStore) GetCEP(ctx context.Context, cep string) (*entity.CEP, error) {
+func (s *ViaCEPStore) GetCEP(ctx context.Context, cep string) (_ *entity.CEP, err error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(s.targetEndpoint, cep), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	req, done := startUpstream(req, providerViaCEP, "ViaCEPStore.GetCEP", fmt.Sprintf(s.targetEndpoint, "{cep}"), tracing.CEP(cep))
+	defer func() { done(err) }()
+
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, transportError(providerViaCEP, err)
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -54,15 +81,20 @@ func (s *ViaCEPStore) GetCEP(ctx context.Context, cep string) (*entity.CEP, erro
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch CEP: received status code %d", resp.StatusCode)
+		return nil, statusError(providerViaCEP, resp.StatusCode)
 	}
 
 	var cepData cepDTO
 	if err := json.NewDecoder(resp.Body).Decode(&cepData); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+		return nil, payloadError(providerViaCEP, fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	if cepData.Erro {
+		return nil, notFoundError(providerViaCEP, "cep")
 	}
 
 	return &entity.CEP{
 		Localidade: cepData.Localidade,
+		Uf:         cepData.Uf,
 	}, nil
 }
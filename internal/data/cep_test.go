@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"github.com/caricciy/go-weather/internal/entity"
 	"github.com/stretchr/testify/assert"
 	"net/http"
 	"net/http/httptest"
@@ -66,6 +67,18 @@ func TestGetCEP(t *testing.T) {
 	})
 }
 
+func TestNewViaCEPStore(t *testing.T) {
+	mockServer := createMockServer(cepDTO{Localidade: "São Paulo"})
+	defer mockServer.Close()
+
+	// The trailing slash of the configured endpoint is not doubled in the request URL
+	store := NewViaCEPStore(mockServer.URL + "/")
+
+	cep, err := store.GetCEP(context.Background(), "12345678")
+	assert.NoError(t, err)
+	assert.Equal(t, "São Paulo", cep.Localidade)
+}
+
 func TestGetCEP_Timeout(t *testing.T) {
 	// Create a mock HTTP server that delays its response (server port will be random)
 	mockServer := createMockServer(0) // No response data, just a delay
@@ -83,7 +96,7 @@ func TestGetCEP_Timeout(t *testing.T) {
 	cep, err := store.GetCEP(ctx, "12345678")
 
 	// Assert that a timeout error occurred
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, entity.ErrUpstreamTimeout)
 	assert.Nil(t, cep)
 }
 
@@ -99,3 +112,29 @@ func TestGetCEP_InvalidResponse(t *testing.T) {
 	// Expecting Localidade to be empty due to invalid response
 	assert.Empty(t, cep.Localidade)
 }
+
+func TestGetCEP_NotFound(t *testing.T) {
+	// ViaCEP answers unknown CEPs with 200 and an erro flag, as a boolean or as a string
+	for _, erro := range []any{true, "true"} {
+		mockServer := createMockServer(map[string]any{"erro": erro})
+
+		store := &ViaCEPStore{targetEndpoint: mockServer.URL + "/ws/%s/json"}
+
+		cep, err := store.GetCEP(context.Background(), "12345678")
+		assert.ErrorIs(t, err, entity.ErrUpstreamNotFound)
+		assert.Nil(t, cep)
+
+		mockServer.Close()
+	}
+}
+
+func TestGetCEP_MalformedResponse(t *testing.T) {
+	mockServer := createMockServer("not a json object")
+	defer mockServer.Close()
+
+	store := &ViaCEPStore{targetEndpoint: mockServer.URL + "/ws/%s/json"}
+
+	cep, err := store.GetCEP(context.Background(), "12345678")
+	assert.ErrorIs(t, err, entity.ErrUpstreamBadPayload)
+	assert.Nil(t, cep)
+}
@@ -0,0 +1,97 @@
+package data
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"log/slog"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// connTrace times the connection phases of a call to a provider with net/http/httptrace.
+// The phases are timed whether they succeed or not, as a stalled DNS lookup or connect is what it is meant to reveal.
+type connTrace struct {
+	mu    sync.Mutex
+	start time.Time
+
+	dnsStart, connectStart, tlsStart time.Time
+	// durations of the phases that happened, a reused connection skips all of them but the time to first byte
+	durations map[string]time.Duration
+
+	gotConn bool
+	reused  bool
+}
+
+// withConnTrace returns a copy of ctx timing the connection phases of the requests made with it
+func withConnTrace(ctx context.Context) (context.Context, *connTrace) {
+	t := &connTrace{start: time.Now(), durations: map[string]time.Duration{}}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.begin(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.end(metrics.PhaseDNS, &t.dnsStart)
+		},
+		// ConnectStart is called for each address dialed, the connect phase lasts from the first dial
+		ConnectStart: func(string, string) {
+			t.begin(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			t.end(metrics.PhaseConnect, &t.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			t.begin(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.end(metrics.PhaseTLS, &t.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = true
+			t.reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			t.end(metrics.PhaseTTFB, &t.start)
+		},
+	}), t
+}
+
+// begin sets the start of a phase, keeping the first one when the phase happens several times
+func (t *connTrace) begin(start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if start.IsZero() {
+		*start = time.Now()
+	}
+}
+
+// end sets the duration of a phase from its start
+func (t *connTrace) end(phase string, start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.durations[phase] = time.Since(*start)
+}
+
+// record records the timed phases as metrics of the provider and returns them as log attributes
+func (t *connTrace) record(provider string) []any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var attrs []any
+	for _, phase := range []string{metrics.PhaseDNS, metrics.PhaseConnect, metrics.PhaseTLS, metrics.PhaseTTFB} {
+		if d, ok := t.durations[phase]; ok {
+			metrics.UpstreamPhase(provider, phase, d)
+			attrs = append(attrs, slog.Float64(phase+"_ms", logging.Milliseconds(d)))
+		}
+	}
+	if t.gotConn {
+		metrics.UpstreamConnection(provider, t.reused)
+		attrs = append(attrs, slog.Bool("conn_reused", t.reused))
+	}
+	return attrs
+}
@@ -0,0 +1,56 @@
+package data
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConnTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{}}
+
+	type testRow struct {
+		name     string
+		expected []string
+		reused   bool
+	}
+
+	// The second call reuses the connection of the first one, it only waits for the first byte
+	testTable := []testRow{
+		{name: "new connection", expected: []string{"connect_ms", "ttfb_ms", "conn_reused"}},
+		{name: "reused connection", expected: []string{"ttfb_ms", "conn_reused"}, reused: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			ctx, conn := withConnTrace(context.Background())
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			res, err := client.Do(req)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, res.Body)
+			require.NoError(t, res.Body.Close())
+
+			var keys []string
+			var reused bool
+			for _, attr := range conn.record(providerViaCEP) {
+				a := attr.(slog.Attr)
+				keys = append(keys, a.Key)
+				if a.Key == "conn_reused" {
+					reused = a.Value.Bool()
+				}
+			}
+			assert.Equal(t, tr.expected, keys)
+			assert.Equal(t, tr.reused, reused)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"io"
+	"strconv"
+	"time"
+)
+
+// forecastDTO is a data transfer object (DTO) for the WeatherAPI forecast response.
+// Readings are pointers so a missing field can be told apart from a genuine zero reading.
+type forecastDTO struct {
+	Forecast *struct {
+		ForecastDay []forecastDayDTO `json:"forecastday"`
+	} `json:"forecast"`
+}
+
+type forecastDayDTO struct {
+	Date string `json:"date"`
+	Day  struct {
+		MaxTempC          *float64 `json:"maxtemp_c"`
+		MinTempC          *float64 `json:"mintemp_c"`
+		AvgTempC          *float64 `json:"avgtemp_c"`
+		MaxWindKph        *float64 `json:"maxwind_kph"`
+		TotalPrecipMm     *float64 `json:"totalprecip_mm"`
+		DailyChanceOfRain *float64 `json:"daily_chance_of_rain"`
+		Condition         struct {
+			Text string `json:"text"`
+		} `json:"condition"`
+	} `json:"day"`
+}
+
+// GetForecast gets the daily forecast of the city of cep for the next days, starting today.
+// A call whose key is rejected by WeatherAPI is retried with another key of the pool, until every key is quarantined.
+func (w *WeatherApiRepository) GetForecast(ctx context.Context, cep *entity.CEP, days int) ([]entity.ForecastDay, error) {
+	return withKey(ctx, w.keys, func(key *apiKey) ([]entity.ForecastDay, error) {
+		return w.getForecast(ctx, key, weatherApiLocation(cep), days)
+	})
+}
+
+func (w *WeatherApiRepository) getForecast(ctx context.Context, key *apiKey, location string, days int) ([]entity.ForecastDay, error) {
+	var forecastData forecastDTO
+	err := w.get(ctx, key, "WeatherApiRepository.GetForecast", w.forecastEndpoint, location, "&days="+strconv.Itoa(days), func(body io.Reader) error {
+		if err := json.NewDecoder(body).Decode(&forecastData); err != nil {
+			return payloadError(providerWeatherApi, fmt.Errorf("failed to decode response: %w", err))
+		}
+
+		if forecastData.Forecast == nil {
+			return payloadError(providerWeatherApi, fmt.Errorf("%w: missing forecast", ErrIncompleteWeatherPayload))
+		}
+		for _, day := range forecastData.Forecast.ForecastDay {
+			if _, err := time.Parse(time.DateOnly, day.Date); err != nil {
+				return payloadError(providerWeatherApi, fmt.Errorf("%w: invalid forecast date %q", ErrIncompleteWeatherPayload, day.Date))
+			}
+			if day.Day.MaxTempC == nil || day.Day.MinTempC == nil || day.Day.AvgTempC == nil {
+				return payloadError(providerWeatherApi, fmt.Errorf("%w: missing temperature of %s", ErrIncompleteWeatherPayload, day.Date))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forecast := make([]entity.ForecastDay, len(forecastData.Forecast.ForecastDay))
+	for i, day := range forecastData.Forecast.ForecastDay {
+		forecast[i] = entity.ForecastDay{
+			Date:          day.Date,
+			MaxTempC:      *day.Day.MaxTempC,
+			MinTempC:      *day.Day.MinTempC,
+			AvgTempC:      *day.Day.AvgTempC,
+			MaxWindKph:    day.Day.MaxWindKph,
+			TotalPrecipMm: day.Day.TotalPrecipMm,
+			ChanceOfRain:  day.Day.DailyChanceOfRain,
+			Condition:     day.Day.Condition.Text,
+		}
+	}
+	return forecast, nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newForecastStore(t *testing.T, body string) (*WeatherApiRepository, *http.Request) {
+	var received http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = *r
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewWeatherApiStore(server.URL, testKeyPool()), &received
+}
+
+func TestGetForecast(t *testing.T) {
+	store, received := newForecastStore(t, `{"forecast":{"forecastday":[
+		{"date":"2026-01-15","day":{"maxtemp_c":31.2,"mintemp_c":22.4,"avgtemp_c":26.1,"maxwind_kph":18.4,"totalprecip_mm":0,"daily_chance_of_rain":0,"condition":{"text":"Sunny"}}},
+		{"date":"2026-01-16","day":{"maxtemp_c":28,"mintemp_c":21,"avgtemp_c":24.5,"condition":{"text":"Patchy rain nearby"}}}
+	]}}`)
+
+	forecast, err := store.GetForecast(context.Background(), &entity.CEP{Localidade: "Lisboa", Country: "PT"}, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, "/v1/forecast.json", received.URL.Path)
+	assert.Equal(t, "Lisboa,PT", received.URL.Query().Get("q"))
+	assert.Equal(t, "2", received.URL.Query().Get("days"))
+	assert.Equal(t, []entity.ForecastDay{
+		{
+			Date:          "2026-01-15",
+			MaxTempC:      31.2,
+			MinTempC:      22.4,
+			AvgTempC:      26.1,
+			MaxWindKph:    floatPtr(18.4),
+			TotalPrecipMm: floatPtr(0),
+			ChanceOfRain:  floatPtr(0),
+			Condition:     "Sunny",
+		},
+		// Readings the provider did not report are left out rather than zeroed
+		{Date: "2026-01-16", MaxTempC: 28, MinTempC: 21, AvgTempC: 24.5, Condition: "Patchy rain nearby"},
+	}, forecast)
+}
+
+func TestGetForecast_IncompletePayload(t *testing.T) {
+	type testRow struct {
+		name string
+		body string
+	}
+
+	testTable := []testRow{
+		{name: "missing forecast", body: `{}`},
+		{name: "missing temperature", body: `{"forecast":{"forecastday":[{"date":"2026-01-15","day":{"maxtemp_c":31.2}}]}}`},
+		{name: "invalid date", body: `{"forecast":{"forecastday":[{"date":"tomorrow","day":{"maxtemp_c":31.2,"mintemp_c":22.4,"avgtemp_c":26.1}}]}}`},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			store, _ := newForecastStore(t, tr.body)
+
+			_, err := store.GetForecast(context.Background(), &entity.CEP{Localidade: "São Paulo"}, 3)
+			assert.ErrorIs(t, err, ErrIncompleteWeatherPayload)
+			assert.ErrorIs(t, err, entity.ErrUpstreamBadPayload)
+		})
+	}
+}
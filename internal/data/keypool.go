@@ -0,0 +1,181 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Strategies selecting the API key of a call
+const (
+	// KeyStrategyRoundRobin uses the keys in turn
+	KeyStrategyRoundRobin = "round_robin"
+	// KeyStrategyLeastUsed uses the key that served the fewest calls
+	KeyStrategyLeastUsed = "least_used"
+)
+
+// DefaultKeyCooldown is how long a key rejected by its provider is quarantined
+const DefaultKeyCooldown = time.Hour
+
+// ErrNoAvailableAPIKey is returned when every key of a pool is quarantined
+var ErrNoAvailableAPIKey = errors.New("no available API key")
+
+// APIKeyPool spreads the calls to a provider over its API keys.
+// A key rejected by the provider, as invalid, forbidden or out of quota, is quarantined for the cool-down period.
+// The keys are only ever reported by their fingerprint, the beginning of their SHA-256 hash.
+type APIKeyPool struct {
+	provider string
+	strategy string
+	cooldown time.Duration
+
+	mu   sync.Mutex
+	keys []*apiKey
+	// next is the position of the next key of the round-robin
+	next int
+}
+
+type apiKey struct {
+	value       string
+	fingerprint string
+	uses        int
+	// quarantinedUntil is the end of the quarantine of the key, zero when the key is in use
+	quarantinedUntil time.Time
+}
+
+// NewWeatherApiKeyPool creates the pool of the WeatherAPI keys, selecting the key of each call with strategy
+func NewWeatherApiKeyPool(keys []string, strategy string, cooldown time.Duration) *APIKeyPool {
+	pool := &APIKeyPool{provider: providerWeatherApi, strategy: strategy, cooldown: cooldown}
+	pool.SetKeys(keys)
+	return pool
+}
+
+// SetKeys replaces the keys of the pool, the keys kept keep their usage and quarantine.
+// The calls in progress complete with the key they started with.
+func (p *APIKeyPool) SetKeys(keys []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	previous := p.keys
+	p.keys = nil
+	for _, value := range keys {
+		if value == "" || slices.ContainsFunc(p.keys, func(k *apiKey) bool { return k.value == value }) {
+			continue
+		}
+		i := slices.IndexFunc(previous, func(k *apiKey) bool { return k.value == value })
+		if i >= 0 {
+			p.keys = append(p.keys, previous[i])
+			continue
+		}
+		key := &apiKey{value: value, fingerprint: Fingerprint(value)}
+		metrics.UpstreamKeyQuarantined(p.provider, key.fingerprint, false)
+		p.keys = append(p.keys, key)
+	}
+
+	for _, key := range previous {
+		if !slices.Contains(p.keys, key) {
+			metrics.ForgetUpstreamKey(p.provider, key.fingerprint)
+		}
+	}
+	p.next = 0
+}
+
+// acquire selects the key of a call, it fails with a rate limited error when every key is quarantined
+func (p *APIKeyPool) acquire(ctx context.Context) (*apiKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var selected *apiKey
+	for i := range p.keys {
+		key := p.keys[(p.next+i)%len(p.keys)]
+		if !key.quarantinedUntil.IsZero() {
+			if now.Before(key.quarantinedUntil) {
+				continue
+			}
+			key.quarantinedUntil = time.Time{}
+			metrics.UpstreamKeyQuarantined(p.provider, key.fingerprint, false)
+			logging.FromContext(ctx).Info("API key back in use", "provider", p.provider, "key", key.fingerprint)
+		}
+		if p.strategy != KeyStrategyLeastUsed {
+			selected = key
+			p.next = (p.next + i + 1) % len(p.keys)
+			break
+		}
+		if selected == nil || key.uses < selected.uses {
+			selected = key
+		}
+	}
+
+	if selected == nil {
+		return nil, &entity.UpstreamError{Provider: p.provider, Kind: entity.ErrUpstreamRateLimited, Err: ErrNoAvailableAPIKey}
+	}
+	selected.uses++
+	return selected, nil
+}
+
+// release records the outcome of a call made with key, quarantining the key when the provider rejected it.
+// It tells whether the key was rejected, so the call may be retried with another key.
+func (p *APIKeyPool) release(ctx context.Context, key *apiKey, err error) bool {
+	metrics.UpstreamKeyRequest(p.provider, key.fingerprint, err)
+	if !keyRejected(err) {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A key removed from the pool during the call is not quarantined, it won't be used again
+	if slices.Contains(p.keys, key) && key.quarantinedUntil.IsZero() {
+		key.quarantinedUntil = time.Now().Add(p.cooldown)
+		metrics.UpstreamKeyQuarantined(p.provider, key.fingerprint, true)
+		logging.FromContext(ctx).Warn("API key quarantined", "provider", p.provider, "key", key.fingerprint, "outcome", metrics.UpstreamOutcome(err), "until", key.quarantinedUntil)
+	}
+	return true
+}
+
+// withKey runs call with a key of the pool.
+// A call whose key is rejected by the provider is retried with another key, until every key is quarantined.
+func withKey[T any](ctx context.Context, pool *APIKeyPool, call func(key *apiKey) (T, error)) (T, error) {
+	var rejected error
+	for {
+		key, err := pool.acquire(ctx)
+		if err != nil && rejected != nil {
+			// The rejection of the last key tells why there is none left
+			var zero T
+			return zero, rejected
+		}
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+
+		value, err := call(key)
+		if !pool.release(ctx, key, err) {
+			return value, err
+		}
+		rejected = err
+	}
+}
+
+// keyRejected tells whether the provider rejected the key of a call, as invalid, forbidden or out of quota
+func keyRejected(err error) bool {
+	var ue *entity.UpstreamError
+	if !errors.As(err, &ue) {
+		return false
+	}
+	return ue.StatusCode == http.StatusUnauthorized || ue.StatusCode == http.StatusForbidden || errors.Is(err, entity.ErrUpstreamRateLimited)
+}
+
+// Fingerprint identifies an API key in the logs and the metrics without disclosing it
+func Fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:4])
+}
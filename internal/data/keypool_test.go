@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyPoolStrategies(t *testing.T) {
+	type testRow struct {
+		name     string
+		strategy string
+		expected []string
+	}
+
+	// Four calls with two keys, then three calls once a third key is added
+	testTable := []testRow{
+		{name: "round robin", strategy: KeyStrategyRoundRobin, expected: []string{"k1", "k2", "k1", "k2", "k1", "k2", "k3"}},
+		{name: "least used", strategy: KeyStrategyLeastUsed, expected: []string{"k1", "k2", "k1", "k2", "k3", "k3", "k1"}},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			pool := NewWeatherApiKeyPool([]string{"k1", "k2", "k1", ""}, tr.strategy, DefaultKeyCooldown)
+
+			var used []string
+			for i := range tr.expected {
+				if i == 4 {
+					pool.SetKeys([]string{"k1", "k2", "k3"})
+				}
+				key, err := pool.acquire(context.Background())
+				require.NoError(t, err)
+				pool.release(context.Background(), key, nil)
+				used = append(used, key.value)
+			}
+			assert.Equal(t, tr.expected, used)
+		})
+	}
+}
+
+// createKeyCheckingServer creates a WeatherAPI mock accepting good-key only, it records the keys of the calls
+func createKeyCheckingServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		mu.Lock()
+		keys = append(keys, key)
+		mu.Unlock()
+
+		switch key {
+		case "good-key":
+			_ = json.NewEncoder(w).Encode(map[string]any{"current": map[string]any{"temp_c": 25, "temp_f": 77}})
+		case "quota-key":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":2007,"message":"API key has exceeded calls per month quota."}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":2006,"message":"API key is invalid."}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestGetWeatherInfo_RotatesRejectedKeys(t *testing.T) {
+	server, calls := createKeyCheckingServer(t)
+	pool := NewWeatherApiKeyPool([]string{"quota-key", "revoked-key", "good-key"}, KeyStrategyRoundRobin, DefaultKeyCooldown)
+	store := NewWeatherApiStore(server.URL, pool)
+	cep := &entity.CEP{Localidade: "São Paulo"}
+
+	// The rejected keys are quarantined and the call is retried with the next key
+	weather, err := store.GetWeatherInfo(context.Background(), cep)
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, weather.Celcius)
+	assert.Equal(t, []string{"quota-key", "revoked-key", "good-key"}, calls())
+
+	// The quarantined keys are skipped until the end of the cool-down
+	_, err = store.GetWeatherInfo(context.Background(), cep)
+	require.NoError(t, err)
+	_, err = store.GetWeatherInfo(context.Background(), cep)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"quota-key", "revoked-key", "good-key", "good-key", "good-key"}, calls())
+}
+
+func TestGetWeatherInfo_EveryKeyRejected(t *testing.T) {
+	server, calls := createKeyCheckingServer(t)
+	pool := NewWeatherApiKeyPool([]string{"quota-key", "revoked-key"}, KeyStrategyRoundRobin, 50*time.Millisecond)
+	store := NewWeatherApiStore(server.URL, pool)
+	cep := &entity.CEP{Localidade: "São Paulo"}
+
+	// The error is the rejection of the last key
+	_, err := store.GetWeatherInfo(context.Background(), cep)
+	var ue *entity.UpstreamError
+	require.ErrorAs(t, err, &ue)
+	assert.Equal(t, http.StatusUnauthorized, ue.StatusCode)
+
+	// WeatherAPI is not called while every key is quarantined
+	_, err = store.GetWeatherInfo(context.Background(), cep)
+	assert.ErrorIs(t, err, ErrNoAvailableAPIKey)
+	assert.ErrorIs(t, err, entity.ErrUpstreamRateLimited)
+	assert.Len(t, calls(), 2)
+
+	// The keys are tried again once the cool-down is over
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.GetWeatherInfo(context.Background(), cep)
+	assert.Error(t, err)
+	assert.Len(t, calls(), 4)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint("test-api-key"), Fingerprint("test-api-key"))
+	assert.NotEqual(t, Fingerprint("test-api-key"), Fingerprint("other-api-key"))
+	assert.NotContains(t, Fingerprint("test-api-key"), "test-api-key")
+	assert.Len(t, Fingerprint("test-api-key"), 8)
+}
@@ -0,0 +1,114 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"github.com/caricciy/go-weather/internal/timing"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider names used to tag upstream errors
+const (
+	providerViaCEP     = "viacep"
+	providerZippopotam = "zippopotam"
+	providerWeatherApi = "weatherapi"
+)
+
+// upstreamClient is the HTTP client of the providers, it propagates the trace context of the requests
+var upstreamClient = &http.Client{Transport: tracing.NewTransport(http.DefaultTransport)}
+
+// baseURL prepares the base URL of a provider to prefix the format of its request URLs
+func baseURL(endpoint string) string {
+	return strings.ReplaceAll(strings.TrimRight(endpoint, "/"), "%", "%%")
+}
+
+// startUpstream records the metrics, the span, the log and the timing provider of a call to a provider, the returned function ends them with the error of the call.
+// The span of the HTTP request records redactedURL, so the postal code and the credentials in the URL are not exported.
+// The connection phases of the call are logged and recorded as metrics too.
+func startUpstream(req *http.Request, provider, operation, redactedURL string, attrs ...attribute.KeyValue) (*http.Request, func(err error)) {
+	start := time.Now()
+	done := metrics.StartUpstream(provider)
+	timing.SetProvider(req.Context(), provider)
+	ctx, end := tracing.Start(req.Context(), operation, append(attrs, tracing.KeyProvider.String(provider))...)
+	traceCtx, conn := withConnTrace(tracing.WithRedactedURL(ctx, redactedURL))
+
+	return req.WithContext(traceCtx), func(err error) {
+		outcome := metrics.UpstreamOutcome(err)
+		done(err)
+		end(err, tracing.KeyOutcome.String(outcome))
+
+		logger := logging.FromContext(ctx).With("provider", provider, "outcome", outcome, "latency_ms", logging.Milliseconds(time.Since(start)))
+		logger = logger.With(conn.record(provider)...)
+		if err != nil && outcome != metrics.OutcomeNotFound {
+			logger.Warn("Upstream call failed", "error", err)
+			return
+		}
+		logger.Debug("Upstream call completed")
+	}
+}
+
+// transportError classifies an error returned by http.Client.Do
+func transportError(provider string, err error) error {
+	kind := entity.ErrUpstreamUnavailable
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = entity.ErrUpstreamTimeout
+	}
+	return &entity.UpstreamError{
+		Provider: provider,
+		Kind:     kind,
+		Err:      fmt.Errorf("failed to execute request: %w", err),
+	}
+}
+
+// statusError classifies an unexpected HTTP status answered by a provider
+func statusError(provider string, statusCode int) error {
+	kind := entity.ErrUpstreamUnavailable
+	switch {
+	case statusCode == http.StatusNotFound:
+		kind = entity.ErrUpstreamNotFound
+	case statusCode == http.StatusTooManyRequests:
+		kind = entity.ErrUpstreamRateLimited
+	case statusCode == http.StatusGatewayTimeout:
+		kind = entity.ErrUpstreamTimeout
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		// The provider refused our credentials, it stays unusable until they are fixed
+		kind = entity.ErrUpstreamUnavailable
+	case statusCode < http.StatusInternalServerError:
+		// The provider rejected a request we built, its answer cannot be used
+		kind = entity.ErrUpstreamBadPayload
+	}
+	return &entity.UpstreamError{
+		Provider:   provider,
+		Kind:       kind,
+		StatusCode: statusCode,
+		Err:        fmt.Errorf("received status code %d", statusCode),
+	}
+}
+
+// payloadError reports a response body that could not be used
+func payloadError(provider string, err error) error {
+	return &entity.UpstreamError{
+		Provider: provider,
+		Kind:     entity.ErrUpstreamBadPayload,
+		Err:      err,
+	}
+}
+
+// notFoundError reports a resource the provider does not know
+func notFoundError(provider, resource string) error {
+	return &entity.UpstreamError{
+		Provider: provider,
+		Kind:     entity.ErrUpstreamNotFound,
+		Err:      fmt.Errorf("%s not found", resource),
+	}
+}
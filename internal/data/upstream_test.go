@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"testing"
+)
+
+func TestUpstreamSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracing.NewTracerProvider(resource.Empty(), recorder))
+	defer otel.SetTracerProvider(previous)
+
+	cepServer := createMockServer(cepDTO{Localidade: "São Paulo"})
+	defer cepServer.Close()
+	weatherServer := createMockWeatherServer(weatherDTO{Current: &weatherCurrentDTO{TempC: floatPtr(25), TempF: floatPtr(77)}})
+	defer weatherServer.Close()
+
+	cepStore := &ViaCEPStore{targetEndpoint: cepServer.URL + "/ws/%s/json"}
+	weatherStore := &WeatherApiRepository{keys: testKeyPool(), targetEndpoint: weatherServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no"}
+
+	_, err := cepStore.GetCEP(context.Background(), "12345678")
+	require.NoError(t, err)
+	_, err = cepStore.GetCEP(context.Background(), "87654321")
+	require.Error(t, err)
+	_, err = weatherStore.GetWeatherInfo(context.Background(), &entity.CEP{Localidade: "São Paulo"})
+	require.NoError(t, err)
+
+	type testRow struct {
+		name     string
+		expected map[string]string
+	}
+
+	testTable := []testRow{
+		{name: "ViaCEPStore.GetCEP", expected: map[string]string{"upstream.provider": "viacep", "upstream.outcome": "ok", "cep.hash": tracing.HashCEP("12345678")}},
+		{name: "ViaCEPStore.GetCEP", expected: map[string]string{"upstream.provider": "viacep", "upstream.outcome": "not_found", "cep.hash": tracing.HashCEP("87654321")}},
+		{name: "WeatherApiRepository.GetWeatherInfo", expected: map[string]string{"upstream.provider": "weatherapi", "upstream.outcome": "ok", "upstream.key": Fingerprint("test-api-key")}},
+	}
+
+	var spans []map[string]string
+	var urls []string
+	for _, span := range recorder.Ended() {
+		attrs := map[string]string{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if span.SpanKind() == trace.SpanKindClient {
+			urls = append(urls, attrs["url.full"])
+			continue
+		}
+		attrs["name"] = span.Name()
+		spans = append(spans, attrs)
+	}
+
+	require.Len(t, spans, len(testTable))
+	for i, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.name, spans[i]["name"])
+			for key, value := range tr.expected {
+				assert.Equal(t, value, spans[i][key], key)
+			}
+		})
+	}
+
+	// The outbound requests record their URL without the postal code or the API key
+	assert.Equal(t, []string{
+		cepServer.URL + "/ws/{cep}/json",
+		cepServer.URL + "/ws/{cep}/json",
+		weatherServer.URL + "/v1/current.json?key=REDACTED&q=S%C3%A3o+Paulo&aqi=no",
+	}, urls)
+}
+
+func TestStatusError(t *testing.T) {
+	type testRow struct {
+		statusCode int
+		expected   error
+	}
+
+	testTable := []testRow{
+		{statusCode: http.StatusBadRequest, expected: entity.ErrUpstreamBadPayload},
+		{statusCode: http.StatusUnauthorized, expected: entity.ErrUpstreamUnavailable},
+		{statusCode: http.StatusForbidden, expected: entity.ErrUpstreamUnavailable},
+		{statusCode: http.StatusNotFound, expected: entity.ErrUpstreamNotFound},
+		{statusCode: http.StatusTooManyRequests, expected: entity.ErrUpstreamRateLimited},
+		{statusCode: http.StatusInternalServerError, expected: entity.ErrUpstreamUnavailable},
+		{statusCode: http.StatusGatewayTimeout, expected: entity.ErrUpstreamTimeout},
+	}
+
+	for _, tr := range testTable {
+		t.Run(http.StatusText(tr.statusCode), func(t *testing.T) {
+			err := statusError("fake", tr.statusCode)
+			assert.ErrorIs(t, err, tr.expected)
+
+			var ue *entity.UpstreamError
+			require.ErrorAs(t, err, &ue)
+			assert.Equal(t, tr.statusCode, ue.StatusCode)
+		})
+	}
+}
@@ -3,47 +3,157 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/redact"
+	"github.com/caricciy/go-weather/internal/tracing"
 	"io"
 	"net/http"
 	url2 "net/url"
+	"time"
 )
 
+// weatherDTO is a data transfer object (DTO) for the WeatherAPI current conditions response.
+// Readings are pointers so a missing field can be told apart from a genuine zero reading.
 type weatherDTO struct {
 	Location struct {
 		Name string `json:"name"`
 	} `json:"location"`
-	Current struct {
-		TempC float64 `json:"temp_c"`
-		TempF float64 `json:"temp_f"`
-	} `json:"current"`
+	Current *weatherCurrentDTO `json:"current"`
 }
 
+type weatherCurrentDTO struct {
+	TempC      *float64 `json:"temp_c"`
+	TempF      *float64 `json:"temp_f"`
+	WindKph    *float64 `json:"wind_kph"`
+	PressureMb *float64 `json:"pressure_mb"`
+	PrecipMm   *float64 `json:"precip_mm"`
+	Condition  struct {
+		Text string `json:"text"`
+	} `json:"condition"`
+	// LastUpdatedEpoch is the Unix time the conditions were observed at
+	LastUpdatedEpoch int64 `json:"last_updated_epoch"`
+}
+
+// weatherApiLanguages maps the application languages to the WeatherAPI lang parameter.
+// English is the WeatherAPI default and needs no parameter.
+var weatherApiLanguages = map[i18n.Language]string{
+	i18n.BrazilianPortuguese: "pt",
+}
+
+// weatherErrorDTO is the body WeatherAPI sends along with 4xx status codes
+type weatherErrorDTO struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// WeatherAPI error codes, see https://www.weatherapi.com/docs/#intro-error-codes
+const (
+	weatherApiNoLocationFound = 1006
+	weatherApiQuotaExceeded   = 2007
+)
+
+var ErrIncompleteWeatherPayload = errors.New("incomplete weather payload")
+
 type WeatherApiRepository struct {
-	apiKey         string
-	targetEndpoint string
+	keys             *APIKeyPool
+	targetEndpoint   string
+	forecastEndpoint string
 }
 
-// NewWeatherApiStore creates a new instance of WeatherApiRepository
-func NewWeatherApiStore(apiKey string) *WeatherApiRepository {
+// DefaultWeatherApiEndpoint is the base URL of the WeatherAPI
+const DefaultWeatherApiEndpoint = "https://api.weatherapi.com"
+
+// NewWeatherApiStore creates a new instance of WeatherApiRepository calling the WeatherAPI at endpoint with the keys of the pool
+func NewWeatherApiStore(endpoint string, keys *APIKeyPool) *WeatherApiRepository {
 	return &WeatherApiRepository{
-		apiKey:         apiKey,
-		targetEndpoint: "https://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no",
+		keys:             keys,
+		targetEndpoint:   baseURL(endpoint) + "/v1/current.json?key=%s&q=%s&aqi=no",
+		forecastEndpoint: baseURL(endpoint) + "/v1/forecast.json?key=%s&q=%s&aqi=no&alerts=no",
 	}
 }
 
+// GetWeatherInfo gets the current conditions of the city of cep.
+// A call whose key is rejected by WeatherAPI is retried with another key of the pool, until every key is quarantined.
 func (w *WeatherApiRepository) GetWeatherInfo(ctx context.Context, cep *entity.CEP) (*entity.WeatherInfo, error) {
-	escapedLocation := url2.QueryEscape(cep.Localidade)
-	url := fmt.Sprintf(w.targetEndpoint, w.apiKey, escapedLocation)
+	return withKey(ctx, w.keys, func(key *apiKey) (*entity.WeatherInfo, error) {
+		return w.getWeatherInfo(ctx, key, weatherApiLocation(cep))
+	})
+}
+
+// weatherApiLocation is the q parameter of the city of cep
+func weatherApiLocation(cep *entity.CEP) string {
+	if cep.Country == "" {
+		return cep.Localidade
+	}
+	// Qualify the city with its country so homonymous cities abroad are not mixed up
+	return fmt.Sprintf("%s,%s", cep.Localidade, cep.Country)
+}
+
+func (w *WeatherApiRepository) getWeatherInfo(ctx context.Context, key *apiKey, location string) (*entity.WeatherInfo, error) {
+	var weatherData weatherDTO
+	err := w.get(ctx, key, "WeatherApiRepository.GetWeatherInfo", w.targetEndpoint, location, "", func(body io.Reader) error {
+		if err := json.NewDecoder(body).Decode(&weatherData); err != nil {
+			return payloadError(providerWeatherApi, fmt.Errorf("failed to decode response: %w", err))
+		}
 
+		current := weatherData.Current
+		if current == nil {
+			return payloadError(providerWeatherApi, fmt.Errorf("%w: missing current conditions", ErrIncompleteWeatherPayload))
+		}
+		if current.TempC == nil || current.TempF == nil {
+			return payloadError(providerWeatherApi, fmt.Errorf("%w: missing temperature", ErrIncompleteWeatherPayload))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	current := weatherData.Current
+	var observedAt time.Time
+	if current.LastUpdatedEpoch > 0 {
+		observedAt = time.Unix(current.LastUpdatedEpoch, 0)
+	}
+
+	return &entity.WeatherInfo{
+		Celcius:    *current.TempC,
+		Fahrenheit: *current.TempF,
+		WindKph:    current.WindKph,
+		PressureMb: current.PressureMb,
+		PrecipMm:   current.PrecipMm,
+		Condition:  current.Condition.Text,
+		ObservedAt: observedAt,
+		Valid:      true,
+	}, nil
+}
+
+// get calls an endpoint of WeatherAPI for location with key, appending query to its parameters.
+// decode reads and checks the body of a successful response, its error fails the upstream call like a transport error.
+func (w *WeatherApiRepository) get(ctx context.Context, key *apiKey, operation, endpoint, location, query string, decode func(body io.Reader) error) (err error) {
+	escapedLocation := url2.QueryEscape(location)
+	url := fmt.Sprintf(endpoint, key.value, escapedLocation) + query
+	redactedURL := fmt.Sprintf(endpoint, redact.Placeholder, escapedLocation) + query
+	if lang, ok := weatherApiLanguages[i18n.FromContext(ctx)]; ok {
+		url += "&lang=" + lang
+		redactedURL += "&lang=" + lang
+	}
+
+	// The errors of the request quote its URL, which holds the key
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", redact.Error(err, key.value))
 	}
-	resp, err := http.DefaultClient.Do(req)
+	req, done := startUpstream(req, providerWeatherApi, operation, redactedURL, tracing.KeyAPIKey.String(key.fingerprint))
+	defer func() { done(err) }()
+
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return transportError(providerWeatherApi, redact.Error(err, key.value))
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -51,17 +161,31 @@ func (w *WeatherApiRepository) GetWeatherInfo(ctx context.Context, cep *entity.C
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to fetch Weather: received status code %d", resp.StatusCode)
+		return weatherApiStatusError(resp)
 	}
+	return decode(resp.Body)
+}
 
-	var weatherData weatherDTO
-	if err := json.NewDecoder(resp.Body).Decode(&weatherData); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// weatherApiStatusError classifies an error response using the WeatherAPI error code when there is one
+func weatherApiStatusError(resp *http.Response) error {
+	var errData weatherErrorDTO
+	if err := json.NewDecoder(resp.Body).Decode(&errData); err == nil {
+		switch errData.Error.Code {
+		case weatherApiNoLocationFound:
+			return &entity.UpstreamError{
+				Provider:   providerWeatherApi,
+				Kind:       entity.ErrUpstreamNotFound,
+				StatusCode: resp.StatusCode,
+				Err:        errors.New(errData.Error.Message),
+			}
+		case weatherApiQuotaExceeded:
+			return &entity.UpstreamError{
+				Provider:   providerWeatherApi,
+				Kind:       entity.ErrUpstreamRateLimited,
+				StatusCode: resp.StatusCode,
+				Err:        errors.New(errData.Error.Message),
+			}
+		}
 	}
-
-	
-	return &entity.WeatherInfo{
-		Celcius:    weatherData.Current.TempC,
-		Fahrenheit: weatherData.Current.TempF,
-	}, nil
+	return statusError(providerWeatherApi, resp.StatusCode)
 }
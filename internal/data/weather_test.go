@@ -1,12 +1,18 @@
 package data
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/logging"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"testing"
 	"time"
@@ -45,10 +51,15 @@ func TestGetWeatherInfo(t *testing.T) {
 		Location: struct {
 			Name string `json:"name"`
 		}{Name: "São Paulo"},
-		Current: struct {
-			TempC float64 `json:"temp_c"`
-			TempF float64 `json:"temp_f"`
-		}{TempC: 25.0, TempF: 77.0},
+		Current: &weatherCurrentDTO{
+			TempC:      floatPtr(25.0),
+			TempF:      floatPtr(77.0),
+			WindKph:    floatPtr(11.2),
+			PressureMb: floatPtr(1015),
+			PrecipMm:   floatPtr(0.3),
+
+			LastUpdatedEpoch: 1768478400,
+		},
 	}
 
 	mockServer := createMockWeatherServer(mockWeather)
@@ -56,7 +67,7 @@ func TestGetWeatherInfo(t *testing.T) {
 
 	// Replace the target endpoint with the mock server URL
 	store := &WeatherApiRepository{
-		apiKey:         "test-api-key",
+		keys:           testKeyPool(),
 		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
 	}
 
@@ -67,6 +78,11 @@ func TestGetWeatherInfo(t *testing.T) {
 		assert.NotNil(t, weather)
 		assert.Equal(t, 25.0, weather.Celcius)
 		assert.Equal(t, 77.0, weather.Fahrenheit)
+		assert.Equal(t, floatPtr(11.2), weather.WindKph)
+		assert.Equal(t, floatPtr(1015), weather.PressureMb)
+		assert.Equal(t, floatPtr(0.3), weather.PrecipMm)
+		assert.Equal(t, time.Unix(1768478400, 0), weather.ObservedAt)
+		assert.True(t, weather.Valid)
 	})
 
 	t.Run("Invalid Weather Info", func(t *testing.T) {
@@ -84,7 +100,7 @@ func TestGetWeatherInfo_Timeout(t *testing.T) {
 
 	// Replace the target endpoint with the mock server URL
 	store := &WeatherApiRepository{
-		apiKey:         "test-api-key",
+		keys:           testKeyPool(),
 		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
 	}
 
@@ -96,7 +112,7 @@ func TestGetWeatherInfo_Timeout(t *testing.T) {
 	weather, err := store.GetWeatherInfo(ctx, cep)
 
 	// Assert that a timeout error occurred
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, entity.ErrUpstreamTimeout)
 	assert.Nil(t, weather)
 }
 
@@ -105,16 +121,207 @@ func TestGetWeatherInfo_InvalidResponse(t *testing.T) {
 	defer mockServer.Close()
 
 	store := &WeatherApiRepository{
-		apiKey:         "test-api-key",
+		keys:           testKeyPool(),
+		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
+	}
+
+	cep := &entity.CEP{Localidade: "São Paulo"}
+	weather, err := store.GetWeatherInfo(context.Background(), cep)
+	assert.ErrorIs(t, err, ErrIncompleteWeatherPayload)
+	assert.ErrorIs(t, err, entity.ErrUpstreamBadPayload)
+	assert.Nil(t, weather)
+}
+
+func TestGetWeatherInfo_PartialResponse(t *testing.T) {
+	mockServer := createMockWeatherServer(map[string]any{"current": map[string]float64{"temp_f": 50}})
+	defer mockServer.Close()
+
+	store := &WeatherApiRepository{
+		keys:           testKeyPool(),
 		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
 	}
 
 	cep := &entity.CEP{Localidade: "São Paulo"}
 	weather, err := store.GetWeatherInfo(context.Background(), cep)
+	assert.ErrorIs(t, err, ErrIncompleteWeatherPayload)
+	assert.Nil(t, weather)
+}
+
+func TestGetWeatherInfo_ZeroCelsius(t *testing.T) {
+	mockServer := createMockWeatherServer(map[string]any{"current": map[string]float64{"temp_c": 0, "temp_f": 32}})
+	defer mockServer.Close()
+
+	store := &WeatherApiRepository{
+		keys:           testKeyPool(),
+		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
+	}
+
+	cep := &entity.CEP{Localidade: "Urubici"}
+	weather, err := store.GetWeatherInfo(context.Background(), cep)
 	assert.NoError(t, err)
-	assert.NotNil(t, weather)
+	assert.True(t, weather.Valid)
+	assert.Equal(t, 0.0, weather.Celcius)
+	assert.Equal(t, 32.0, weather.Fahrenheit)
+}
+
+func TestGetWeatherInfo_WithoutWind(t *testing.T) {
+	mockServer := createMockWeatherServer(map[string]any{"current": map[string]float64{"temp_c": 21, "temp_f": 69.8, "pressure_mb": 1012, "precip_mm": 0}})
+	defer mockServer.Close()
 
-	assert.Empty(t, weather.Fahrenheit)
-	assert.Empty(t, weather.Celcius)
-	assert.Empty(t, weather.Kelvin)
+	store := &WeatherApiRepository{
+		keys:           testKeyPool(),
+		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
+	}
+
+	cep := &entity.CEP{Localidade: "São Paulo"}
+	weather, err := store.GetWeatherInfo(context.Background(), cep)
+	assert.NoError(t, err)
+	assert.True(t, weather.Valid)
+	// A missing reading stays missing instead of becoming a calm 0 km/h
+	assert.Nil(t, weather.WindKph)
+	assert.Equal(t, floatPtr(1012), weather.PressureMb)
+	assert.Equal(t, floatPtr(0), weather.PrecipMm)
+}
+
+// testKeyPool creates a pool of a single key
+func testKeyPool() *APIKeyPool {
+	return NewWeatherApiKeyPool([]string{"test-api-key"}, KeyStrategyRoundRobin, DefaultKeyCooldown)
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestGetWeatherInfo_LocalizedCondition(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		text := "Sunny"
+		if r.URL.Query().Get("lang") == "pt" {
+			text = "Sol"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"current": map[string]any{"temp_c": 25, "temp_f": 77, "condition": map[string]string{"text": text}},
+		})
+	}))
+	defer mockServer.Close()
+
+	store := &WeatherApiRepository{
+		keys:           testKeyPool(),
+		targetEndpoint: mockServer.URL + "/v1/current.json?key=%s&q=%s&aqi=no",
+	}
+	cep := &entity.CEP{Localidade: "São Paulo"}
+
+	t.Run("Default language", func(t *testing.T) {
+		weather, err := store.GetWeatherInfo(context.Background(), cep)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sunny", weather.Condition)
+	})
+
+	t.Run("Brazilian Portuguese", func(t *testing.T) {
+		ctx := i18n.WithLanguage(context.Background(), i18n.BrazilianPortuguese)
+		weather, err := store.GetWeatherInfo(ctx, cep)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sol", weather.Condition)
+	})
+}
+
+func TestGetWeatherInfo_ErrorCodes(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "Nowhere":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":1006,"message":"No matching location found."}}`))
+		case "Quota":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":2007,"message":"API key has exceeded calls per month quota."}}`))
+		case "Limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "Revoked":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":2006,"message":"API key is invalid."}}`))
+		case "Disabled":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":2008,"message":"API key has been disabled."}}`))
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer mockServer.Close()
+
+	testTable := []struct {
+		location string
+		expected error
+	}{
+		{"Nowhere", entity.ErrUpstreamNotFound},
+		{"Quota", entity.ErrUpstreamRateLimited},
+		{"Limited", entity.ErrUpstreamRateLimited},
+		{"Revoked", entity.ErrUpstreamUnavailable},
+		{"Disabled", entity.ErrUpstreamUnavailable},
+		{"Down", entity.ErrUpstreamUnavailable},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.location, func(t *testing.T) {
+			// Each location has its own key, as the key answered with a quota error is quarantined
+			store := NewWeatherApiStore(mockServer.URL, testKeyPool())
+			weather, err := store.GetWeatherInfo(context.Background(), &entity.CEP{Localidade: tr.location})
+			assert.ErrorIs(t, err, tr.expected)
+			assert.Nil(t, weather)
+		})
+	}
+}
+
+func TestGetWeatherInfo_NeverDisclosesTheKey(t *testing.T) {
+	const apiKey = "s3cr3t+key/42"
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "Slow":
+			time.Sleep(200 * time.Millisecond)
+		case "Rejected":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":2006,"message":"API key is invalid."}}`))
+		case "Broken":
+			_, _ = w.Write([]byte(`{"current":`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer mockServer.Close()
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	type testRow struct {
+		name     string
+		endpoint string
+		location string
+	}
+
+	testTable := []testRow{
+		{name: "timeout", endpoint: mockServer.URL, location: "Slow"},
+		{name: "unreachable", endpoint: closedServer.URL, location: "Paris"},
+		{name: "invalid endpoint", endpoint: "http://bad host", location: "Paris"},
+		{name: "rejected key", endpoint: mockServer.URL, location: "Rejected"},
+		{name: "broken payload", endpoint: mockServer.URL, location: "Broken"},
+		{name: "server error", endpoint: mockServer.URL, location: "Down"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			// The logs are not scrubbed, the repository must not disclose the key by itself
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			ctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logger), 50*time.Millisecond)
+			defer cancel()
+
+			pool := NewWeatherApiKeyPool([]string{apiKey}, KeyStrategyRoundRobin, DefaultKeyCooldown)
+			store := NewWeatherApiStore(tr.endpoint, pool)
+			_, err := store.GetWeatherInfo(ctx, &entity.CEP{Localidade: tr.location})
+			require.Error(t, err)
+
+			for _, form := range []string{apiKey, url.QueryEscape(apiKey), url.PathEscape(apiKey)} {
+				assert.NotContains(t, err.Error(), form)
+				assert.NotContains(t, logs.String(), form)
+			}
+		})
+	}
 }
@@ -0,0 +1,200 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of the webhook deliveries
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// webhookPayloadDTO is the body of a delivery, the same structure is kept in the dead letters
+type webhookPayloadDTO struct {
+	DeliveryID string             `json:"delivery_id"`
+	Event      string             `json:"event"`
+	WebhookID  string             `json:"webhook_id"`
+	CEP        string             `json:"cep"`
+	Rule       webhookRuleDTO     `json:"rule"`
+	Value      *float64           `json:"value,omitempty"`
+	Weather    *webhookWeatherDTO `json:"weather,omitempty"`
+	Time       time.Time          `json:"time"`
+}
+
+type webhookRuleDTO struct {
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+type webhookWeatherDTO struct {
+	Celcius    float64  `json:"temp_C"`
+	Fahrenheit float64  `json:"temp_F"`
+	Kelvin     float64  `json:"temp_K"`
+	WindKph    *float64 `json:"wind_kph,omitempty"`
+	PressureMb *float64 `json:"pressure_mb,omitempty"`
+	PrecipMm   *float64 `json:"precip_mm,omitempty"`
+	Condition  string   `json:"condition,omitempty"`
+}
+
+func newWebhookPayloadDTO(d *entity.WebhookDelivery) webhookPayloadDTO {
+	payload := webhookPayloadDTO{
+		DeliveryID: d.ID,
+		Event:      d.Event,
+		WebhookID:  d.Webhook.ID,
+		CEP:        d.Webhook.CEP,
+		Rule:       webhookRuleDTO(d.Webhook.Rule),
+		Time:       d.Time.UTC(),
+	}
+	if d.Weather != nil {
+		value := d.Value
+		payload.Value = &value
+		payload.Weather = &webhookWeatherDTO{
+			Celcius:    d.Weather.Celcius,
+			Fahrenheit: d.Weather.Fahrenheit,
+			Kelvin:     d.Weather.Kelvin,
+			WindKph:    d.Weather.WindKph,
+			PressureMb: d.Weather.PressureMb,
+			PrecipMm:   d.Weather.PrecipMm,
+			Condition:  d.Weather.Condition,
+		}
+	}
+	return payload
+}
+
+// errCallbackAddressForbidden is returned when a callback resolves to an address of the server's own network
+var errCallbackAddressForbidden = errors.New("callback address is not public")
+
+type WebhookHttpSender struct {
+	client *http.Client
+}
+
+// NewWebhookHttpSender creates a sender that gives up on a callback after timeout.
+// It only connects to public addresses, so a callback can't reach the loopback, private, link-local or special-purpose networks of the server.
+func NewWebhookHttpSender(timeout time.Duration) *WebhookHttpSender {
+	return newWebhookHttpSender(timeout, publicAddressOnly)
+}
+
+// newWebhookHttpSender creates a sender whose connections are checked by control, once the callback host is resolved
+func newWebhookHttpSender(timeout time.Duration, control func(network, address string, c syscall.RawConn) error) *WebhookHttpSender {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second, Control: control}
+	return &WebhookHttpSender{
+		client: &http.Client{
+			Timeout: timeout,
+			// The callbacks are called directly, a proxy would be the address checked instead of theirs
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// A redirect is answered to the sender, the signed payload is never posted elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts a delivery. Client errors other than 408 and 429 are reported as entity.ErrWebhookRejected.
+func (s *WebhookHttpSender) Send(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	body, err := json.Marshal(newWebhookPayloadDTO(delivery))
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: failed to create request: %w", entity.ErrWebhookRejected, err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-weather-webhooks")
+	req.Header.Set(WebhookIDHeader, delivery.Webhook.ID)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(delivery.Webhook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if errors.Is(err, errCallbackAddressForbidden) {
+		return fmt.Errorf("%w: %w", entity.ErrWebhookRejected, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("webhook callback answered %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: callback answered %d", entity.ErrWebhookRejected, resp.StatusCode)
+	}
+}
+
+// specialPurposePrefixes are the ranges of global unicast addresses that are not reachable on the internet,
+// or reach back into the network of the server, such as the metadata services some clouds run on carrier-grade NAT addresses
+var specialPurposePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // this network
+	netip.MustParsePrefix("100.64.0.0/10"),   // shared address space of carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which reaches any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard-only
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments, Teredo included
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which embeds any IPv4 address
+}
+
+// publicAddressOnly only lets the connections to global unicast addresses through, outside the private and special-purpose ranges.
+// It runs after the DNS resolution, so a host name resolving to such an address is refused as well.
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errCallbackAddressForbidden, address)
+	}
+	addr := addrPort.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || slices.ContainsFunc(specialPurposePrefixes, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+		return fmt.Errorf("%w: %s", errCallbackAddressForbidden, addr)
+	}
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of "timestamp.body" with the webhook secret.
+// Receivers recompute it to check the X-Webhook-Signature header and reject old timestamps to prevent replays.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
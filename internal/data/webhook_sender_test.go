@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDelivery(callbackURL string) *entity.WebhookDelivery {
+	return &entity.WebhookDelivery{
+		ID:    "delivery-1",
+		Event: entity.WebhookEventThresholdCrossed,
+		Webhook: &entity.Webhook{
+			ID:          "webhook-1",
+			CEP:         "20270150",
+			Rule:        entity.WebhookRule{Metric: "temp_C", Operator: "gt", Threshold: 35},
+			CallbackURL: callbackURL,
+			Secret:      "secret",
+		},
+		Value:   36.5,
+		Weather: &entity.WeatherInfo{Celcius: 36.5, Fahrenheit: 97.7, Kelvin: 309.65, Condition: "Sunny", Valid: true},
+		Time:    time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// newTestSender creates a sender allowed to post to the test servers, which listen on the loopback
+func newTestSender() *WebhookHttpSender {
+	return newWebhookHttpSender(time.Second, nil)
+}
+
+func TestWebhookSenderSignsDeliveries(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := newTestSender().Send(context.Background(), newTestDelivery(server.URL))
+	require.NoError(t, err)
+
+	assert.Equal(t, "webhook-1", header.Get(WebhookIDHeader))
+	assert.Equal(t, "delivery-1", header.Get(WebhookDeliveryHeader))
+	timestamp := header.Get(WebhookTimestampHeader)
+	assert.NotEmpty(t, timestamp)
+	assert.Equal(t, "sha256="+SignWebhook("secret", timestamp, body), header.Get(WebhookSignatureHeader))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, entity.WebhookEventThresholdCrossed, payload["event"])
+	assert.Equal(t, "20270150", payload["cep"])
+	assert.Equal(t, 36.5, payload["value"])
+	assert.Equal(t, map[string]any{"metric": "temp_C", "operator": "gt", "threshold": 35.0}, payload["rule"])
+	assert.Equal(t, "Sunny", payload["weather"].(map[string]any)["condition"])
+}
+
+func TestSignWebhook(t *testing.T) {
+	// HMAC-SHA256 of `1700000000.{"a":1}` keyed by "secret"
+	assert.Equal(t, "49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686", SignWebhook("secret", "1700000000", []byte(`{"a":1}`)))
+}
+
+func TestWebhookSenderClassifiesResponses(t *testing.T) {
+	type testRow struct {
+		name         string
+		status       int
+		wantErr      bool
+		wantRejected bool
+	}
+
+	testTable := []testRow{
+		{name: "accepted", status: http.StatusOK},
+		{name: "server error is retried", status: http.StatusServiceUnavailable, wantErr: true},
+		{name: "rate limit is retried", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "timeout is retried", status: http.StatusRequestTimeout, wantErr: true},
+		{name: "client error is rejected", status: http.StatusNotFound, wantErr: true, wantRejected: true},
+		{name: "redirect is rejected", status: http.StatusFound, wantErr: true, wantRejected: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tr.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tr.status)
+			}))
+			defer server.Close()
+
+			err := newTestSender().Send(context.Background(), newTestDelivery(server.URL))
+			if !tr.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Equal(t, tr.wantRejected, errors.Is(err, entity.ErrWebhookRejected))
+		})
+	}
+}
+
+func TestWebhookSenderRefusesInternalCallbacks(t *testing.T) {
+	var called atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	type testRow struct {
+		name        string
+		callbackURL string
+	}
+
+	testTable := []testRow{
+		{name: "loopback", callbackURL: server.URL},
+		{name: "host name of the loopback", callbackURL: "http://localhost:" + port + "/hook"},
+		{name: "cloud metadata", callbackURL: "http://169.254.169.254/latest/meta-data/"},
+		{name: "carrier-grade NAT metadata", callbackURL: "http://100.100.100.200/latest/meta-data/"},
+		{name: "private network", callbackURL: "http://10.0.0.1/hook"},
+		{name: "unspecified", callbackURL: "http://0.0.0.0:" + port + "/hook"},
+		{name: "IPv4-mapped loopback", callbackURL: "http://[::ffff:127.0.0.1]:" + port + "/hook"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			err := NewWebhookHttpSender(time.Second).Send(context.Background(), newTestDelivery(tr.callbackURL))
+			assert.ErrorIs(t, err, errCallbackAddressForbidden)
+			// The callback is not retried
+			assert.ErrorIs(t, err, entity.ErrWebhookRejected)
+		})
+	}
+	assert.False(t, called.Load())
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	type testRow struct {
+		address string
+		allowed bool
+	}
+
+	testTable := []testRow{
+		{address: "93.184.216.34:443", allowed: true},
+		{address: "[2606:2800:220:1::1]:443", allowed: true},
+		{address: "127.0.0.1:80"},
+		{address: "[::1]:80"},
+		{address: "192.168.1.10:80"},
+		{address: "172.16.0.1:80"},
+		{address: "[fd00::1]:80"},
+		{address: "169.254.169.254:80"},
+		{address: "[fe80::1]:80"},
+		{address: "0.0.0.0:80"},
+		{address: "[::]:80"},
+		{address: "0.1.2.3:80"},
+		{address: "100.100.100.200:80"},
+		{address: "198.18.0.1:80"},
+		{address: "240.0.0.1:80"},
+		{address: "255.255.255.255:80"},
+		{address: "224.0.0.1:80"},
+		{address: "[ff02::1]:80"},
+		{address: "[ff0e::1]:80"},
+		{address: "[64:ff9b::a9fe:a9fe]:80"},
+		{address: "[2002:7f00:1::1]:80"},
+		{address: "[2001::1]:80"},
+		{address: "[::ffff:169.254.169.254]:80"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.address, func(t *testing.T) {
+			err := publicAddressOnly("tcp", tr.address, nil)
+			if tr.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, errCallbackAddressForbidden)
+		})
+	}
+}
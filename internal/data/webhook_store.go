@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxDeadLetters bounds the dead letters kept in the file, the oldest are dropped first
+const maxDeadLetters = 1000
+
+// webhookFileDTO is the content of the webhooks file
+type webhookFileDTO struct {
+	Webhooks    []webhookDTO    `json:"webhooks"`
+	DeadLetters []deadLetterDTO `json:"dead_letters"`
+}
+
+type webhookDTO struct {
+	ID          string         `json:"id"`
+	CEP         string         `json:"cep"`
+	Rule        webhookRuleDTO `json:"rule"`
+	CallbackURL string         `json:"callback_url"`
+	Secret      string         `json:"secret"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Firing      bool           `json:"firing"`
+}
+
+type deadLetterDTO struct {
+	CallbackURL string            `json:"callback_url"`
+	Payload     webhookPayloadDTO `json:"payload"`
+	Attempts    int               `json:"attempts"`
+	LastError   string            `json:"last_error"`
+	FailedAt    time.Time         `json:"failed_at"`
+}
+
+// WebhookFileStore keeps the webhooks and the dead letters in a JSON file.
+// Every change rewrites the file, which suits the few hundred webhooks of a deployment.
+type WebhookFileStore struct {
+	path string
+
+	mu          sync.Mutex
+	webhooks    map[string]entity.Webhook
+	deadLetters []deadLetterDTO
+}
+
+// NewWebhookFileStore loads the webhooks of a file, which is created on the first change if it doesn't exist
+func NewWebhookFileStore(path string) (*WebhookFileStore, error) {
+	s := &WebhookFileStore{
+		path:     path,
+		webhooks: make(map[string]entity.Webhook),
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+	}
+
+	var file webhookFileDTO
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode webhooks file %s: %w", path, err)
+	}
+	for _, dto := range file.Webhooks {
+		s.webhooks[dto.ID] = entity.Webhook{
+			ID:          dto.ID,
+			CEP:         dto.CEP,
+			Rule:        entity.WebhookRule(dto.Rule),
+			CallbackURL: dto.CallbackURL,
+			Secret:      dto.Secret,
+			CreatedAt:   dto.CreatedAt,
+			Firing:      dto.Firing,
+		}
+	}
+	s.deadLetters = file.DeadLetters
+	return s, nil
+}
+
+func (s *WebhookFileStore) CreateWebhook(_ context.Context, webhook *entity.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.webhooks[webhook.ID] = *webhook
+	if err := s.save(); err != nil {
+		delete(s.webhooks, webhook.ID)
+		return err
+	}
+	return nil
+}
+
+func (s *WebhookFileStore) GetWebhook(_ context.Context, id string) (*entity.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return nil, entity.ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks returns the webhooks from the oldest to the newest
+func (s *WebhookFileStore) ListWebhooks(_ context.Context) ([]*entity.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhooks := make([]*entity.Webhook, 0, len(s.webhooks))
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, &webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].ID < webhooks[j].ID
+		}
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (s *WebhookFileStore) DeleteWebhook(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return entity.ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	if err := s.save(); err != nil {
+		s.webhooks[id] = webhook
+		return err
+	}
+	return nil
+}
+
+func (s *WebhookFileStore) SetWebhookFiring(_ context.Context, id string, firing bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	webhook, ok := s.webhooks[id]
+	if !ok {
+		return entity.ErrWebhookNotFound
+	}
+	previous := webhook.Firing
+	webhook.Firing = firing
+	s.webhooks[id] = webhook
+	if err := s.save(); err != nil {
+		webhook.Firing = previous
+		s.webhooks[id] = webhook
+		return err
+	}
+	return nil
+}
+
+func (s *WebhookFileStore) AddDeadLetter(_ context.Context, deadLetter *entity.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deadLetters := s.deadLetters
+	s.deadLetters = append(s.deadLetters, deadLetterDTO{
+		CallbackURL: deadLetter.Delivery.Webhook.CallbackURL,
+		Payload:     newWebhookPayloadDTO(deadLetter.Delivery),
+		Attempts:    deadLetter.Attempts,
+		LastError:   deadLetter.LastError,
+		FailedAt:    deadLetter.FailedAt.UTC(),
+	})
+	if len(s.deadLetters) > maxDeadLetters {
+		s.deadLetters = s.deadLetters[len(s.deadLetters)-maxDeadLetters:]
+	}
+	if err := s.save(); err != nil {
+		s.deadLetters = deadLetters
+		return err
+	}
+	return nil
+}
+
+// save writes the file atomically, a crash leaves either the old or the new content.
+// It is only readable by its owner since it holds the webhook secrets.
+func (s *WebhookFileStore) save() error {
+	file := webhookFileDTO{
+		Webhooks:    make([]webhookDTO, 0, len(s.webhooks)),
+		DeadLetters: s.deadLetters,
+	}
+	for _, webhook := range s.webhooks {
+		file.Webhooks = append(file.Webhooks, webhookDTO{
+			ID:          webhook.ID,
+			CEP:         webhook.CEP,
+			Rule:        webhookRuleDTO(webhook.Rule),
+			CallbackURL: webhook.CallbackURL,
+			Secret:      webhook.Secret,
+			CreatedAt:   webhook.CreatedAt.UTC(),
+			Firing:      webhook.Firing,
+		})
+	}
+	sort.Slice(file.Webhooks, func(i, j int) bool { return file.Webhooks[i].ID < file.Webhooks[j].ID })
+	if file.DeadLetters == nil {
+		file.DeadLetters = []deadLetterDTO{}
+	}
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode webhooks file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write webhooks file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write webhooks file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write webhooks file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write webhooks file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write webhooks file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebhookFileStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+
+	store, err := NewWebhookFileStore(path)
+	require.NoError(t, err)
+
+	first := &entity.Webhook{ID: "a", CEP: "20270150", Rule: entity.WebhookRule{Metric: "temp_C", Operator: "gt", Threshold: 35}, CallbackURL: "https://example.com/a", Secret: "s1", CreatedAt: time.Unix(100, 0)}
+	second := &entity.Webhook{ID: "b", CEP: "01001000", Rule: entity.WebhookRule{Metric: "temp_C", Operator: "lt", Threshold: 5}, CallbackURL: "https://example.com/b", Secret: "s2", CreatedAt: time.Unix(200, 0)}
+	require.NoError(t, store.CreateWebhook(ctx, second))
+	require.NoError(t, store.CreateWebhook(ctx, first))
+	require.NoError(t, store.SetWebhookFiring(ctx, "a", true))
+	require.NoError(t, store.AddDeadLetter(ctx, &entity.DeadLetter{Delivery: newTestDelivery("https://example.com/a"), Attempts: 5, LastError: "boom", FailedAt: time.Unix(300, 0)}))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	reloaded, err := NewWebhookFileStore(path)
+	require.NoError(t, err)
+
+	webhooks, err := reloaded.ListWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	assert.Equal(t, "a", webhooks[0].ID)
+	assert.True(t, webhooks[0].Firing)
+	assert.Equal(t, "s1", webhooks[0].Secret)
+	assert.Equal(t, first.Rule, webhooks[0].Rule)
+	assert.Equal(t, "b", webhooks[1].ID)
+	assert.False(t, webhooks[1].Firing)
+
+	var file webhookFileDTO
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &file))
+	require.Len(t, file.DeadLetters, 1)
+	assert.Equal(t, "delivery-1", file.DeadLetters[0].Payload.DeliveryID)
+	assert.Equal(t, 5, file.DeadLetters[0].Attempts)
+	assert.Equal(t, "boom", file.DeadLetters[0].LastError)
+}
+
+func TestWebhookFileStoreNotFound(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewWebhookFileStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err)
+
+	_, err = store.GetWebhook(ctx, "missing")
+	assert.ErrorIs(t, err, entity.ErrWebhookNotFound)
+	assert.ErrorIs(t, store.DeleteWebhook(ctx, "missing"), entity.ErrWebhookNotFound)
+	assert.ErrorIs(t, store.SetWebhookFiring(ctx, "missing", true), entity.ErrWebhookNotFound)
+
+	require.NoError(t, store.CreateWebhook(ctx, &entity.Webhook{ID: "a"}))
+	require.NoError(t, store.DeleteWebhook(ctx, "a"))
+	_, err = store.GetWebhook(ctx, "a")
+	assert.ErrorIs(t, err, entity.ErrWebhookNotFound)
+}
+
+func TestWebhookFileStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	_, err := NewWebhookFileStore(path)
+	assert.Error(t, err)
+}
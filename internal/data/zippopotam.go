@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// zippopotamDTO is a data transfer object (DTO) for the Zippopotam postal code response.
+type zippopotamDTO struct {
+	PostCode            string `json:"post code"`
+	Country             string `json:"country"`
+	CountryAbbreviation string `json:"country abbreviation"`
+	Places              []struct {
+		PlaceName string `json:"place name"`
+		State     string `json:"state"`
+		Latitude  string `json:"latitude"`
+		Longitude string `json:"longitude"`
+	} `json:"places"`
+}
+
+type ZippopotamStore struct {
+	country        string
+	targetEndpoint string
+}
+
+// DefaultZippopotamEndpoint is the base URL of the Zippopotam.us API
+const DefaultZippopotamEndpoint = "https://api.zippopotam.us"
+
+// NewZippopotamStore creates a new instance of ZippopotamStore for the given country (ISO 3166-1 alpha-2),
+// calling the Zippopotam.us API at endpoint
+func NewZippopotamStore(endpoint, country string) *ZippopotamStore {
+	return &ZippopotamStore{
+		country:        strings.ToUpper(country),
+		targetEndpoint: baseURL(endpoint) + "/%s/%s",
+	}
+}
+
+// GetCEP retrieves the location of a postal code of the store's country
+func (s *ZippopotamStore) GetCEP(ctx context.Context, postalCode string) (_ *entity.CEP, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(s.targetEndpoint, s.country, postalCode), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req, done := startUpstream(req, providerZippopotam, "ZippopotamStore.GetCEP", fmt.Sprintf(s.targetEndpoint, s.country, "{postalcode}"),
+		tracing.CEP(postalCode), tracing.KeyCountry.String(s.country))
+	defer func() { done(err) }()
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, transportError(providerZippopotam, err)
+	}
+
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	// Zippopotam answers unknown postal codes with 404 and an empty body, which statusError reports as not found
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(providerZippopotam, resp.StatusCode)
+	}
+
+	var postalCodeData zippopotamDTO
+	if err := json.NewDecoder(resp.Body).Decode(&postalCodeData); err != nil {
+		return nil, payloadError(providerZippopotam, fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	if len(postalCodeData.Places) == 0 {
+		return nil, notFoundError(providerZippopotam, "postal code")
+	}
+
+	return &entity.CEP{
+		Localidade: postalCodeData.Places[0].PlaceName,
+		Uf:         postalCodeData.Places[0].State,
+		Country:    s.country,
+	}, nil
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// createMockZippopotamServer creates a mock HTTP server that simulates the Zippopotam API response.
+func createMockZippopotamServer(mockResponse any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/PT/1000-001":
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(mockResponse)
+		case "/PT/5000-000":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			// Zippopotam returns an empty object for unknown postal codes
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("{}"))
+		}
+	}))
+}
+
+func TestZippopotamGetCEP(t *testing.T) {
+	mockPostalCode := map[string]any{
+		"post code":            "1000-001",
+		"country":              "Portugal",
+		"country abbreviation": "PT",
+		"places": []map[string]string{
+			{"place name": "Lisboa", "state": "Lisboa", "latitude": "38.7167", "longitude": "-9.1333"},
+		},
+	}
+
+	mockServer := createMockZippopotamServer(mockPostalCode)
+	defer mockServer.Close()
+
+	store := &ZippopotamStore{country: "PT", targetEndpoint: mockServer.URL + "/%s/%s"}
+
+	t.Run("Valid postal code", func(t *testing.T) {
+		cep, err := store.GetCEP(context.Background(), "1000-001")
+		assert.NoError(t, err)
+		assert.NotNil(t, cep)
+		assert.Equal(t, "Lisboa", cep.Localidade)
+		assert.Equal(t, "PT", cep.Country)
+	})
+
+	t.Run("Unknown postal code", func(t *testing.T) {
+		cep, err := store.GetCEP(context.Background(), "9999-999")
+		assert.ErrorIs(t, err, entity.ErrUpstreamNotFound)
+		assert.Nil(t, cep)
+	})
+
+	t.Run("Upstream error", func(t *testing.T) {
+		cep, err := store.GetCEP(context.Background(), "5000-000")
+		assert.ErrorIs(t, err, entity.ErrUpstreamUnavailable)
+		assert.Nil(t, cep)
+	})
+}
@@ -0,0 +1,141 @@
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed index.html
+var ui []byte
+
+// openAPISchema is the subset of an OpenAPI schema object used to check the spec against the code
+type openAPISchema struct {
+	Properties map[string]json.RawMessage `json:"properties"`
+	Enum       []string                   `json:"enum"`
+}
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// HandleSpec serves the OpenAPI document
+func HandleSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(spec)
+}
+
+// HandleUI serves the interactive documentation page, which renders the OpenAPI document
+func HandleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(ui)
+}
+
+// Spec returns the OpenAPI document
+func Spec() []byte {
+	return spec
+}
+
+func document() (*openAPIDocument, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode openapi document: %w", err)
+	}
+	return &doc, nil
+}
+
+// SchemaDrift compares the JSON fields of the struct v with the properties of a schema of the document.
+// It returns one message per field present on only one side, or nil when both agree.
+func SchemaDrift(schema string, v any) ([]string, error) {
+	doc, err := document()
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := doc.Components.Schemas[schema]
+	if !ok {
+		return nil, fmt.Errorf("schema %s not found", schema)
+	}
+
+	fields := jsonFields(reflect.TypeOf(v))
+
+	var drift []string
+	for name := range fields {
+		if _, ok := s.Properties[name]; !ok {
+			drift = append(drift, fmt.Sprintf("field %s is missing from schema %s", name, schema))
+		}
+	}
+	for name := range s.Properties {
+		if _, ok := fields[name]; !ok {
+			drift = append(drift, fmt.Sprintf("property %s of schema %s is not sent by the code", name, schema))
+		}
+	}
+
+	sort.Strings(drift)
+	return drift, nil
+}
+
+// SchemaEnum returns the enum values of a schema of the document
+func SchemaEnum(schema string) ([]string, error) {
+	doc, err := document()
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := doc.Components.Schemas[schema]
+	if !ok {
+		return nil, fmt.Errorf("schema %s not found", schema)
+	}
+	return s.Enum, nil
+}
+
+// Operations returns the "METHOD /path" of every operation of the document
+func Operations() ([]string, error) {
+	doc, err := document()
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []string
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(operations)
+	return operations, nil
+}
+
+// jsonFields returns the names of the JSON fields of a struct type
+func jsonFields(t reflect.Type) map[string]struct{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	fields := map[string]struct{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = struct{}{}
+	}
+	return fields
+}
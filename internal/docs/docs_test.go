@@ -0,0 +1,54 @@
+package docs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSchemaDrift(t *testing.T) {
+	type location struct {
+		City    string `json:"city"`
+		State   string `json:"state,omitempty"`
+		Country string `json:"country"`
+	}
+	type driftedLocation struct {
+		City     string `json:"city"`
+		Province string `json:"province"`
+		internal string
+	}
+
+	t.Run("Matching struct", func(t *testing.T) {
+		drift, err := SchemaDrift("Location", location{})
+		assert.NoError(t, err)
+		assert.Empty(t, drift)
+	})
+
+	t.Run("Drifted struct", func(t *testing.T) {
+		drift, err := SchemaDrift("Location", &driftedLocation{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"field province is missing from schema Location",
+			"property country of schema Location is not sent by the code",
+			"property state of schema Location is not sent by the code",
+		}, drift)
+	})
+
+	t.Run("Unknown schema", func(t *testing.T) {
+		_, err := SchemaDrift("Unknown", location{})
+		assert.Error(t, err)
+	})
+}
+
+func TestHandlers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleSpec(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, string(Spec()), rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	HandleUI(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "/openapi.json")
+}
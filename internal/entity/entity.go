@@ -1,6 +1,9 @@
 package entity
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type CEPRepository interface {
 	GetCEP(ctx context.Context, cep string) (*CEP, error)
@@ -10,12 +13,58 @@ type WeatherRepository interface {
 	GetWeatherInfo(ctx context.Context, cep *CEP) (*WeatherInfo, error)
 }
 
+// ForecastRepository provides the daily forecast of a location
+type ForecastRepository interface {
+	// GetForecast returns the forecast of the next days, starting today. Providers may return fewer days than asked.
+	GetForecast(ctx context.Context, cep *CEP, days int) ([]ForecastDay, error)
+}
+
 type CEP struct {
 	Localidade string
+	// Uf is the state (or region) of the location
+	Uf string
+	// Country is the ISO 3166-1 alpha-2 code of the location. It is empty for Brazilian CEPs.
+	Country string
 }
 
 type WeatherInfo struct {
 	Celcius    float64
 	Fahrenheit float64
 	Kelvin     float64
+	// WindKph, PressureMb and PrecipMm are nil when the provider did not report them
+	WindKph    *float64
+	PressureMb *float64
+	PrecipMm   *float64
+	// Condition is the description of the current conditions, localized when the provider supports it
+	Condition string
+	// ObservedAt is when the provider observed the conditions, it is zero when unknown
+	ObservedAt time.Time
+	// Valid reports whether the readings were present in the source.
+	// Zero readings of a valid WeatherInfo are genuine (e.g. 0 °C).
+	Valid bool
+}
+
+// ForecastDay is the forecast of a day, in canonical units
+type ForecastDay struct {
+	// Date is the local date of the location, as YYYY-MM-DD
+	Date     string
+	MaxTempC float64
+	MinTempC float64
+	AvgTempC float64
+	// MaxWindKph, TotalPrecipMm and ChanceOfRain are nil when the provider did not report them
+	MaxWindKph    *float64
+	TotalPrecipMm *float64
+	// ChanceOfRain is a percentage
+	ChanceOfRain *float64
+	// Condition is the description of the conditions of the day, localized when the provider supports it
+	Condition string
+}
+
+// WeatherReport is the outcome of a lookup that may succeed partially
+type WeatherReport struct {
+	Location *CEP
+	// Weather is nil when the weather step failed
+	Weather *WeatherInfo
+	// WeatherErr is the failure of the weather step of a partial lookup
+	WeatherErr error
 }
@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of upstream failures reported by the repositories
+var (
+	ErrUpstreamTimeout     = errors.New("upstream timeout")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrUpstreamRateLimited = errors.New("upstream rate limited")
+	ErrUpstreamBadPayload  = errors.New("upstream bad payload")
+	ErrUpstreamNotFound    = errors.New("upstream resource not found")
+)
+
+// UpstreamError is a failure of an external provider.
+// errors.Is matches both its Kind and the root cause in Err.
+type UpstreamError struct {
+	Provider string
+	Kind     error
+	// StatusCode is the HTTP status answered by the provider, zero when no response was received
+	StatusCode int
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s: %v (status %d): %v", e.Provider, e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s: %v: %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
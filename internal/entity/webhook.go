@@ -0,0 +1,72 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWebhookNotFound is returned by a WebhookRepository for an unknown webhook
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrWebhookRejected is returned by a WebhookSender when the callback refused a delivery, so retrying won't help
+var ErrWebhookRejected = errors.New("webhook delivery rejected")
+
+// Events of the webhook deliveries
+const (
+	WebhookEventThresholdCrossed = "threshold.crossed"
+	WebhookEventTest             = "webhook.test"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *Webhook) error
+	GetWebhook(ctx context.Context, id string) (*Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	SetWebhookFiring(ctx context.Context, id string, firing bool) error
+	AddDeadLetter(ctx context.Context, deadLetter *DeadLetter) error
+}
+
+type WebhookSender interface {
+	// Send posts a delivery to the callback of its webhook, signed with the webhook secret
+	Send(ctx context.Context, delivery *WebhookDelivery) error
+}
+
+// Webhook notifies a callback URL when the weather of a CEP crosses a threshold
+type Webhook struct {
+	ID          string
+	CEP         string
+	Rule        WebhookRule
+	CallbackURL string
+	// Secret is the HMAC key of the signatures of the deliveries
+	Secret    string
+	CreatedAt time.Time
+	// Firing reports whether the rule matched on the last evaluation, a delivery is made when it starts matching
+	Firing bool
+}
+
+// WebhookRule compares a measurement with a threshold, e.g. temp_C gt 35
+type WebhookRule struct {
+	Metric    string
+	Operator  string
+	Threshold float64
+}
+
+// WebhookDelivery is an event posted to the callback of a webhook
+type WebhookDelivery struct {
+	ID      string
+	Event   string
+	Webhook *Webhook
+	// Value is the measurement of the rule metric read from Weather. Test deliveries have no weather.
+	Value   float64
+	Weather *WeatherInfo
+	Time    time.Time
+}
+
+// DeadLetter records a delivery that could not be made
+type DeadLetter struct {
+	Delivery  *WebhookDelivery
+	Attempts  int
+	LastError string
+	FailedAt  time.Time
+}
@@ -0,0 +1,88 @@
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/caricciy/go-weather/internal/util"
+	"github.com/graph-gophers/graphql-go"
+	"net/http"
+	"time"
+)
+
+//go:embed schema.graphql
+var schemaSource string
+
+const (
+	// requestTimeout bounds the execution of a query, it is longer than a single REST lookup because a query may hold many
+	requestTimeout = 10 * time.Second
+	// maxRequestSize bounds the body of a POST request, a query of maxCEPs postal codes is far smaller
+	maxRequestSize = 64 << 10
+	// maxQueryDepth bounds the nesting of the fields of a query. The schema has no cycle, only the introspection
+	// queries of the GraphQL tools nest that deep, through the ofType of the wrapped types.
+	maxQueryDepth = 15
+)
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Handler serves GraphQL queries over HTTP
+type Handler struct {
+	schema   *graphql.Schema
+	useCases *usecase.WeatherUseCases
+}
+
+// NewHandler creates the GraphQL handler. Introspection queries are rejected unless introspection is true.
+func NewHandler(useCases *usecase.WeatherUseCases, introspection bool) *Handler {
+	opts := []graphql.SchemaOpt{
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxQueryDepth),
+		// The resolvers of a query wait on the loaders, more of them at once would not fetch more at once
+		graphql.MaxParallelism(maxConcurrentLookups),
+	}
+	if !introspection {
+		opts = append(opts, graphql.DisableIntrospection())
+	}
+
+	return &Handler{
+		schema:   graphql.MustParseSchema(schemaSource, &Resolver{}, opts...),
+		useCases: useCases,
+	}
+}
+
+// ServeHTTP executes a query sent as a JSON body (POST) or as query parameters (GET)
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				util.SendJSON(w, map[string]any{"errors": []map[string]string{{"message": "invalid variables"}}}, http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			util.SendJSON(w, map[string]any{"errors": []map[string]string{{"message": "request body too large"}}}, http.StatusRequestEntityTooLarge)
+			return
+		}
+		util.SendJSON(w, map[string]any{"errors": []map[string]string{{"message": "invalid request body"}}}, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+
+	// Loaders live as long as the request, so lookups are only shared within a query
+	ctx = context.WithValue(ctx, loadersKey{}, newLoaders(ctx, h.useCases))
+
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	util.SendJSON(w, response, http.StatusOK)
+}
@@ -0,0 +1,227 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingCEPRepository resolves the CEPs of its map, answers the others as not found and counts its calls
+type countingCEPRepository struct {
+	ceps  map[string]*entity.CEP
+	calls atomic.Int32
+}
+
+func (f *countingCEPRepository) GetCEP(_ context.Context, cep string) (*entity.CEP, error) {
+	f.calls.Add(1)
+	if c, ok := f.ceps[cep]; ok {
+		return c, nil
+	}
+	return nil, &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")}
+}
+
+// countingWeatherRepository answers 25 °C everywhere and counts its calls
+type countingWeatherRepository struct {
+	calls atomic.Int32
+}
+
+func (f *countingWeatherRepository) GetWeatherInfo(_ context.Context, _ *entity.CEP) (*entity.WeatherInfo, error) {
+	f.calls.Add(1)
+	return &entity.WeatherInfo{Celcius: 25, Fahrenheit: 77, Condition: "Sunny", Valid: true}, nil
+}
+
+// countingForecastRepository forecasts 30/20 °C days and counts its calls
+type countingForecastRepository struct {
+	calls atomic.Int32
+}
+
+func (f *countingForecastRepository) GetForecast(_ context.Context, _ *entity.CEP, days int) ([]entity.ForecastDay, error) {
+	f.calls.Add(1)
+	forecast := make([]entity.ForecastDay, days)
+	for i := range forecast {
+		forecast[i] = entity.ForecastDay{Date: fmt.Sprintf("2026-01-%02d", 15+i), MaxTempC: 30, MinTempC: 20, AvgTempC: 25, Condition: "Sunny"}
+	}
+	return forecast, nil
+}
+
+type graphQLResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newTestHandler(introspection bool) (*Handler, *countingCEPRepository, *countingWeatherRepository) {
+	h, cepRepository, weatherRepository, _ := newForecastTestHandler(introspection)
+	return h, cepRepository, weatherRepository
+}
+
+func newForecastTestHandler(introspection bool) (*Handler, *countingCEPRepository, *countingWeatherRepository, *countingForecastRepository) {
+	cepRepository := &countingCEPRepository{ceps: map[string]*entity.CEP{
+		"01001000": {Localidade: "São Paulo", Uf: "SP"},
+		"01310100": {Localidade: "São Paulo", Uf: "SP"},
+	}}
+	weatherRepository := &countingWeatherRepository{}
+	forecastRepository := &countingForecastRepository{}
+	useCases := usecase.NewWeatherUseCases(cepRepository, weatherRepository)
+	useCases.SetForecastRepository(forecastRepository)
+	return NewHandler(useCases, introspection), cepRepository, weatherRepository, forecastRepository
+}
+
+func execute(t *testing.T, h http.Handler, query string) graphQLResponse {
+	body, _ := json.Marshal(graphQLRequest{Query: query})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp graphQLResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestQueryDeduplicatesLookups(t *testing.T) {
+	h, cepRepository, weatherRepository := newTestHandler(false)
+
+	resp := execute(t, h, `{
+		a: cep(code: "01001000") { address { city state weather { tempC } } weather { tempF } }
+		b: cep(code: "01001000") { code }
+		c: ceps(codes: ["01001000", "01310100"]) { address { city } weather { condition } }
+	}`)
+
+	assert.Empty(t, resp.Errors)
+	address := resp.Data["a"].(map[string]any)["address"].(map[string]any)
+	assert.Equal(t, "São Paulo", address["city"])
+	assert.Equal(t, 25.0, address["weather"].(map[string]any)["tempC"])
+
+	// Two distinct CEPs are resolved once each and share the weather lookup of their city
+	assert.Equal(t, int32(2), cepRepository.calls.Load())
+	assert.Equal(t, int32(1), weatherRepository.calls.Load())
+}
+
+func TestQueryReportsErrorCodes(t *testing.T) {
+	h, _, _ := newTestHandler(false)
+
+	resp := execute(t, h, `{ cep(code: "99999999") { code address { city } } }`)
+
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "CEP_NOT_FOUND", resp.Errors[0].Extensions["code"])
+	assert.Equal(t, "99999999", resp.Data["cep"].(map[string]any)["code"])
+	assert.Nil(t, resp.Data["cep"].(map[string]any)["address"])
+}
+
+func TestQueryForecast(t *testing.T) {
+	h, _, _, forecastRepository := newForecastTestHandler(false)
+
+	resp := execute(t, h, `{
+		a: cep(code: "01001000") { forecast { date maxTempC minTempC maxWindKph condition } }
+		b: cep(code: "01310100") { address { forecast(days: 3) { date } } }
+		c: cep(code: "01001000") { forecast(days: 1) { date } }
+	}`)
+
+	assert.Empty(t, resp.Errors)
+	forecast := resp.Data["a"].(map[string]any)["forecast"].([]any)
+	require.Len(t, forecast, 3)
+	assert.Equal(t, map[string]any{"date": "2026-01-15", "maxTempC": 30.0, "minTempC": 20.0, "maxWindKph": nil, "condition": "Sunny"}, forecast[0])
+	assert.Len(t, resp.Data["c"].(map[string]any)["forecast"], 1)
+
+	// Both CEPs are in the same city, the default forecast is fetched once and the one day forecast once
+	assert.Equal(t, int32(2), forecastRepository.calls.Load())
+}
+
+func TestQueryForecastDays(t *testing.T) {
+	h, _, _, forecastRepository := newForecastTestHandler(false)
+
+	resp := execute(t, h, `{ cep(code: "01001000") { forecast(days: 15) { date } } }`)
+
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "a forecast has from 1 to 14 days", resp.Errors[0].Message)
+	assert.Zero(t, forecastRepository.calls.Load())
+}
+
+func TestQueryLookupBudget(t *testing.T) {
+	h, cepRepository, _ := newTestHandler(false)
+
+	// Aliased ceps fields each stay within maxCEPs, but together they ask for more distinct postal codes than the budget
+	var query strings.Builder
+	query.WriteString("{")
+	for alias := range 3 {
+		codes := make([]string, maxCEPs)
+		for i := range codes {
+			codes[i] = fmt.Sprintf(`"%08d"`, alias*maxCEPs+i)
+		}
+		fmt.Fprintf(&query, " a%d: ceps(codes: [%s]) { address { city } }", alias, strings.Join(codes, ","))
+	}
+	query.WriteString(" }")
+
+	resp := execute(t, h, query.String())
+
+	assert.Equal(t, int32(maxLookups), cepRepository.calls.Load())
+	var budgetErrors int
+	for _, err := range resp.Errors {
+		if err.Message == "a query looks up at most 100 distinct postal codes, locations and forecasts" {
+			budgetErrors++
+		}
+	}
+	assert.Equal(t, 3*maxCEPs-maxLookups, budgetErrors)
+}
+
+func TestQueryDepthIsBounded(t *testing.T) {
+	h, _, _ := newTestHandler(true)
+
+	query := `{ __schema { types { fields { type` + strings.Repeat(" { ofType", maxQueryDepth) + " { name }" + strings.Repeat(" }", maxQueryDepth) + " } } } }"
+	resp := execute(t, h, query)
+
+	require.NotEmpty(t, resp.Errors)
+	assert.Contains(t, resp.Errors[0].Message, "exceeds max depth")
+	assert.Nil(t, resp.Data)
+}
+
+func TestRequestBodyIsBounded(t *testing.T) {
+	h, _, _ := newTestHandler(false)
+
+	body, _ := json.Marshal(graphQLRequest{Query: "{ cep(code: \"01001000\") { code } }" + strings.Repeat(" ", maxRequestSize)})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.JSONEq(t, `{"errors":[{"message":"request body too large"}]}`, rec.Body.String())
+}
+
+func TestIntrospectionToggle(t *testing.T) {
+	const query = `{ __schema { queryType { name } } }`
+
+	disabled, _, _ := newTestHandler(false)
+	assert.Nil(t, execute(t, disabled, query).Data["__schema"])
+
+	enabled, _, _ := newTestHandler(true)
+	assert.NotNil(t, execute(t, enabled, query).Data["__schema"])
+}
+
+func TestGetRequest(t *testing.T) {
+	h, _, _ := newTestHandler(false)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ cep(code: "01001000") { country } }`), nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data":{"cep":{"country":"BR"}}}`, rec.Body.String())
+}
+
+func TestRequestMatchesSpec(t *testing.T) {
+	drift, err := docs.SchemaDrift("GraphQLRequest", graphQLRequest{})
+	assert.NoError(t, err)
+	assert.Empty(t, drift)
+}
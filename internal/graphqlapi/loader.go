@@ -0,0 +1,119 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"sync"
+	"time"
+)
+
+// CacheLoader is the cache of the loaders in the metrics, a key already requested by the GraphQL request is a hit
+const CacheLoader = "graphql_loader"
+
+// errBudgetExceeded is returned by the loads of new keys once a loader holds as many keys as its budget
+var errBudgetExceeded = errors.New("loader budget exceeded")
+
+// loaderResult is the outcome of the lookup of one key
+type loaderResult[V any] struct {
+	value V
+	err   error
+}
+
+type loaderCall[V any] struct {
+	done   chan struct{}
+	result loaderResult[V]
+}
+
+// loader batches and deduplicates the lookups of a single GraphQL request.
+// Keys requested within the batch window are fetched together, and every key is fetched at most once.
+// A loader fetches at most budget distinct keys, so the aliases of a query can't fan out into unbounded lookups.
+type loader[K comparable, V any] struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, keys []K) []loaderResult[V]
+	wait   time.Duration
+	budget int
+
+	mu      sync.Mutex
+	calls   map[K]*loaderCall[V]
+	pending []K
+}
+
+func newLoader[K comparable, V any](ctx context.Context, wait time.Duration, budget int, fetch func(ctx context.Context, keys []K) []loaderResult[V]) *loader[K, V] {
+	return &loader[K, V]{
+		ctx:    ctx,
+		fetch:  fetch,
+		wait:   wait,
+		budget: budget,
+		calls:  map[K]*loaderCall[V]{},
+	}
+}
+
+// Load returns the value of a key, joining the lookup of the key if it is already pending or done
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	call, ok := l.calls[key]
+	if !ok && len(l.calls) >= l.budget {
+		l.mu.Unlock()
+		var zero V
+		return zero, errBudgetExceeded
+	}
+	metrics.CacheLookup(CacheLoader, ok)
+	if !ok {
+		call = &loaderCall[V]{done: make(chan struct{})}
+		l.calls[key] = call
+		l.pending = append(l.pending, key)
+		if len(l.pending) == 1 {
+			time.AfterFunc(l.wait, l.dispatch)
+		}
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result.value, call.result.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// dispatch fetches the pending keys as one batch
+func (l *loader[K, V]) dispatch() {
+	l.mu.Lock()
+	keys := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	results := l.fetch(l.ctx, keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, key := range keys {
+		call := l.calls[key]
+		call.result = results[i]
+		close(call.done)
+	}
+}
+
+// fetchConcurrently runs fetchOne for every key, at most limit at a time, keeping the results in the order of the keys.
+// It is the batch function of loaders whose repositories have no batch endpoint.
+func fetchConcurrently[K comparable, V any](ctx context.Context, keys []K, limit int, fetchOne func(ctx context.Context, key K) (V, error)) []loaderResult[V] {
+	results := make([]loaderResult[V], len(keys))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			value, err := fetchOne(ctx, key)
+			results[i] = loaderResult[V]{value: value, err: err}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
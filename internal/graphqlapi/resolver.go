@@ -0,0 +1,259 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"strings"
+	"time"
+)
+
+const (
+	// maxCEPs is the maximum number of postal codes of a ceps query
+	maxCEPs = 100
+	// maxLookups is the budget of the loaders of a request: the distinct postal codes, locations and forecasts it looks up,
+	// whatever the number of aliased fields asking for them
+	maxLookups = maxCEPs
+	// maxConcurrentLookups bounds the upstream lookups running at once for a request
+	maxConcurrentLookups = 8
+	// batchWindow is how long loaders wait to gather the keys of a batch
+	batchWindow = 2 * time.Millisecond
+)
+
+type loadersKey struct{}
+
+// postalCodeKey identifies a postal code of a country
+type postalCodeKey struct {
+	country string
+	code    string
+}
+
+// forecastKey identifies the forecast of some days at a location
+type forecastKey struct {
+	location entity.CEP
+	days     int
+}
+
+// loaders holds the loaders of a request
+type loaders struct {
+	locations *loader[postalCodeKey, *entity.CEP]
+	weather   *loader[entity.CEP, *entity.WeatherInfo]
+	forecasts *loader[forecastKey, []entity.ForecastDay]
+}
+
+func newLoaders(ctx context.Context, useCases *usecase.WeatherUseCases) *loaders {
+	return &loaders{
+		locations: newLoader(ctx, batchWindow, maxLookups, func(ctx context.Context, keys []postalCodeKey) []loaderResult[*entity.CEP] {
+			return fetchConcurrently(ctx, keys, maxConcurrentLookups, func(ctx context.Context, key postalCodeKey) (*entity.CEP, error) {
+				return useCases.GetLocationByPostalCode(ctx, key.country, key.code)
+			})
+		}),
+		// Weather is keyed by location, so postal codes of the same city share one lookup
+		weather: newLoader(ctx, batchWindow, maxLookups, func(ctx context.Context, keys []entity.CEP) []loaderResult[*entity.WeatherInfo] {
+			return fetchConcurrently(ctx, keys, maxConcurrentLookups, func(ctx context.Context, key entity.CEP) (*entity.WeatherInfo, error) {
+				return useCases.GetWeatherByLocation(ctx, &key)
+			})
+		}),
+		forecasts: newLoader(ctx, batchWindow, maxLookups, func(ctx context.Context, keys []forecastKey) []loaderResult[[]entity.ForecastDay] {
+			return fetchConcurrently(ctx, keys, maxConcurrentLookups, func(ctx context.Context, key forecastKey) ([]entity.ForecastDay, error) {
+				return useCases.GetForecastByLocation(ctx, &key.location, key.days)
+			})
+		}),
+	}
+}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// resolverError exposes the catalog code of an error in the GraphQL error extensions
+type resolverError struct {
+	code    problem.Code
+	message string
+}
+
+func newResolverError(ctx context.Context, err error) *resolverError {
+	code := problem.CodeOf(err)
+	return &resolverError{
+		code:    code,
+		message: i18n.Message(i18n.FromContext(ctx), problem.Lookup(code).MessageKey),
+	}
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// loadError reports the failure of a load. A load beyond the budget of the request is a fault of the query, not of a lookup.
+func loadError(ctx context.Context, err error) error {
+	if errors.Is(err, errBudgetExceeded) {
+		return fmt.Errorf("a query looks up at most %d distinct postal codes, locations and forecasts", maxLookups)
+	}
+	return newResolverError(ctx, err)
+}
+
+// Resolver is the root resolver of the schema
+type Resolver struct{}
+
+type cepArgs struct {
+	Code    string
+	Country string
+}
+
+type cepsArgs struct {
+	Codes   []string
+	Country string
+}
+
+func (r *Resolver) Cep(args cepArgs) *cepResolver {
+	return &cepResolver{key: postalCodeKey{country: countryArg(args.Country), code: args.Code}}
+}
+
+func (r *Resolver) Ceps(args cepsArgs) ([]*cepResolver, error) {
+	if len(args.Codes) > maxCEPs {
+		return nil, fmt.Errorf("a query accepts at most %d postal codes", maxCEPs)
+	}
+
+	country := countryArg(args.Country)
+	ceps := make([]*cepResolver, len(args.Codes))
+	for i, code := range args.Codes {
+		ceps[i] = &cepResolver{key: postalCodeKey{country: country, code: code}}
+	}
+	return ceps, nil
+}
+
+type forecastArgs struct {
+	Days int32
+}
+
+func countryArg(country string) string {
+	if country == "" {
+		return usecase.DefaultCountry
+	}
+	return strings.ToUpper(country)
+}
+
+type cepResolver struct {
+	key postalCodeKey
+}
+
+func (r *cepResolver) Code() string {
+	return r.key.code
+}
+
+func (r *cepResolver) Country() string {
+	return r.key.country
+}
+
+func (r *cepResolver) Address(ctx context.Context) (*addressResolver, error) {
+	location, err := loadersFromContext(ctx).locations.Load(ctx, r.key)
+	if err != nil {
+		return nil, loadError(ctx, err)
+	}
+	return &addressResolver{location: location, country: r.key.country}, nil
+}
+
+func (r *cepResolver) Weather(ctx context.Context) (*weatherResolver, error) {
+	address, err := r.Address(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return address.Weather(ctx)
+}
+
+func (r *cepResolver) Forecast(ctx context.Context, args forecastArgs) (*[]*forecastDayResolver, error) {
+	address, err := r.Address(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return address.Forecast(ctx, args)
+}
+
+type addressResolver struct {
+	location *entity.CEP
+	country  string
+}
+
+func (r *addressResolver) City() string {
+	return r.location.Localidade
+}
+
+func (r *addressResolver) State() *string {
+	if r.location.Uf == "" {
+		return nil
+	}
+	return &r.location.Uf
+}
+
+func (r *addressResolver) Country() string {
+	return r.country
+}
+
+func (r *addressResolver) Weather(ctx context.Context) (*weatherResolver, error) {
+	weather, err := loadersFromContext(ctx).weather.Load(ctx, *r.location)
+	if err != nil {
+		return nil, loadError(ctx, err)
+	}
+	return &weatherResolver{weather: weather}, nil
+}
+
+func (r *addressResolver) Forecast(ctx context.Context, args forecastArgs) (*[]*forecastDayResolver, error) {
+	if args.Days < 1 || args.Days > usecase.MaxForecastDays {
+		return nil, fmt.Errorf("a forecast has from 1 to %d days", usecase.MaxForecastDays)
+	}
+
+	forecast, err := loadersFromContext(ctx).forecasts.Load(ctx, forecastKey{location: *r.location, days: int(args.Days)})
+	if err != nil {
+		return nil, loadError(ctx, err)
+	}
+	days := make([]*forecastDayResolver, len(forecast))
+	for i := range forecast {
+		days[i] = &forecastDayResolver{day: &forecast[i]}
+	}
+	return &days, nil
+}
+
+type weatherResolver struct {
+	weather *entity.WeatherInfo
+}
+
+func (r *weatherResolver) TempC() float64       { return r.weather.Celcius }
+func (r *weatherResolver) TempF() float64       { return r.weather.Fahrenheit }
+func (r *weatherResolver) TempK() float64       { return r.weather.Kelvin }
+func (r *weatherResolver) WindKph() *float64    { return r.weather.WindKph }
+func (r *weatherResolver) PressureMb() *float64 { return r.weather.PressureMb }
+func (r *weatherResolver) PrecipMm() *float64   { return r.weather.PrecipMm }
+
+func (r *weatherResolver) Condition() *string {
+	if r.weather.Condition == "" {
+		return nil
+	}
+	return &r.weather.Condition
+}
+
+type forecastDayResolver struct {
+	day *entity.ForecastDay
+}
+
+func (r *forecastDayResolver) Date() string            { return r.day.Date }
+func (r *forecastDayResolver) MaxTempC() float64       { return r.day.MaxTempC }
+func (r *forecastDayResolver) MinTempC() float64       { return r.day.MinTempC }
+func (r *forecastDayResolver) AvgTempC() float64       { return r.day.AvgTempC }
+func (r *forecastDayResolver) MaxWindKph() *float64    { return r.day.MaxWindKph }
+func (r *forecastDayResolver) TotalPrecipMm() *float64 { return r.day.TotalPrecipMm }
+func (r *forecastDayResolver) ChanceOfRain() *float64  { return r.day.ChanceOfRain }
+
+func (r *forecastDayResolver) Condition() *string {
+	if r.day.Condition == "" {
+		return nil
+	}
+	return &r.day.Condition
+}
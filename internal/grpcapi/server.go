@@ -0,0 +1,241 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// lookupTimeout bounds each lookup, like the REST handler does
+	lookupTimeout = 5 * time.Second
+	// maxBatchSize is the maximum number of postal codes of a batch
+	maxBatchSize = 100
+	// maxConcurrentLookups bounds the lookups running at once for a batch or stream
+	maxConcurrentLookups = 8
+	// errorDomain is the domain of the ErrorInfo details attached to errors
+	errorDomain = "go-weather"
+)
+
+// WeatherServer implements the weather.v1.WeatherService gRPC service on top of the weather use cases
+type WeatherServer struct {
+	weatherv1.UnimplementedWeatherServiceServer
+	useCases *usecase.WeatherUseCases
+}
+
+// NewWeatherServer creates a new instance of WeatherServer
+func NewWeatherServer(useCases *usecase.WeatherUseCases) *WeatherServer {
+	return &WeatherServer{useCases: useCases}
+}
+
+// GetWeatherByCEP returns the location and current weather of a postal code
+func (s *WeatherServer) GetWeatherByCEP(ctx context.Context, req *weatherv1.GetWeatherByCEPRequest) (*weatherv1.GetWeatherByCEPResponse, error) {
+	resp, err := s.lookup(ctx, req.GetCountry(), req.GetCep())
+	if err != nil {
+		return nil, toStatus(err).Err()
+	}
+	return resp, nil
+}
+
+// GetCEP returns the location of a postal code
+func (s *WeatherServer) GetCEP(ctx context.Context, req *weatherv1.GetCEPRequest) (*weatherv1.GetCEPResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	country := countryOrDefault(req.GetCountry())
+	location, err := s.useCases.GetLocationByPostalCode(ctx, country, req.GetCep())
+	if err != nil {
+		return nil, toStatus(err).Err()
+	}
+	return &weatherv1.GetCEPResponse{Location: toLocation(location, country)}, nil
+}
+
+// BatchGetWeatherByCEP looks up several postal codes concurrently, reporting failures per item
+func (s *WeatherServer) BatchGetWeatherByCEP(ctx context.Context, req *weatherv1.BatchGetWeatherByCEPRequest) (*weatherv1.BatchGetWeatherByCEPResponse, error) {
+	postalCodes := req.GetPostalCodes()
+	if len(postalCodes) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "a batch accepts at most %d postal codes", maxBatchSize)
+	}
+
+	results := make([]*weatherv1.WeatherResult, len(postalCodes))
+	sem := make(chan struct{}, maxConcurrentLookups)
+	var wg sync.WaitGroup
+
+	for i, pc := range postalCodes {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.result(ctx, pc)
+		}()
+	}
+	wg.Wait()
+
+	return &weatherv1.BatchGetWeatherByCEPResponse{Results: results}, nil
+}
+
+// StreamWeatherByCEP looks up every postal code received on the stream and sends the results as they complete
+func (s *WeatherServer) StreamWeatherByCEP(stream weatherv1.WeatherService_StreamWeatherByCEPServer) error {
+	ctx := stream.Context()
+	sem := make(chan struct{}, maxConcurrentLookups)
+	var wg sync.WaitGroup
+	var sendMu sync.Mutex
+	var sendErr error
+
+	// Wait for the lookups in flight before returning, the stream is closed afterward
+	defer wg.Wait()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			wg.Wait()
+			return sendErr
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := s.result(ctx, req.GetPostalCode())
+
+			// grpc streams do not support concurrent sends
+			sendMu.Lock()
+			defer sendMu.Unlock()
+			if sendErr != nil {
+				return
+			}
+			sendErr = stream.Send(&weatherv1.StreamWeatherByCEPResponse{Result: result})
+		}()
+	}
+}
+
+// result looks up a postal code of a batch or stream, turning a failure into an item error
+func (s *WeatherServer) result(ctx context.Context, pc *weatherv1.PostalCode) *weatherv1.WeatherResult {
+	resp, err := s.lookup(ctx, pc.GetCountry(), pc.GetCep())
+	if err != nil {
+		st := toStatus(err)
+		return &weatherv1.WeatherResult{
+			PostalCode: pc,
+			Result: &weatherv1.WeatherResult_Error{Error: &weatherv1.Error{
+				Code:     string(problem.CodeOf(err)),
+				Message:  st.Message(),
+				GrpcCode: int32(st.Code()),
+			}},
+		}
+	}
+	return &weatherv1.WeatherResult{
+		PostalCode: pc,
+		Result:     &weatherv1.WeatherResult_Response{Response: resp},
+	}
+}
+
+func (s *WeatherServer) lookup(ctx context.Context, country, postalCode string) (*weatherv1.GetWeatherByCEPResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	country = countryOrDefault(country)
+	report, err := s.useCases.GetWeatherReportByPostalCode(ctx, country, postalCode, false)
+	if err != nil {
+		return nil, err
+	}
+
+	w := report.Weather
+	return &weatherv1.GetWeatherByCEPResponse{
+		Location: toLocation(report.Location, country),
+		Weather: &weatherv1.Weather{
+			TempC:      w.Celcius,
+			TempF:      w.Fahrenheit,
+			TempK:      w.Kelvin,
+			WindKph:    w.WindKph,
+			PressureMb: w.PressureMb,
+			PrecipMm:   w.PrecipMm,
+			Condition:  w.Condition,
+		},
+	}, nil
+}
+
+func countryOrDefault(country string) string {
+	if country == "" {
+		return usecase.DefaultCountry
+	}
+	return strings.ToUpper(country)
+}
+
+func toLocation(c *entity.CEP, country string) *weatherv1.Location {
+	return &weatherv1.Location{City: c.Localidade, State: c.Uf, Country: country}
+}
+
+// grpcCodes maps the codes of the problem catalog to gRPC status codes
+var grpcCodes = map[problem.Code]codes.Code{
+	problem.CodeCEPInvalid:         codes.InvalidArgument,
+	problem.CodePostalCodeInvalid:  codes.InvalidArgument,
+	problem.CodeUnitsInvalid:       codes.InvalidArgument,
+	problem.CodeMessageInvalid:     codes.InvalidArgument,
+	problem.CodeWebhookInvalid:     codes.InvalidArgument,
+	problem.CodeBatchInvalid:       codes.InvalidArgument,
+	problem.CodeNotAcceptable:      codes.InvalidArgument,
+	problem.CodeWebhookNotFound:    codes.NotFound,
+	problem.CodeWebhookDelivery:    codes.Unavailable,
+	problem.CodeCEPNotFound:        codes.NotFound,
+	problem.CodePostalCodeNotFound: codes.NotFound,
+	problem.CodeCountryUnsupported: codes.NotFound,
+	problem.CodeWeatherNotFound:    codes.NotFound,
+	problem.CodeUpstreamTimeout:    codes.DeadlineExceeded,
+	problem.CodeUpstreamDown:       codes.Unavailable,
+	problem.CodeUpstreamRateLimit:  codes.ResourceExhausted,
+	problem.CodeSubscriptionLimit:  codes.ResourceExhausted,
+	problem.CodeUpstreamBadPayload: codes.Internal,
+	problem.CodeCEPUpstreamError:   codes.Internal,
+	problem.CodeWeatherUpstream:    codes.Internal,
+	problem.CodeInternal:           codes.Internal,
+}
+
+// toStatus converts a use case error to a gRPC status carrying the catalog code in an ErrorInfo detail
+func toStatus(err error) *status.Status {
+	code := problem.CodeOf(err)
+	grpcCode, ok := grpcCodes[code]
+	if !ok {
+		grpcCode = codes.Internal
+	}
+
+	def := problem.Lookup(code)
+	if def.Status >= http.StatusInternalServerError {
+		// The error chain may carry upstream details, it is logged and kept out of the status
+		slog.Error("gRPC lookup failed", "code", code, "error", err)
+	}
+
+	st := status.New(grpcCode, def.Title)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(code), Domain: errorDomain}); err == nil {
+		return detailed
+	}
+	return st
+}
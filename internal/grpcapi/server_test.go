@@ -0,0 +1,194 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeCEPRepository resolves the CEPs of its map and answers the others as not found
+type fakeCEPRepository map[string]*entity.CEP
+
+func (f fakeCEPRepository) GetCEP(_ context.Context, cep string) (*entity.CEP, error) {
+	if c, ok := f[cep]; ok {
+		return c, nil
+	}
+	return nil, &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")}
+}
+
+// fakeWeatherRepository answers 25 °C everywhere but in "Offline", where the provider is unavailable
+type fakeWeatherRepository struct{}
+
+func (fakeWeatherRepository) GetWeatherInfo(_ context.Context, cep *entity.CEP) (*entity.WeatherInfo, error) {
+	if cep.Localidade == "Offline" {
+		return nil, &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamUnavailable, Err: errors.New("connection refused")}
+	}
+	return &entity.WeatherInfo{Celcius: 25, Fahrenheit: 77, Condition: "Sunny", Valid: true}, nil
+}
+
+func newTestClient(t *testing.T) weatherv1.WeatherServiceClient {
+	cepRepository := fakeCEPRepository{
+		"01001000": {Localidade: "São Paulo", Uf: "SP"},
+		"20270150": {Localidade: "Offline", Uf: "RJ"},
+	}
+	useCases := usecase.NewWeatherUseCases(cepRepository, fakeWeatherRepository{})
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	weatherv1.RegisterWeatherServiceServer(server, NewWeatherServer(useCases))
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return weatherv1.NewWeatherServiceClient(conn)
+}
+
+func TestGetWeatherByCEP(t *testing.T) {
+	client := newTestClient(t)
+
+	t.Run("Valid CEP", func(t *testing.T) {
+		resp, err := client.GetWeatherByCEP(context.Background(), &weatherv1.GetWeatherByCEPRequest{Cep: "01001000"})
+		require.NoError(t, err)
+		assert.Equal(t, "São Paulo", resp.GetLocation().GetCity())
+		assert.Equal(t, "BR", resp.GetLocation().GetCountry())
+		assert.Equal(t, 25.0, resp.GetWeather().GetTempC())
+		assert.Equal(t, 298.15, resp.GetWeather().GetTempK())
+	})
+
+	testTable := []struct {
+		name      string
+		cep       string
+		grpcCode  codes.Code
+		errorCode string
+	}{
+		{"Invalid CEP", "123", codes.InvalidArgument, "CEP_INVALID"},
+		{"CEP not found", "99999999", codes.NotFound, "CEP_NOT_FOUND"},
+		{"Weather provider unavailable", "20270150", codes.Unavailable, "UPSTREAM_UNAVAILABLE"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			_, err := client.GetWeatherByCEP(context.Background(), &weatherv1.GetWeatherByCEPRequest{Cep: tr.cep})
+			st := status.Convert(err)
+			assert.Equal(t, tr.grpcCode, st.Code())
+			require.Len(t, st.Details(), 1)
+			assert.Equal(t, tr.errorCode, st.Details()[0].(*errdetails.ErrorInfo).GetReason())
+		})
+	}
+}
+
+func TestGetCEP(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.GetCEP(context.Background(), &weatherv1.GetCEPRequest{Cep: "20270150"})
+	require.NoError(t, err)
+	assert.Equal(t, "RJ", resp.GetLocation().GetState())
+
+	_, err = client.GetCEP(context.Background(), &weatherv1.GetCEPRequest{Cep: "1000-001", Country: "US"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestBatchGetWeatherByCEP(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.BatchGetWeatherByCEP(context.Background(), &weatherv1.BatchGetWeatherByCEPRequest{
+		PostalCodes: []*weatherv1.PostalCode{{Cep: "01001000"}, {Cep: "99999999"}, {Cep: "20270150"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.GetResults(), 3)
+
+	assert.Equal(t, "São Paulo", resp.GetResults()[0].GetResponse().GetLocation().GetCity())
+	assert.Equal(t, "CEP_NOT_FOUND", resp.GetResults()[1].GetError().GetCode())
+	assert.Equal(t, int32(codes.Unavailable), resp.GetResults()[2].GetError().GetGrpcCode())
+	assert.Equal(t, "20270150", resp.GetResults()[2].GetPostalCode().GetCep())
+
+	t.Run("Batch too large", func(t *testing.T) {
+		postalCodes := make([]*weatherv1.PostalCode, maxBatchSize+1)
+		_, err := client.BatchGetWeatherByCEP(context.Background(), &weatherv1.BatchGetWeatherByCEPRequest{PostalCodes: postalCodes})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestStreamWeatherByCEP(t *testing.T) {
+	client := newTestClient(t)
+
+	stream, err := client.StreamWeatherByCEP(context.Background())
+	require.NoError(t, err)
+
+	for _, cep := range []string{"01001000", "99999999"} {
+		require.NoError(t, stream.Send(&weatherv1.StreamWeatherByCEPRequest{PostalCode: &weatherv1.PostalCode{Cep: cep}}))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	results := map[string]*weatherv1.WeatherResult{}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		results[resp.GetResult().GetPostalCode().GetCep()] = resp.GetResult()
+	}
+
+	require.Len(t, results, 2)
+	assert.Equal(t, 25.0, results["01001000"].GetResponse().GetWeather().GetTempC())
+	assert.Equal(t, "CEP_NOT_FOUND", results["99999999"].GetError().GetCode())
+}
+
+// blockingWeatherRepository holds every lookup until its context is done, telling when each one starts
+type blockingWeatherRepository struct {
+	started chan struct{}
+}
+
+func (f blockingWeatherRepository) GetWeatherInfo(ctx context.Context, _ *entity.CEP) (*entity.WeatherInfo, error) {
+	f.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBatchGetWeatherByCEPStopsWhenCancelled(t *testing.T) {
+	cepRepository := fakeCEPRepository{}
+	var postalCodes []*weatherv1.PostalCode
+	for i := range maxConcurrentLookups + 1 {
+		cep := fmt.Sprintf("0100%04d", i)
+		cepRepository[cep] = &entity.CEP{Localidade: cep}
+		postalCodes = append(postalCodes, &weatherv1.PostalCode{Cep: cep})
+	}
+	weatherRepository := blockingWeatherRepository{started: make(chan struct{}, len(postalCodes))}
+	server := NewWeatherServer(usecase.NewWeatherUseCases(cepRepository, weatherRepository))
+
+	// Every slot is taken by a held lookup when the client goes away, the last postal code waits for a slot
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for range maxConcurrentLookups {
+			<-weatherRepository.started
+		}
+		// Let the batch block on the full semaphore
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := server.BatchGetWeatherByCEP(ctx, &weatherv1.BatchGetWeatherByCEPRequest{PostalCodes: postalCodes})
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Empty(t, weatherRepository.started, "the lookup waiting for a slot must not start")
+}
@@ -0,0 +1,908 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: weather/v1/weather.proto
+
+package weatherv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// PostalCode identifies a postal code of a country.
+type PostalCode struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Cep   string                 `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	// ISO 3166-1 alpha-2 country of the postal code, BR when empty.
+	Country       string `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PostalCode) Reset() {
+	*x = PostalCode{}
+	mi := &file_weather_v1_weather_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PostalCode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PostalCode) ProtoMessage() {}
+
+func (x *PostalCode) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PostalCode.ProtoReflect.Descriptor instead.
+func (*PostalCode) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PostalCode) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *PostalCode) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+type GetWeatherByCEPRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Cep   string                 `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	// ISO 3166-1 alpha-2 country of the postal code, BR when empty.
+	Country       string `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWeatherByCEPRequest) Reset() {
+	*x = GetWeatherByCEPRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWeatherByCEPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWeatherByCEPRequest) ProtoMessage() {}
+
+func (x *GetWeatherByCEPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWeatherByCEPRequest.ProtoReflect.Descriptor instead.
+func (*GetWeatherByCEPRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetWeatherByCEPRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *GetWeatherByCEPRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+type GetWeatherByCEPResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Location      *Location              `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
+	Weather       *Weather               `protobuf:"bytes,2,opt,name=weather,proto3" json:"weather,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWeatherByCEPResponse) Reset() {
+	*x = GetWeatherByCEPResponse{}
+	mi := &file_weather_v1_weather_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWeatherByCEPResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWeatherByCEPResponse) ProtoMessage() {}
+
+func (x *GetWeatherByCEPResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWeatherByCEPResponse.ProtoReflect.Descriptor instead.
+func (*GetWeatherByCEPResponse) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetWeatherByCEPResponse) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *GetWeatherByCEPResponse) GetWeather() *Weather {
+	if x != nil {
+		return x.Weather
+	}
+	return nil
+}
+
+type GetCEPRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Cep   string                 `protobuf:"bytes,1,opt,name=cep,proto3" json:"cep,omitempty"`
+	// ISO 3166-1 alpha-2 country of the postal code, BR when empty.
+	Country       string `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCEPRequest) Reset() {
+	*x = GetCEPRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCEPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCEPRequest) ProtoMessage() {}
+
+func (x *GetCEPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCEPRequest.ProtoReflect.Descriptor instead.
+func (*GetCEPRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetCEPRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *GetCEPRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+type GetCEPResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Location      *Location              `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCEPResponse) Reset() {
+	*x = GetCEPResponse{}
+	mi := &file_weather_v1_weather_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCEPResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCEPResponse) ProtoMessage() {}
+
+func (x *GetCEPResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCEPResponse.ProtoReflect.Descriptor instead.
+func (*GetCEPResponse) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetCEPResponse) GetLocation() *Location {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+type BatchGetWeatherByCEPRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostalCodes   []*PostalCode          `protobuf:"bytes,1,rep,name=postal_codes,json=postalCodes,proto3" json:"postal_codes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetWeatherByCEPRequest) Reset() {
+	*x = BatchGetWeatherByCEPRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetWeatherByCEPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetWeatherByCEPRequest) ProtoMessage() {}
+
+func (x *BatchGetWeatherByCEPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetWeatherByCEPRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetWeatherByCEPRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchGetWeatherByCEPRequest) GetPostalCodes() []*PostalCode {
+	if x != nil {
+		return x.PostalCodes
+	}
+	return nil
+}
+
+type BatchGetWeatherByCEPResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Results are in the order of the postal codes of the request.
+	Results       []*WeatherResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetWeatherByCEPResponse) Reset() {
+	*x = BatchGetWeatherByCEPResponse{}
+	mi := &file_weather_v1_weather_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetWeatherByCEPResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetWeatherByCEPResponse) ProtoMessage() {}
+
+func (x *BatchGetWeatherByCEPResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetWeatherByCEPResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetWeatherByCEPResponse) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BatchGetWeatherByCEPResponse) GetResults() []*WeatherResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type StreamWeatherByCEPRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PostalCode    *PostalCode            `protobuf:"bytes,1,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamWeatherByCEPRequest) Reset() {
+	*x = StreamWeatherByCEPRequest{}
+	mi := &file_weather_v1_weather_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamWeatherByCEPRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamWeatherByCEPRequest) ProtoMessage() {}
+
+func (x *StreamWeatherByCEPRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamWeatherByCEPRequest.ProtoReflect.Descriptor instead.
+func (*StreamWeatherByCEPRequest) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamWeatherByCEPRequest) GetPostalCode() *PostalCode {
+	if x != nil {
+		return x.PostalCode
+	}
+	return nil
+}
+
+type StreamWeatherByCEPResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Result        *WeatherResult         `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamWeatherByCEPResponse) Reset() {
+	*x = StreamWeatherByCEPResponse{}
+	mi := &file_weather_v1_weather_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamWeatherByCEPResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamWeatherByCEPResponse) ProtoMessage() {}
+
+func (x *StreamWeatherByCEPResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamWeatherByCEPResponse.ProtoReflect.Descriptor instead.
+func (*StreamWeatherByCEPResponse) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamWeatherByCEPResponse) GetResult() *WeatherResult {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+// WeatherResult is the outcome of one lookup of a batch or stream.
+type WeatherResult struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	PostalCode *PostalCode            `protobuf:"bytes,1,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	// Types that are valid to be assigned to Result:
+	//
+	//	*WeatherResult_Response
+	//	*WeatherResult_Error
+	Result        isWeatherResult_Result `protobuf_oneof:"result"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WeatherResult) Reset() {
+	*x = WeatherResult{}
+	mi := &file_weather_v1_weather_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WeatherResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WeatherResult) ProtoMessage() {}
+
+func (x *WeatherResult) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WeatherResult.ProtoReflect.Descriptor instead.
+func (*WeatherResult) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *WeatherResult) GetPostalCode() *PostalCode {
+	if x != nil {
+		return x.PostalCode
+	}
+	return nil
+}
+
+func (x *WeatherResult) GetResult() isWeatherResult_Result {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+func (x *WeatherResult) GetResponse() *GetWeatherByCEPResponse {
+	if x != nil {
+		if x, ok := x.Result.(*WeatherResult_Response); ok {
+			return x.Response
+		}
+	}
+	return nil
+}
+
+func (x *WeatherResult) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Result.(*WeatherResult_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isWeatherResult_Result interface {
+	isWeatherResult_Result()
+}
+
+type WeatherResult_Response struct {
+	Response *GetWeatherByCEPResponse `protobuf:"bytes,2,opt,name=response,proto3,oneof"`
+}
+
+type WeatherResult_Error struct {
+	Error *Error `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
+func (*WeatherResult_Response) isWeatherResult_Result() {}
+
+func (*WeatherResult_Error) isWeatherResult_Result() {}
+
+type Location struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	City          string                 `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Country       string                 `protobuf:"bytes,3,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Location) Reset() {
+	*x = Location{}
+	mi := &file_weather_v1_weather_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Location) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Location) ProtoMessage() {}
+
+func (x *Location) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Location.ProtoReflect.Descriptor instead.
+func (*Location) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Location) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *Location) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Location) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+// Weather holds the current conditions in canonical units.
+// Wind, pressure and precipitation are unset when the provider did not report them.
+type Weather struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TempC         float64                `protobuf:"fixed64,1,opt,name=temp_c,json=tempC,proto3" json:"temp_c,omitempty"`
+	TempF         float64                `protobuf:"fixed64,2,opt,name=temp_f,json=tempF,proto3" json:"temp_f,omitempty"`
+	TempK         float64                `protobuf:"fixed64,3,opt,name=temp_k,json=tempK,proto3" json:"temp_k,omitempty"`
+	WindKph       *float64               `protobuf:"fixed64,4,opt,name=wind_kph,json=windKph,proto3,oneof" json:"wind_kph,omitempty"`
+	PressureMb    *float64               `protobuf:"fixed64,5,opt,name=pressure_mb,json=pressureMb,proto3,oneof" json:"pressure_mb,omitempty"`
+	PrecipMm      *float64               `protobuf:"fixed64,6,opt,name=precip_mm,json=precipMm,proto3,oneof" json:"precip_mm,omitempty"`
+	Condition     string                 `protobuf:"bytes,7,opt,name=condition,proto3" json:"condition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Weather) Reset() {
+	*x = Weather{}
+	mi := &file_weather_v1_weather_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Weather) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Weather) ProtoMessage() {}
+
+func (x *Weather) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Weather.ProtoReflect.Descriptor instead.
+func (*Weather) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Weather) GetTempC() float64 {
+	if x != nil {
+		return x.TempC
+	}
+	return 0
+}
+
+func (x *Weather) GetTempF() float64 {
+	if x != nil {
+		return x.TempF
+	}
+	return 0
+}
+
+func (x *Weather) GetTempK() float64 {
+	if x != nil {
+		return x.TempK
+	}
+	return 0
+}
+
+func (x *Weather) GetWindKph() float64 {
+	if x != nil && x.WindKph != nil {
+		return *x.WindKph
+	}
+	return 0
+}
+
+func (x *Weather) GetPressureMb() float64 {
+	if x != nil && x.PressureMb != nil {
+		return *x.PressureMb
+	}
+	return 0
+}
+
+func (x *Weather) GetPrecipMm() float64 {
+	if x != nil && x.PrecipMm != nil {
+		return *x.PrecipMm
+	}
+	return 0
+}
+
+func (x *Weather) GetCondition() string {
+	if x != nil {
+		return x.Condition
+	}
+	return ""
+}
+
+// Error describes a failed lookup with the same codes as the REST API (e.g. CEP_NOT_FOUND).
+type Error struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Code    string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// grpc_code is the gRPC status code the lookup would have failed with on its own.
+	GrpcCode      int32 `protobuf:"varint,3,opt,name=grpc_code,json=grpcCode,proto3" json:"grpc_code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_weather_v1_weather_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_weather_v1_weather_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_weather_v1_weather_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetGrpcCode() int32 {
+	if x != nil {
+		return x.GrpcCode
+	}
+	return 0
+}
+
+var File_weather_v1_weather_proto protoreflect.FileDescriptor
+
+const file_weather_v1_weather_proto_rawDesc = "" +
+	"\n" +
+	"\x18weather/v1/weather.proto\x12\n" +
+	"weather.v1\"8\n" +
+	"\n" +
+	"PostalCode\x12\x10\n" +
+	"\x03cep\x18\x01 \x01(\tR\x03cep\x12\x18\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\"D\n" +
+	"\x16GetWeatherByCEPRequest\x12\x10\n" +
+	"\x03cep\x18\x01 \x01(\tR\x03cep\x12\x18\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\"z\n" +
+	"\x17GetWeatherByCEPResponse\x120\n" +
+	"\blocation\x18\x01 \x01(\v2\x14.weather.v1.LocationR\blocation\x12-\n" +
+	"\aweather\x18\x02 \x01(\v2\x13.weather.v1.WeatherR\aweather\";\n" +
+	"\rGetCEPRequest\x12\x10\n" +
+	"\x03cep\x18\x01 \x01(\tR\x03cep\x12\x18\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\"B\n" +
+	"\x0eGetCEPResponse\x120\n" +
+	"\blocation\x18\x01 \x01(\v2\x14.weather.v1.LocationR\blocation\"X\n" +
+	"\x1bBatchGetWeatherByCEPRequest\x129\n" +
+	"\fpostal_codes\x18\x01 \x03(\v2\x16.weather.v1.PostalCodeR\vpostalCodes\"S\n" +
+	"\x1cBatchGetWeatherByCEPResponse\x123\n" +
+	"\aresults\x18\x01 \x03(\v2\x19.weather.v1.WeatherResultR\aresults\"T\n" +
+	"\x19StreamWeatherByCEPRequest\x127\n" +
+	"\vpostal_code\x18\x01 \x01(\v2\x16.weather.v1.PostalCodeR\n" +
+	"postalCode\"O\n" +
+	"\x1aStreamWeatherByCEPResponse\x121\n" +
+	"\x06result\x18\x01 \x01(\v2\x19.weather.v1.WeatherResultR\x06result\"\xc0\x01\n" +
+	"\rWeatherResult\x127\n" +
+	"\vpostal_code\x18\x01 \x01(\v2\x16.weather.v1.PostalCodeR\n" +
+	"postalCode\x12A\n" +
+	"\bresponse\x18\x02 \x01(\v2#.weather.v1.GetWeatherByCEPResponseH\x00R\bresponse\x12)\n" +
+	"\x05error\x18\x03 \x01(\v2\x11.weather.v1.ErrorH\x00R\x05errorB\b\n" +
+	"\x06result\"N\n" +
+	"\bLocation\x12\x12\n" +
+	"\x04city\x18\x01 \x01(\tR\x04city\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x18\n" +
+	"\acountry\x18\x03 \x01(\tR\acountry\"\xff\x01\n" +
+	"\aWeather\x12\x15\n" +
+	"\x06temp_c\x18\x01 \x01(\x01R\x05tempC\x12\x15\n" +
+	"\x06temp_f\x18\x02 \x01(\x01R\x05tempF\x12\x15\n" +
+	"\x06temp_k\x18\x03 \x01(\x01R\x05tempK\x12\x1e\n" +
+	"\bwind_kph\x18\x04 \x01(\x01H\x00R\awindKph\x88\x01\x01\x12$\n" +
+	"\vpressure_mb\x18\x05 \x01(\x01H\x01R\n" +
+	"pressureMb\x88\x01\x01\x12 \n" +
+	"\tprecip_mm\x18\x06 \x01(\x01H\x02R\bprecipMm\x88\x01\x01\x12\x1c\n" +
+	"\tcondition\x18\a \x01(\tR\tconditionB\v\n" +
+	"\t_wind_kphB\x0e\n" +
+	"\f_pressure_mbB\f\n" +
+	"\n" +
+	"_precip_mm\"R\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1b\n" +
+	"\tgrpc_code\x18\x03 \x01(\x05R\bgrpcCode2\x81\x03\n" +
+	"\x0eWeatherService\x12Z\n" +
+	"\x0fGetWeatherByCEP\x12\".weather.v1.GetWeatherByCEPRequest\x1a#.weather.v1.GetWeatherByCEPResponse\x12?\n" +
+	"\x06GetCEP\x12\x19.weather.v1.GetCEPRequest\x1a\x1a.weather.v1.GetCEPResponse\x12i\n" +
+	"\x14BatchGetWeatherByCEP\x12'.weather.v1.BatchGetWeatherByCEPRequest\x1a(.weather.v1.BatchGetWeatherByCEPResponse\x12g\n" +
+	"\x12StreamWeatherByCEP\x12%.weather.v1.StreamWeatherByCEPRequest\x1a&.weather.v1.StreamWeatherByCEPResponse(\x010\x01BEZCgithub.com/caricciy/go-weather/internal/grpcapi/weatherv1;weatherv1b\x06proto3"
+
+var (
+	file_weather_v1_weather_proto_rawDescOnce sync.Once
+	file_weather_v1_weather_proto_rawDescData []byte
+)
+
+func file_weather_v1_weather_proto_rawDescGZIP() []byte {
+	file_weather_v1_weather_proto_rawDescOnce.Do(func() {
+		file_weather_v1_weather_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_weather_v1_weather_proto_rawDesc), len(file_weather_v1_weather_proto_rawDesc)))
+	})
+	return file_weather_v1_weather_proto_rawDescData
+}
+
+var file_weather_v1_weather_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_weather_v1_weather_proto_goTypes = []any{
+	(*PostalCode)(nil),                   // 0: weather.v1.PostalCode
+	(*GetWeatherByCEPRequest)(nil),       // 1: weather.v1.GetWeatherByCEPRequest
+	(*GetWeatherByCEPResponse)(nil),      // 2: weather.v1.GetWeatherByCEPResponse
+	(*GetCEPRequest)(nil),                // 3: weather.v1.GetCEPRequest
+	(*GetCEPResponse)(nil),               // 4: weather.v1.GetCEPResponse
+	(*BatchGetWeatherByCEPRequest)(nil),  // 5: weather.v1.BatchGetWeatherByCEPRequest
+	(*BatchGetWeatherByCEPResponse)(nil), // 6: weather.v1.BatchGetWeatherByCEPResponse
+	(*StreamWeatherByCEPRequest)(nil),    // 7: weather.v1.StreamWeatherByCEPRequest
+	(*StreamWeatherByCEPResponse)(nil),   // 8: weather.v1.StreamWeatherByCEPResponse
+	(*WeatherResult)(nil),                // 9: weather.v1.WeatherResult
+	(*Location)(nil),                     // 10: weather.v1.Location
+	(*Weather)(nil),                      // 11: weather.v1.Weather
+	(*Error)(nil),                        // 12: weather.v1.Error
+}
+var file_weather_v1_weather_proto_depIdxs = []int32{
+	10, // 0: weather.v1.GetWeatherByCEPResponse.location:type_name -> weather.v1.Location
+	11, // 1: weather.v1.GetWeatherByCEPResponse.weather:type_name -> weather.v1.Weather
+	10, // 2: weather.v1.GetCEPResponse.location:type_name -> weather.v1.Location
+	0,  // 3: weather.v1.BatchGetWeatherByCEPRequest.postal_codes:type_name -> weather.v1.PostalCode
+	9,  // 4: weather.v1.BatchGetWeatherByCEPResponse.results:type_name -> weather.v1.WeatherResult
+	0,  // 5: weather.v1.StreamWeatherByCEPRequest.postal_code:type_name -> weather.v1.PostalCode
+	9,  // 6: weather.v1.StreamWeatherByCEPResponse.result:type_name -> weather.v1.WeatherResult
+	0,  // 7: weather.v1.WeatherResult.postal_code:type_name -> weather.v1.PostalCode
+	2,  // 8: weather.v1.WeatherResult.response:type_name -> weather.v1.GetWeatherByCEPResponse
+	12, // 9: weather.v1.WeatherResult.error:type_name -> weather.v1.Error
+	1,  // 10: weather.v1.WeatherService.GetWeatherByCEP:input_type -> weather.v1.GetWeatherByCEPRequest
+	3,  // 11: weather.v1.WeatherService.GetCEP:input_type -> weather.v1.GetCEPRequest
+	5,  // 12: weather.v1.WeatherService.BatchGetWeatherByCEP:input_type -> weather.v1.BatchGetWeatherByCEPRequest
+	7,  // 13: weather.v1.WeatherService.StreamWeatherByCEP:input_type -> weather.v1.StreamWeatherByCEPRequest
+	2,  // 14: weather.v1.WeatherService.GetWeatherByCEP:output_type -> weather.v1.GetWeatherByCEPResponse
+	4,  // 15: weather.v1.WeatherService.GetCEP:output_type -> weather.v1.GetCEPResponse
+	6,  // 16: weather.v1.WeatherService.BatchGetWeatherByCEP:output_type -> weather.v1.BatchGetWeatherByCEPResponse
+	8,  // 17: weather.v1.WeatherService.StreamWeatherByCEP:output_type -> weather.v1.StreamWeatherByCEPResponse
+	14, // [14:18] is the sub-list for method output_type
+	10, // [10:14] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_weather_v1_weather_proto_init() }
+func file_weather_v1_weather_proto_init() {
+	if File_weather_v1_weather_proto != nil {
+		return
+	}
+	file_weather_v1_weather_proto_msgTypes[9].OneofWrappers = []any{
+		(*WeatherResult_Response)(nil),
+		(*WeatherResult_Error)(nil),
+	}
+	file_weather_v1_weather_proto_msgTypes[11].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_weather_v1_weather_proto_rawDesc), len(file_weather_v1_weather_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_weather_v1_weather_proto_goTypes,
+		DependencyIndexes: file_weather_v1_weather_proto_depIdxs,
+		MessageInfos:      file_weather_v1_weather_proto_msgTypes,
+	}.Build()
+	File_weather_v1_weather_proto = out.File
+	file_weather_v1_weather_proto_goTypes = nil
+	file_weather_v1_weather_proto_depIdxs = nil
+}
@@ -0,0 +1,242 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: weather/v1/weather.proto
+
+package weatherv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	WeatherService_GetWeatherByCEP_FullMethodName      = "/weather.v1.WeatherService/GetWeatherByCEP"
+	WeatherService_GetCEP_FullMethodName               = "/weather.v1.WeatherService/GetCEP"
+	WeatherService_BatchGetWeatherByCEP_FullMethodName = "/weather.v1.WeatherService/BatchGetWeatherByCEP"
+	WeatherService_StreamWeatherByCEP_FullMethodName   = "/weather.v1.WeatherService/StreamWeatherByCEP"
+)
+
+// WeatherServiceClient is the client API for WeatherService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// WeatherService looks up locations and their current weather by postal code.
+type WeatherServiceClient interface {
+	// GetWeatherByCEP returns the current weather of a postal code (a Brazilian CEP by default).
+	GetWeatherByCEP(ctx context.Context, in *GetWeatherByCEPRequest, opts ...grpc.CallOption) (*GetWeatherByCEPResponse, error)
+	// GetCEP returns the location of a postal code (a Brazilian CEP by default).
+	GetCEP(ctx context.Context, in *GetCEPRequest, opts ...grpc.CallOption) (*GetCEPResponse, error)
+	// BatchGetWeatherByCEP looks up several postal codes at once. Failures are reported per item.
+	BatchGetWeatherByCEP(ctx context.Context, in *BatchGetWeatherByCEPRequest, opts ...grpc.CallOption) (*BatchGetWeatherByCEPResponse, error)
+	// StreamWeatherByCEP answers every postal code sent on the stream with a result, in completion order.
+	StreamWeatherByCEP(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse], error)
+}
+
+type weatherServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWeatherServiceClient(cc grpc.ClientConnInterface) WeatherServiceClient {
+	return &weatherServiceClient{cc}
+}
+
+func (c *weatherServiceClient) GetWeatherByCEP(ctx context.Context, in *GetWeatherByCEPRequest, opts ...grpc.CallOption) (*GetWeatherByCEPResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetWeatherByCEPResponse)
+	err := c.cc.Invoke(ctx, WeatherService_GetWeatherByCEP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) GetCEP(ctx context.Context, in *GetCEPRequest, opts ...grpc.CallOption) (*GetCEPResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCEPResponse)
+	err := c.cc.Invoke(ctx, WeatherService_GetCEP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) BatchGetWeatherByCEP(ctx context.Context, in *BatchGetWeatherByCEPRequest, opts ...grpc.CallOption) (*BatchGetWeatherByCEPResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchGetWeatherByCEPResponse)
+	err := c.cc.Invoke(ctx, WeatherService_BatchGetWeatherByCEP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *weatherServiceClient) StreamWeatherByCEP(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &WeatherService_ServiceDesc.Streams[0], WeatherService_StreamWeatherByCEP_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WeatherService_StreamWeatherByCEPClient = grpc.BidiStreamingClient[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]
+
+// WeatherServiceServer is the server API for WeatherService service.
+// All implementations must embed UnimplementedWeatherServiceServer
+// for forward compatibility.
+//
+// WeatherService looks up locations and their current weather by postal code.
+type WeatherServiceServer interface {
+	// GetWeatherByCEP returns the current weather of a postal code (a Brazilian CEP by default).
+	GetWeatherByCEP(context.Context, *GetWeatherByCEPRequest) (*GetWeatherByCEPResponse, error)
+	// GetCEP returns the location of a postal code (a Brazilian CEP by default).
+	GetCEP(context.Context, *GetCEPRequest) (*GetCEPResponse, error)
+	// BatchGetWeatherByCEP looks up several postal codes at once. Failures are reported per item.
+	BatchGetWeatherByCEP(context.Context, *BatchGetWeatherByCEPRequest) (*BatchGetWeatherByCEPResponse, error)
+	// StreamWeatherByCEP answers every postal code sent on the stream with a result, in completion order.
+	StreamWeatherByCEP(grpc.BidiStreamingServer[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]) error
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+// UnimplementedWeatherServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWeatherServiceServer struct{}
+
+func (UnimplementedWeatherServiceServer) GetWeatherByCEP(context.Context, *GetWeatherByCEPRequest) (*GetWeatherByCEPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWeatherByCEP not implemented")
+}
+func (UnimplementedWeatherServiceServer) GetCEP(context.Context, *GetCEPRequest) (*GetCEPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCEP not implemented")
+}
+func (UnimplementedWeatherServiceServer) BatchGetWeatherByCEP(context.Context, *BatchGetWeatherByCEPRequest) (*BatchGetWeatherByCEPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetWeatherByCEP not implemented")
+}
+func (UnimplementedWeatherServiceServer) StreamWeatherByCEP(grpc.BidiStreamingServer[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamWeatherByCEP not implemented")
+}
+func (UnimplementedWeatherServiceServer) mustEmbedUnimplementedWeatherServiceServer() {}
+func (UnimplementedWeatherServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeWeatherServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WeatherServiceServer will
+// result in compilation errors.
+type UnsafeWeatherServiceServer interface {
+	mustEmbedUnimplementedWeatherServiceServer()
+}
+
+func RegisterWeatherServiceServer(s grpc.ServiceRegistrar, srv WeatherServiceServer) {
+	// If the following call pancis, it indicates UnimplementedWeatherServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&WeatherService_ServiceDesc, srv)
+}
+
+func _WeatherService_GetWeatherByCEP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetWeatherByCEPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetWeatherByCEP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetWeatherByCEP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetWeatherByCEP(ctx, req.(*GetWeatherByCEPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_GetCEP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCEPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).GetCEP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_GetCEP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).GetCEP(ctx, req.(*GetCEPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_BatchGetWeatherByCEP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetWeatherByCEPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WeatherServiceServer).BatchGetWeatherByCEP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: WeatherService_BatchGetWeatherByCEP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WeatherServiceServer).BatchGetWeatherByCEP(ctx, req.(*BatchGetWeatherByCEPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WeatherService_StreamWeatherByCEP_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WeatherServiceServer).StreamWeatherByCEP(&grpc.GenericServerStream[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type WeatherService_StreamWeatherByCEPServer = grpc.BidiStreamingServer[StreamWeatherByCEPRequest, StreamWeatherByCEPResponse]
+
+// WeatherService_ServiceDesc is the grpc.ServiceDesc for WeatherService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var WeatherService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "weather.v1.WeatherService",
+	HandlerType: (*WeatherServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetWeatherByCEP",
+			Handler:    _WeatherService_GetWeatherByCEP_Handler,
+		},
+		{
+			MethodName: "GetCEP",
+			Handler:    _WeatherService_GetCEP_Handler,
+		},
+		{
+			MethodName: "BatchGetWeatherByCEP",
+			Handler:    _WeatherService_BatchGetWeatherByCEP_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamWeatherByCEP",
+			Handler:       _WeatherService_StreamWeatherByCEP_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "weather/v1/weather.proto",
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// bearsAdminToken tells whether the request bears adminToken in an Authorization: Bearer header, an empty token authorizes nobody
+func bearsAdminToken(r *http.Request, adminToken *string) bool {
+	if adminToken == nil || *adminToken == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(*adminToken)) == 1
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/render"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/units"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxBatchSize is the maximum number of CEPs of a batch, like the gRPC batch
+	maxBatchSize = 100
+	// maxConcurrentLookups bounds the lookups running at once for a batch
+	maxConcurrentLookups = 8
+)
+
+type batchWeatherResponse struct {
+	XMLName xml.Name             `json:"-" xml:"results"`
+	Results []batchWeatherResult `json:"results" xml:"result"`
+}
+
+// batchWeatherResult is the outcome of the lookup of a CEP of a batch.
+// Location is present once the CEP is resolved, Weather or Error tells the outcome of the weather step.
+type batchWeatherResult struct {
+	CEP      string                   `json:"cep" xml:"cep"`
+	Location *locationResponse        `json:"location,omitempty" xml:"location,omitempty"`
+	Weather  *getWeatherByCEPResponse `json:"weather,omitempty" xml:"weather,omitempty"`
+	Error    *componentErrorResponse  `json:"error,omitempty" xml:"error,omitempty"`
+
+	lookup weatherLookup
+}
+
+// HandleGetWeatherBatch looks up the weather of the CEPs of the ceps parameter concurrently, reporting failures per CEP
+func (h *WeatherHandler) HandleGetWeatherBatch(w http.ResponseWriter, r *http.Request) {
+	ctx, end := tracing.Start(r.Context(), "WeatherHandler.HandleGetWeatherBatch")
+	defer end(nil)
+	r = r.WithContext(ctx)
+
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	system, err := units.ParseSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		sendProblem(w, r, problem.CodeUnitsInvalid)
+		return
+	}
+
+	ceps := batchCEPs(r.URL.Query()["ceps"])
+	if len(ceps) == 0 || len(ceps) > maxBatchSize {
+		sendProblem(w, r, problem.CodeBatchInvalid)
+		return
+	}
+
+	results := make([]batchWeatherResult, len(ceps))
+	sem := make(chan struct{}, maxConcurrentLookups)
+	var wg sync.WaitGroup
+
+	for i, cep := range ceps {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = h.batchResult(r, cep, system)
+		}()
+	}
+	wg.Wait()
+
+	render.SendCached(w, r, format, batchWeatherResponse{Results: results}, h.cache.Load().batchFreshness(results, time.Now()))
+}
+
+func (h *WeatherHandler) batchResult(r *http.Request, cep string, system units.System) batchWeatherResult {
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
+	defer cancel()
+
+	report, err := h.cepUseCases.GetWeatherReportByPostalCode(ctx, usecase.DefaultCountry, cep, true)
+	result := batchWeatherResult{
+		CEP:    cep,
+		lookup: weatherLookup{postalCode: cep, country: usecase.DefaultCountry, report: report, err: err},
+	}
+	if err != nil {
+		logError(r, err)
+		result.Error = newComponentError(r, problem.CodeOf(err))
+		return result
+	}
+
+	result.Location = &locationResponse{City: report.Location.Localidade, State: report.Location.Uf, Country: usecase.DefaultCountry}
+	if report.WeatherErr != nil {
+		logError(r, report.WeatherErr)
+		result.Error = newComponentError(r, problem.CodeOf(report.WeatherErr))
+		return result
+	}
+
+	weather := weatherResponse(report.Weather, system, h.precision)
+	result.Weather = &weather
+	return result
+}
+
+// batchCEPs reads the CEPs of repeated or comma-separated ceps parameters
+func batchCEPs(values []string) []string {
+	var ceps []string
+	for _, value := range values {
+		for _, cep := range strings.Split(value, ",") {
+			if cep = strings.TrimSpace(cep); cep != "" {
+				ceps = append(ceps, cep)
+			}
+		}
+	}
+	return ceps
+}
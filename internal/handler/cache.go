@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/render"
+	"time"
+)
+
+const (
+	// DefaultCEPMaxAge is the lifetime of the CEP lookups, the location of a CEP almost never changes
+	DefaultCEPMaxAge = 24 * time.Hour
+	// DefaultWeatherInterval is how often WeatherAPI refreshes the current conditions
+	DefaultWeatherInterval = 15 * time.Minute
+
+	// minWeatherMaxAge is the lifetime of conditions that are overdue or have no observation time
+	minWeatherMaxAge = time.Minute
+)
+
+// postalCodeErrors are the failures that only depend on the postal code, they last as long as a CEP lookup
+var postalCodeErrors = map[problem.Code]bool{
+	problem.CodeCEPInvalid:         true,
+	problem.CodeCEPNotFound:        true,
+	problem.CodePostalCodeInvalid:  true,
+	problem.CodePostalCodeNotFound: true,
+	problem.CodeCountryUnsupported: true,
+}
+
+// CacheConfig holds the lifetimes used to derive the Cache-Control of the responses
+type CacheConfig struct {
+	CEPMaxAge       time.Duration
+	WeatherInterval time.Duration
+}
+
+// DefaultCacheConfig returns the default lifetimes
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{CEPMaxAge: DefaultCEPMaxAge, WeatherInterval: DefaultWeatherInterval}
+}
+
+// weatherFreshness keeps conditions until the provider is expected to refresh them
+func (c CacheConfig) weatherFreshness(weather *entity.WeatherInfo, now time.Time) render.Freshness {
+	if weather.ObservedAt.IsZero() {
+		return render.Freshness{MaxAge: minWeatherMaxAge}
+	}
+
+	maxAge := min(weather.ObservedAt.Add(c.WeatherInterval).Sub(now), c.WeatherInterval)
+	return render.Freshness{MaxAge: max(maxAge, minWeatherMaxAge), LastModified: weather.ObservedAt}
+}
+
+// lookupFreshness is the freshness of a weather lookup. Transient failures are not cached,
+// while a postal code the client got wrong stays wrong for as long as a CEP lookup.
+func (c CacheConfig) lookupFreshness(lookup weatherLookup, now time.Time) render.Freshness {
+	switch {
+	case lookup.err != nil:
+		if postalCodeErrors[problem.CodeOf(lookup.err)] {
+			return render.Freshness{MaxAge: c.CEPMaxAge}
+		}
+		return render.Freshness{}
+	case lookup.report.Weather == nil:
+		return render.Freshness{}
+	default:
+		return c.weatherFreshness(lookup.report.Weather, now)
+	}
+}
+
+// batchFreshness is the freshness of the lookup that expires first.
+// The batch is only modified at the latest observation when every item has a known observation or a permanent error.
+func (c CacheConfig) batchFreshness(results []batchWeatherResult, now time.Time) render.Freshness {
+	var freshness render.Freshness
+	knownModification := true
+	for i, result := range results {
+		item := c.lookupFreshness(result.lookup, now)
+		if i == 0 || item.MaxAge < freshness.MaxAge {
+			freshness.MaxAge = item.MaxAge
+		}
+
+		if result.lookup.err != nil && item.MaxAge > 0 {
+			continue
+		}
+		if item.LastModified.IsZero() {
+			knownModification = false
+		} else if item.LastModified.After(freshness.LastModified) {
+			freshness.LastModified = item.LastModified
+		}
+	}
+
+	if !knownModification {
+		freshness.LastModified = time.Time{}
+	}
+	return freshness
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/render"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLookupFreshness(t *testing.T) {
+	cache := DefaultCacheConfig()
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	location := &entity.CEP{Localidade: "Rio de Janeiro", Uf: "RJ"}
+	observed := func(ago time.Duration) weatherLookup {
+		return weatherLookup{report: &entity.WeatherReport{Location: location, Weather: &entity.WeatherInfo{ObservedAt: now.Add(-ago)}}}
+	}
+
+	type testRow struct {
+		name     string
+		lookup   weatherLookup
+		expected render.Freshness
+	}
+
+	testTable := []testRow{
+		{name: "recent observation", lookup: observed(6 * time.Minute), expected: render.Freshness{MaxAge: 9 * time.Minute, LastModified: now.Add(-6 * time.Minute)}},
+		{name: "overdue observation", lookup: observed(time.Hour), expected: render.Freshness{MaxAge: time.Minute, LastModified: now.Add(-time.Hour)}},
+		{name: "observation ahead of the clock", lookup: observed(-time.Hour), expected: render.Freshness{MaxAge: 15 * time.Minute, LastModified: now.Add(time.Hour)}},
+		{name: "unknown observation", lookup: weatherLookup{report: &entity.WeatherReport{Location: location, Weather: &entity.WeatherInfo{}}}, expected: render.Freshness{MaxAge: time.Minute}},
+		{name: "failed weather step", lookup: weatherLookup{report: &entity.WeatherReport{Location: location, WeatherErr: usecase.ErrWeatherNotFound}}, expected: render.Freshness{}},
+		{name: "invalid CEP", lookup: weatherLookup{err: usecase.ErrInvalidCEP}, expected: render.Freshness{MaxAge: 24 * time.Hour}},
+		{name: "upstream failure", lookup: weatherLookup{err: usecase.ErrCouldNotFetchCEP}, expected: render.Freshness{}},
+		{name: "rate limited", lookup: weatherLookup{err: usecase.ErrUpstreamRateLimited}, expected: render.Freshness{}},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, cache.lookupFreshness(tr.lookup, now))
+		})
+	}
+
+	t.Run("batch", func(t *testing.T) {
+		results := []batchWeatherResult{{lookup: observed(6 * time.Minute)}, {lookup: observed(10 * time.Minute)}, {lookup: weatherLookup{err: usecase.ErrInvalidCEP}}}
+		assert.Equal(t, render.Freshness{MaxAge: 5 * time.Minute, LastModified: now.Add(-6 * time.Minute)}, cache.batchFreshness(results, now))
+
+		results = append(results, batchWeatherResult{lookup: weatherLookup{report: &entity.WeatherReport{Location: location, Weather: &entity.WeatherInfo{}}}})
+		assert.Equal(t, render.Freshness{MaxAge: time.Minute}, cache.batchFreshness(results, now))
+	})
+}
+
+func TestConditionalRequests(t *testing.T) {
+	router := newWeatherRouter()
+
+	type testRow struct {
+		name         string
+		target       string
+		cacheControl string
+	}
+
+	testTable := []testRow{
+		{name: "cep", target: "/cep/20270150", cacheControl: "public, max-age=86400"},
+		{name: "weather", target: "/weather/20270150", cacheControl: "public, max-age=60"},
+		{name: "batch", target: "/weather?ceps=20270150,01001000", cacheControl: "public, max-age=60"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			first := getWeather(router, tr.target, "")
+			require.Equal(t, http.StatusOK, first.Code)
+			assert.Equal(t, tr.cacheControl, first.Header().Get("Cache-Control"))
+			etag := first.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+
+			r := httptest.NewRequest(http.MethodGet, tr.target, nil)
+			r.Header.Set("If-None-Match", etag)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, r)
+
+			assert.Equal(t, http.StatusNotModified, recorder.Code)
+			assert.Equal(t, etag, recorder.Header().Get("ETag"))
+			assert.Empty(t, recorder.Body.String())
+		})
+	}
+}
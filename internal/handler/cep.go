@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/render"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"strings"
+)
+
+// getCEPResponse is the location of a postal code
+type getCEPResponse struct {
+	XMLName  xml.Name         `json:"-" xml:"address"`
+	CEP      string           `json:"cep" xml:"cep"`
+	Location locationResponse `json:"location" xml:"location"`
+	Debug    *debugResponse   `json:"debug,omitempty" xml:"debug,omitempty"`
+}
+
+// CSV writes the location as a single row
+func (resp getCEPResponse) CSV() [][]string {
+	return [][]string{
+		{"cep", "city", "state", "country"},
+		{resp.CEP, resp.Location.City, resp.Location.State, resp.Location.Country},
+	}
+}
+
+// Proto converts the response to the message of the gRPC GetCEP call
+func (resp getCEPResponse) Proto() proto.Message {
+	l := resp.Location
+	return &weatherv1.GetCEPResponse{Location: &weatherv1.Location{City: l.City, State: l.State, Country: l.Country}}
+}
+
+// HandleGetCEP handles the request to get the location of a CEP
+func (h *WeatherHandler) HandleGetCEP(w http.ResponseWriter, r *http.Request) {
+	cep := chi.URLParam(r, "cep")
+	ctx, end := tracing.Start(r.Context(), "WeatherHandler.HandleGetCEP", tracing.CEP(cep))
+	defer end(nil)
+
+	h.handleLocation(w, r.WithContext(ctx), usecase.DefaultCountry, cep)
+}
+
+// HandleGetPostalCode handles the request to get the location of a postal code of a given country
+func (h *WeatherHandler) HandleGetPostalCode(w http.ResponseWriter, r *http.Request) {
+	country, postalCode := chi.URLParam(r, "country"), chi.URLParam(r, "postalcode")
+	ctx, end := tracing.Start(r.Context(), "WeatherHandler.HandleGetPostalCode", tracing.CEP(postalCode), tracing.KeyCountry.String(country))
+	defer end(nil)
+
+	h.handleLocation(w, r.WithContext(ctx), country, postalCode)
+}
+
+// handleLocation looks up the location of a postal code, which is cached much longer than the weather
+func (h *WeatherHandler) handleLocation(w http.ResponseWriter, r *http.Request, country, postalCode string) {
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
+	defer cancel()
+
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	location, err := h.cepUseCases.GetLocationByPostalCode(ctx, country, postalCode)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	response := getCEPResponse{
+		CEP:      postalCode,
+		Location: locationResponse{City: location.Localidade, State: location.Uf, Country: strings.ToUpper(country)},
+	}
+	if h.debugRequested(r) {
+		response.Debug = newDebugResponse(r)
+		sendDebug(w, r, format, response)
+		return
+	}
+	render.SendCached(w, r, format, response, render.Freshness{MaxAge: h.cache.Load().CEPMaxAge})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/render"
+	"github.com/caricciy/go-weather/internal/timing"
+	"net/http"
+	"strconv"
+)
+
+// debugResponse breaks a lookup down into its timed phases, it is only sent to admins asking for it with ?debug=1
+type debugResponse struct {
+	Steps []debugStepResponse `json:"steps" xml:"step"`
+}
+
+// debugStepResponse is a phase of a lookup and the provider that served it
+type debugStepResponse struct {
+	Phase      string  `json:"phase" xml:"phase"`
+	Provider   string  `json:"provider,omitempty" xml:"provider,omitempty"`
+	DurationMs float64 `json:"duration_ms" xml:"duration_ms"`
+}
+
+// EnableDebug lets the requests bearing adminToken ask for the timing breakdown of a lookup with ?debug=1.
+// It may be called again to rotate the token while the handler serves requests, an empty token disables the breakdown.
+func (h *WeatherHandler) EnableDebug(adminToken string) {
+	h.adminToken.Store(&adminToken)
+}
+
+// debugRequested tells whether the request asks for the debug breakdown and bears the admin token
+func (h *WeatherHandler) debugRequested(r *http.Request) bool {
+	if debug, _ := strconv.ParseBool(r.URL.Query().Get("debug")); !debug {
+		return false
+	}
+	return bearsAdminToken(r, h.adminToken.Load())
+}
+
+// newDebugResponse describes the phases the request has timed so far
+func newDebugResponse(r *http.Request) *debugResponse {
+	response := &debugResponse{Steps: []debugStepResponse{}}
+	if collector := timing.FromContext(r.Context()); collector != nil {
+		for _, step := range collector.Steps() {
+			response.Steps = append(response.Steps, debugStepResponse{
+				Phase:      step.Phase,
+				Provider:   step.Provider,
+				DurationMs: logging.Milliseconds(step.Duration),
+			})
+		}
+	}
+	return response
+}
+
+// sendDebug sends a lookup carrying its debug breakdown, which changes on every request and must not be cached
+func sendDebug(w http.ResponseWriter, r *http.Request, format render.Format, resp any) {
+	w.Header().Set("Cache-Control", "no-store")
+	render.Send(w, r, format, resp, http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/problem"
+	"google.golang.org/protobuf/proto"
+	"strconv"
+	"strings"
+)
+
+// csvHeader is the header of the weather CSV. The columns are the same for every unit system, those outside the requested system are empty.
+var csvHeader = []string{
+	"cep", "city", "state", "country",
+	"temp_C", "temp_F", "temp_K",
+	"wind_kph", "wind_mph", "wind_ms",
+	"pressure_mb", "pressure_inHg", "pressure_Pa",
+	"precip_mm", "precip_in",
+	"condition", "error_code",
+}
+
+// weatherLookup is the lookup of a postal code, err is set when the location step failed
+type weatherLookup struct {
+	postalCode string
+	country    string
+	report     *entity.WeatherReport
+	err        error
+}
+
+// errorCode is the code of the step of the lookup that failed, if any
+func (l weatherLookup) errorCode() problem.Code {
+	switch {
+	case l.err != nil:
+		return problem.CodeOf(l.err)
+	case l.report != nil && l.report.WeatherErr != nil:
+		return problem.CodeOf(l.report.WeatherErr)
+	default:
+		return ""
+	}
+}
+
+// csvRecord flattens a lookup and its converted measurements to a row of the weather CSV
+func (l weatherLookup) csvRecord(weather *getWeatherByCEPResponse) []string {
+	record := make([]string, 0, len(csvHeader))
+	record = append(record, l.postalCode)
+	if l.report != nil && l.report.Location != nil {
+		record = append(record, l.report.Location.Localidade, l.report.Location.Uf, strings.ToUpper(l.country))
+	} else {
+		record = append(record, "", "", "")
+	}
+
+	if weather == nil {
+		weather = &getWeatherByCEPResponse{}
+	}
+	for _, v := range []*float64{
+		weather.Celcius, weather.Fahrenheit, weather.Kelvin,
+		weather.WindKph, weather.WindMph, weather.WindMs,
+		weather.PressureMb, weather.PressureInHg, weather.PressurePa,
+		weather.PrecipMm, weather.PrecipIn,
+	} {
+		record = append(record, csvNumber(v))
+	}
+	return append(record, weather.Condition, string(l.errorCode()))
+}
+
+// proto converts a successful lookup to its protobuf message, the measurements are in canonical units
+func (l weatherLookup) proto() *weatherv1.GetWeatherByCEPResponse {
+	response := &weatherv1.GetWeatherByCEPResponse{}
+	if l.report == nil {
+		return response
+	}
+	if c := l.report.Location; c != nil {
+		response.Location = &weatherv1.Location{City: c.Localidade, State: c.Uf, Country: strings.ToUpper(l.country)}
+	}
+	if w := l.report.Weather; w != nil {
+		response.Weather = &weatherv1.Weather{
+			TempC:      w.Celcius,
+			TempF:      w.Fahrenheit,
+			TempK:      w.Kelvin,
+			WindKph:    w.WindKph,
+			PressureMb: w.PressureMb,
+			PrecipMm:   w.PrecipMm,
+			Condition:  w.Condition,
+		}
+	}
+	return response
+}
+
+func csvNumber(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// CSV writes the weather of a lookup as a single row
+func (resp getWeatherByCEPResponse) CSV() [][]string {
+	return [][]string{csvHeader, resp.lookup.csvRecord(&resp)}
+}
+
+// Proto converts the response to the message of the gRPC GetWeatherByCEP call
+func (resp getWeatherByCEPResponse) Proto() proto.Message {
+	return resp.lookup.proto()
+}
+
+// CSV writes a row per CEP of the batch, in the order of the request
+func (resp batchWeatherResponse) CSV() [][]string {
+	records := [][]string{csvHeader}
+	for _, result := range resp.Results {
+		records = append(records, result.lookup.csvRecord(result.Weather))
+	}
+	return records
+}
+
+// Proto converts the response to the message of the gRPC BatchGetWeatherByCEP call
+func (resp batchWeatherResponse) Proto() proto.Message {
+	response := &weatherv1.BatchGetWeatherByCEPResponse{}
+	for _, result := range resp.Results {
+		item := &weatherv1.WeatherResult{PostalCode: &weatherv1.PostalCode{Cep: result.CEP, Country: result.lookup.country}}
+		if result.Error != nil {
+			item.Result = &weatherv1.WeatherResult_Error{Error: &weatherv1.Error{Code: string(result.Error.Code), Message: result.Error.Detail}}
+		} else {
+			item.Result = &weatherv1.WeatherResult_Response{Response: result.lookup.proto()}
+		}
+		response.Results = append(response.Results, item)
+	}
+	return response
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// TestResponsesMatchSpec fails when a response struct drifts from its schema in the OpenAPI document
+func TestResponsesMatchSpec(t *testing.T) {
+	testTable := []struct {
+		schema   string
+		response any
+	}{
+		{"Weather", getWeatherByCEPResponse{}},
+		{"Location", locationResponse{}},
+		{"ComponentStatus", componentStatusResponse{}},
+		{"ComponentError", componentErrorResponse{}},
+		{"Address", getCEPResponse{}},
+		{"Debug", debugResponse{}},
+		{"DebugStep", debugStepResponse{}},
+		{"WeatherBatch", batchWeatherResponse{}},
+		{"WeatherBatchResult", batchWeatherResult{}},
+		{"Problem", problemResponse{}},
+		{"WebhookRule", webhookRuleMessage{}},
+		{"WebhookRequest", createWebhookRequest{}},
+		{"Webhook", webhookResponse{}},
+		{"WebhookList", listWebhooksResponse{}},
+		{"WebhookTest", testWebhookResponse{}},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.schema, func(t *testing.T) {
+			drift, err := docs.SchemaDrift(tr.schema, tr.response)
+			assert.NoError(t, err)
+			assert.Empty(t, drift)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"runtime/debug"
+)
+
+// routeMethods are the methods reported in the Allow header of a 405
+var routeMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// HandleNotFound answers the requests to a path without route
+func HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	sendProblem(w, r, problem.CodeNotFound)
+}
+
+// NewMethodNotAllowedHandler answers the requests to a route that does not serve their method,
+// listing in the Allow header the methods routes serves for the path
+func NewMethodNotAllowedHandler(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, method := range routeMethods {
+			if routes.Match(chi.NewRouteContext(), method, r.URL.Path) {
+				w.Header().Add("Allow", method)
+			}
+		}
+		sendProblem(w, r, problem.CodeMethodNotAllowed)
+	}
+}
+
+// Recoverer logs the panics of the handlers with their stack and answers them as internal errors.
+// http.ErrAbortHandler is panicked again so the server aborts the response as intended.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+
+			logging.FromContext(r.Context()).Error("Request panicked", "panic", rvr, "stack", string(debug.Stack()))
+			// An upgraded connection is no longer HTTP, there is nothing to answer on it
+			if r.Header.Get("Connection") != "Upgrade" {
+				sendProblem(w, r, problem.CodeInternal)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
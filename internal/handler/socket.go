@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/caricciy/go-weather/internal/live"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/units"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultPingInterval is how often idle sockets are pinged when no interval is configured
+	DefaultPingInterval = 30 * time.Second
+	// DefaultMaxSubscriptions is the number of CEPs a socket can follow when no limit is configured
+	DefaultMaxSubscriptions = 50
+
+	// socketQueueSize bounds the messages waiting to be written to a socket.
+	// Weather updates of a CEP replace each other, so it only fills up when a client sends requests without reading.
+	socketQueueSize    = 256
+	socketWriteTimeout = 10 * time.Second
+	socketReadLimit    = 4096
+)
+
+// Message types of the weather socket
+const (
+	socketSubscribe    = "subscribe"
+	socketUnsubscribe  = "unsubscribe"
+	socketSubscribed   = "subscribed"
+	socketUnsubscribed = "unsubscribed"
+	socketWeather      = "weather"
+	socketError        = "error"
+)
+
+// socketRequest is a message sent by a client
+type socketRequest struct {
+	Type string `json:"type"`
+	CEP  string `json:"cep"`
+}
+
+// socketMessage is a message sent to a client, weather has the body of a partial lookup
+type socketMessage struct {
+	Type    string                   `json:"type"`
+	CEP     string                   `json:"cep,omitempty"`
+	ID      string                   `json:"id,omitempty"`
+	Weather *getWeatherByCEPResponse `json:"weather,omitempty"`
+	Error   *componentErrorResponse  `json:"error,omitempty"`
+}
+
+type SocketHandler struct {
+	hub *live.Hub
+	// precision is the number of decimal places of every measurement in the messages
+	precision    int
+	pingInterval time.Duration
+	// maxSubscriptions is swapped by a reload of the configuration while the handler serves sockets
+	maxSubscriptions atomic.Int64
+	// originPatterns are the hosts of other origins allowed to open a socket
+	originPatterns []string
+}
+
+func NewSocketHandler(hub *live.Hub, precision int, pingInterval time.Duration, maxSubscriptions int, originPatterns []string) *SocketHandler {
+	if pingInterval <= 0 {
+		pingInterval = DefaultPingInterval
+	}
+	h := &SocketHandler{
+		hub:            hub,
+		precision:      precision,
+		pingInterval:   pingInterval,
+		originPatterns: originPatterns,
+	}
+	h.SetMaxSubscriptions(maxSubscriptions)
+	return h
+}
+
+// SetMaxSubscriptions replaces the number of CEPs a socket may follow, the default when it is not positive.
+// The sockets following more CEPs keep them, they may not subscribe to others until they are back under the limit.
+func (h *SocketHandler) SetMaxSubscriptions(maxSubscriptions int) {
+	if maxSubscriptions <= 0 {
+		maxSubscriptions = DefaultMaxSubscriptions
+	}
+	h.maxSubscriptions.Store(int64(maxSubscriptions))
+}
+
+// HandleWeatherSocket upgrades the request to a WebSocket where the client subscribes to the live weather of CEPs
+func (h *SocketHandler) HandleWeatherSocket(w http.ResponseWriter, r *http.Request) {
+	system, err := units.ParseSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		sendProblem(w, r, problem.CodeUnitsInvalid)
+		return
+	}
+
+	// A hijacked connection may keep the server deadlines, see http.Hijacker
+	clearDeadlines(r, http.NewResponseController(w))
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.originPatterns})
+	if err != nil {
+		// Accept has already answered the request
+		return
+	}
+	conn.SetReadLimit(socketReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	c := &socketConn{
+		handler:       h,
+		conn:          conn,
+		r:             r,
+		system:        system,
+		cancel:        cancel,
+		subscriptions: make(map[string]*socketSubscription),
+		queued:        make(map[string]int),
+		wake:          make(chan struct{}, 1),
+		closeStatus:   websocket.StatusNormalClosure,
+	}
+	c.serve(ctx)
+}
+
+// socketConn is the state of a socket
+type socketConn struct {
+	handler *SocketHandler
+	conn    *websocket.Conn
+	r       *http.Request
+	system  units.System
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	mu            sync.Mutex
+	closed        bool
+	subscriptions map[string]*socketSubscription
+	queue         []socketMessage
+	// queued is the position in queue of the weather message waiting to be written for a CEP
+	queued      map[string]int
+	wake        chan struct{}
+	closeStatus websocket.StatusCode
+	closeReason string
+}
+
+// socketSubscription is the subscription of a CEP, sub is nil until the first snapshot is ready
+type socketSubscription struct {
+	sub *live.Subscription
+}
+
+func (c *socketConn) serve(ctx context.Context) {
+	c.wg.Add(3)
+	go c.readLoop(ctx)
+	go c.writeLoop(ctx)
+	go c.pingLoop(ctx)
+
+	<-ctx.Done()
+
+	c.mu.Lock()
+	c.closed = true
+	subscriptions := c.subscriptions
+	c.subscriptions = make(map[string]*socketSubscription)
+	status, reason := c.closeStatus, c.closeReason
+	c.mu.Unlock()
+
+	for _, entry := range subscriptions {
+		if entry.sub != nil {
+			entry.sub.Close()
+		}
+	}
+
+	c.conn.Close(status, reason)
+	c.wg.Wait()
+}
+
+// fail closes the socket with a status, the first reason wins
+func (c *socketConn) fail(status websocket.StatusCode, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failLocked(status, reason)
+}
+
+func (c *socketConn) failLocked(status websocket.StatusCode, reason string) {
+	if !c.closed && c.closeReason == "" {
+		c.closeStatus, c.closeReason = status, reason
+	}
+	c.cancel()
+}
+
+// readLoop reads the requests of the client. It doesn't use ctx, so the close handshake can still be read.
+func (c *socketConn) readLoop(ctx context.Context) {
+	defer c.wg.Done()
+	defer c.cancel()
+
+	for {
+		typ, data, err := c.conn.Read(context.Background())
+		if err != nil {
+			return
+		}
+
+		var req socketRequest
+		if typ != websocket.MessageText || json.Unmarshal(data, &req) != nil {
+			c.enqueue(socketMessage{Type: socketError, Error: newComponentError(c.r, problem.CodeMessageInvalid)})
+			continue
+		}
+
+		switch req.Type {
+		case socketSubscribe:
+			c.subscribe(ctx, req.CEP)
+		case socketUnsubscribe:
+			c.unsubscribe(req.CEP)
+		default:
+			c.enqueue(socketMessage{Type: socketError, CEP: req.CEP, Error: newComponentError(c.r, problem.CodeMessageInvalid)})
+		}
+	}
+}
+
+// writeLoop writes the queued messages, a client that doesn't read them in time is disconnected
+func (c *socketConn) writeLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		}
+
+		c.mu.Lock()
+		batch := c.queue
+		c.queue = nil
+		clear(c.queued)
+		c.mu.Unlock()
+
+		for _, msg := range batch {
+			if err := c.write(ctx, msg); err != nil {
+				c.fail(websocket.StatusPolicyViolation, "write timeout")
+				return
+			}
+		}
+	}
+}
+
+func (c *socketConn) write(ctx context.Context, msg socketMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+	defer cancel()
+	return wsjson.Write(ctx, c.conn, msg)
+}
+
+// pingLoop closes the socket when the client stops answering pings
+func (c *socketConn) pingLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.handler.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, c.handler.pingInterval)
+			err := c.conn.Ping(pingCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				c.fail(websocket.StatusPolicyViolation, "ping timeout")
+				return
+			}
+		}
+	}
+}
+
+// subscribe follows a CEP, the first snapshot is fetched in the background so the other requests aren't held
+func (c *socketConn) subscribe(ctx context.Context, cep string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.subscriptions[cep]; ok {
+		c.enqueueLocked(socketMessage{Type: socketSubscribed, CEP: cep})
+		return
+	}
+	if int64(len(c.subscriptions)) >= c.handler.maxSubscriptions.Load() {
+		c.enqueueLocked(socketMessage{Type: socketError, CEP: cep, Error: newComponentError(c.r, problem.CodeSubscriptionLimit)})
+		return
+	}
+
+	entry := &socketSubscription{}
+	c.subscriptions[cep] = entry
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		sub, err := c.handler.hub.Subscribe(ctx, usecase.DefaultCountry, cep)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		// The CEP was unsubscribed or the socket closed in the meantime
+		if c.subscriptions[cep] != entry {
+			if sub != nil {
+				sub.Close()
+			}
+			return
+		}
+		if err != nil {
+			delete(c.subscriptions, cep)
+			if !errors.Is(err, context.Canceled) {
+				logError(c.r, err)
+				c.enqueueLocked(socketMessage{Type: socketError, CEP: cep, Error: newComponentError(c.r, problem.CodeOf(err))})
+			}
+			return
+		}
+
+		entry.sub = sub
+		c.enqueueLocked(socketMessage{Type: socketSubscribed, CEP: cep})
+		c.enqueueLocked(c.weatherMessage(cep, sub.Current()))
+
+		c.wg.Add(1)
+		go c.forward(cep, entry)
+	}()
+}
+
+// forward queues the updates of a subscription until it is closed
+func (c *socketConn) forward(cep string, entry *socketSubscription) {
+	defer c.wg.Done()
+
+	for update := range entry.sub.Updates() {
+		c.mu.Lock()
+		if c.subscriptions[cep] == entry {
+			c.enqueueLocked(c.weatherMessage(cep, update))
+		}
+		c.mu.Unlock()
+	}
+
+	// The updates also end when the server shuts down
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subscriptions[cep] == entry {
+		c.failLocked(websocket.StatusGoingAway, "server shutting down")
+	}
+}
+
+func (c *socketConn) unsubscribe(cep string) {
+	c.mu.Lock()
+	entry, ok := c.subscriptions[cep]
+	delete(c.subscriptions, cep)
+	c.enqueueLocked(socketMessage{Type: socketUnsubscribed, CEP: cep})
+	c.mu.Unlock()
+
+	if ok && entry.sub != nil {
+		entry.sub.Close()
+	}
+}
+
+func (c *socketConn) weatherMessage(cep string, update live.Update) socketMessage {
+	response := newWeatherResponse(c.r, update.Report, usecase.DefaultCountry, c.system, c.handler.precision, true)
+	return socketMessage{Type: socketWeather, CEP: cep, ID: update.ID, Weather: &response}
+}
+
+func (c *socketConn) enqueue(msg socketMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enqueueLocked(msg)
+}
+
+// enqueueLocked queues a message for the writer, replacing the weather message of the CEP still waiting to be written
+func (c *socketConn) enqueueLocked(msg socketMessage) {
+	if c.closed {
+		return
+	}
+	if msg.Type == socketWeather {
+		if i, ok := c.queued[msg.CEP]; ok {
+			c.queue[i] = msg
+			return
+		}
+	}
+	if len(c.queue) >= socketQueueSize {
+		c.failLocked(websocket.StatusPolicyViolation, "too many pending messages")
+		return
+	}
+	if msg.Type == socketWeather {
+		c.queued[msg.CEP] = len(c.queue)
+	}
+	c.queue = append(c.queue, msg)
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
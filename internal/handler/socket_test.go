@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"github.com/caricciy/go-weather/internal/live"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSocketServer serves the socket with server timeouts much shorter than the test and a limit of 2 subscriptions
+func newSocketServer(t *testing.T, weatherRepository *fakeWeatherRepository) (*httptest.Server, *live.Hub) {
+	hub := live.NewHub(usecase.NewWeatherUseCases(fakeCEPRepository{}, weatherRepository), 20*time.Millisecond)
+	t.Cleanup(func() { hub.Shutdown(context.Background()) })
+
+	router := chi.NewRouter()
+	router.Get("/weather/ws", NewSocketHandler(hub, 2, 30*time.Millisecond, 2, nil).HandleWeatherSocket)
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+// testSocket is a client that keeps reading, so it answers the pings, and queues the messages it gets
+type testSocket struct {
+	conn     *websocket.Conn
+	messages chan socketMessage
+	closeErr error
+}
+
+func dialSocket(t *testing.T, server *httptest.Server) *testSocket {
+	conn, _, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http")+"/weather/ws?units=metric", nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.CloseNow() })
+
+	s := &testSocket{conn: conn, messages: make(chan socketMessage, 64)}
+	go func() {
+		defer close(s.messages)
+		for {
+			var msg socketMessage
+			if err := wsjson.Read(context.Background(), conn, &msg); err != nil {
+				s.closeErr = err
+				return
+			}
+			s.messages <- msg
+		}
+	}()
+	return s
+}
+
+func (s *testSocket) send(t *testing.T, message string) {
+	require.NoError(t, s.conn.Write(context.Background(), websocket.MessageText, []byte(message)))
+}
+
+func (s *testSocket) receive(t *testing.T) socketMessage {
+	select {
+	case msg, ok := <-s.messages:
+		require.True(t, ok, "socket closed: %v", s.closeErr)
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+		return socketMessage{}
+	}
+}
+
+// receiveType skips the messages up to the next one of a type and CEP
+func (s *testSocket) receiveType(t *testing.T, typ, cep string) socketMessage {
+	for {
+		if msg := s.receive(t); msg.Type == typ && msg.CEP == cep {
+			return msg
+		}
+	}
+}
+
+func TestSocketFollowsSeveralCEPs(t *testing.T) {
+	weatherRepository := &fakeWeatherRepository{}
+	weatherRepository.celcius.Store(math.Float64bits(25))
+	server, _ := newSocketServer(t, weatherRepository)
+	conn := dialSocket(t, server)
+
+	conn.send(t, `{"type":"subscribe","cep":"01001000"}`)
+	conn.send(t, `{"type":"subscribe","cep":"20270150"}`)
+
+	// The subscriptions are resolved concurrently, so their messages come in any order
+	weather := make(map[string]socketMessage)
+	for len(weather) < 2 {
+		if msg := conn.receive(t); msg.Type == socketWeather {
+			weather[msg.CEP] = msg
+		}
+	}
+	saoPaulo := weather["01001000"]
+	assert.Equal(t, "São Paulo", saoPaulo.Weather.Location.City)
+	assert.Equal(t, 25.0, *saoPaulo.Weather.Celcius)
+	assert.Nil(t, saoPaulo.Weather.Fahrenheit)
+	assert.Equal(t, "Rio de Janeiro", weather["20270150"].Weather.Location.City)
+
+	// Past the server timeouts the socket keeps receiving the changes
+	time.Sleep(250 * time.Millisecond)
+	weatherRepository.celcius.Store(math.Float64bits(30))
+
+	update := conn.receiveType(t, socketWeather, "01001000")
+	assert.NotEqual(t, saoPaulo.ID, update.ID)
+	assert.Equal(t, 30.0, *update.Weather.Celcius)
+
+	conn.send(t, `{"type":"unsubscribe","cep":"20270150"}`)
+	conn.receiveType(t, socketUnsubscribed, "20270150")
+}
+
+func TestSocketErrors(t *testing.T) {
+	server, _ := newSocketServer(t, &fakeWeatherRepository{})
+	conn := dialSocket(t, server)
+
+	type testRow struct {
+		name     string
+		message  string
+		wantCEP  string
+		wantCode problem.Code
+	}
+
+	rows := []testRow{
+		{name: "invalid json", message: `{"type":`, wantCode: problem.CodeMessageInvalid},
+		{name: "unknown type", message: `{"type":"follow","cep":"01001000"}`, wantCEP: "01001000", wantCode: problem.CodeMessageInvalid},
+		{name: "invalid cep", message: `{"type":"subscribe","cep":"123"}`, wantCEP: "123", wantCode: problem.CodeCEPInvalid},
+		{name: "unknown cep", message: `{"type":"subscribe","cep":"99999999"}`, wantCEP: "99999999", wantCode: problem.CodeCEPNotFound},
+	}
+
+	for _, row := range rows {
+		t.Run(row.name, func(t *testing.T) {
+			conn.send(t, row.message)
+
+			msg := conn.receive(t)
+			assert.Equal(t, socketError, msg.Type)
+			assert.Equal(t, row.wantCEP, msg.CEP)
+			assert.Equal(t, row.wantCode, msg.Error.Code)
+		})
+	}
+}
+
+func TestSocketSubscriptionLimit(t *testing.T) {
+	server, _ := newSocketServer(t, &fakeWeatherRepository{})
+	conn := dialSocket(t, server)
+
+	conn.send(t, `{"type":"subscribe","cep":"01001000"}`)
+	conn.receiveType(t, socketSubscribed, "01001000")
+	conn.send(t, `{"type":"subscribe","cep":"20270150"}`)
+	conn.receiveType(t, socketSubscribed, "20270150")
+
+	conn.send(t, `{"type":"subscribe","cep":"99999999"}`)
+	msg := conn.receiveType(t, socketError, "99999999")
+	assert.Equal(t, problem.CodeSubscriptionLimit, msg.Error.Code)
+}
+
+func TestSocketClosesOnShutdown(t *testing.T) {
+	server, hub := newSocketServer(t, &fakeWeatherRepository{})
+	conn := dialSocket(t, server)
+
+	conn.send(t, `{"type":"subscribe","cep":"01001000"}`)
+	conn.receiveType(t, socketSubscribed, "01001000")
+
+	require.NoError(t, hub.Shutdown(context.Background()))
+
+	for {
+		select {
+		case _, ok := <-conn.messages:
+			if !ok {
+				assert.Equal(t, websocket.StatusGoingAway, websocket.CloseStatus(conn.closeErr))
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("socket not closed")
+		}
+	}
+}
+
+func TestSocketQueueCoalescesWeather(t *testing.T) {
+	c := &socketConn{
+		cancel: func() {},
+		queued: make(map[string]int),
+		wake:   make(chan struct{}, 1),
+	}
+
+	c.enqueue(socketMessage{Type: socketSubscribed, CEP: "01001000"})
+	c.enqueue(socketMessage{Type: socketWeather, CEP: "01001000", ID: "first"})
+	c.enqueue(socketMessage{Type: socketWeather, CEP: "20270150", ID: "other"})
+	c.enqueue(socketMessage{Type: socketWeather, CEP: "01001000", ID: "latest"})
+
+	require.Len(t, c.queue, 3)
+	assert.Equal(t, socketSubscribed, c.queue[0].Type)
+	assert.Equal(t, "latest", c.queue[1].ID)
+	assert.Equal(t, "other", c.queue[2].ID)
+
+	for i := len(c.queue); i < socketQueueSize; i++ {
+		c.enqueue(socketMessage{Type: socketUnsubscribed, CEP: "01001000"})
+	}
+	assert.Empty(t, c.closeReason)
+	c.enqueue(socketMessage{Type: socketUnsubscribed, CEP: "01001000"})
+	assert.Equal(t, websocket.StatusPolicyViolation, c.closeStatus)
+}
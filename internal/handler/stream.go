@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/live"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/units"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"time"
+)
+
+// DefaultHeartbeat is how often a comment is sent to keep idle streams open when no heartbeat is configured
+const DefaultHeartbeat = 15 * time.Second
+
+// weatherEvent is the SSE event type of the weather updates
+const weatherEvent = "weather"
+
+type StreamHandler struct {
+	hub *live.Hub
+	// precision is the number of decimal places of every measurement in the events
+	precision int
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(hub *live.Hub, precision int, heartbeat time.Duration) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	return &StreamHandler{
+		hub:       hub,
+		precision: precision,
+		heartbeat: heartbeat,
+	}
+}
+
+// HandleStreamWeatherByCEP streams the weather of a CEP as Server-Sent Events.
+// An event is sent when the conditions change, a client resuming with Last-Event-ID
+// only gets the current conditions if they changed since its last event.
+func (h *StreamHandler) HandleStreamWeatherByCEP(w http.ResponseWriter, r *http.Request) {
+	system, err := units.ParseSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		sendProblem(w, r, problem.CodeUnitsInvalid)
+		return
+	}
+
+	sub, err := h.hub.Subscribe(r.Context(), usecase.DefaultCountry, chi.URLParam(r, "cep"))
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	clearDeadlines(r, rc)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Clients reconnect after one refresh interval, there is nothing new to get sooner
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", h.hub.Interval().Milliseconds()); err != nil {
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if current := sub.Current(); current.ID != lastID {
+		if err := h.sendEvent(w, r, current, system); err != nil {
+			return
+		}
+		lastID = current.ID
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case update, ok := <-sub.Updates():
+			if !ok {
+				return
+			}
+			if update.ID == lastID {
+				continue
+			}
+			if err := h.sendEvent(w, r, update, system); err != nil {
+				return
+			}
+			lastID = update.ID
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// sendEvent writes an update as a weather event, with the same body as a partial lookup
+func (h *StreamHandler) sendEvent(w http.ResponseWriter, r *http.Request, update live.Update, system units.System) error {
+	data, err := json.Marshal(newWeatherResponse(r, update.Report, usecase.DefaultCountry, system, h.precision, true))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", update.ID, weatherEvent, data)
+	return err
+}
+
+// clearDeadlines lets a long-lived connection outlive the server timeouts, which are meant for regular requests
+func clearDeadlines(r *http.Request, rc *http.ResponseController) {
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logging.FromContext(r.Context()).Warn("Could not clear the write deadline of a connection", "error", err)
+	}
+	if err := rc.SetReadDeadline(time.Time{}); err != nil {
+		logging.FromContext(r.Context()).Warn("Could not clear the read deadline of a connection", "error", err)
+	}
+}
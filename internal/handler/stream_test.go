@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/live"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeCEPRepository struct{}
+
+func (fakeCEPRepository) GetCEP(_ context.Context, cep string) (*entity.CEP, error) {
+	switch cep {
+	case "01001000":
+		return &entity.CEP{Localidade: "São Paulo", Uf: "SP"}, nil
+	case "20270150":
+		return &entity.CEP{Localidade: "Rio de Janeiro", Uf: "RJ"}, nil
+	}
+	return nil, &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")}
+}
+
+// fakeWeatherRepository answers the temperature it holds
+type fakeWeatherRepository struct {
+	celcius atomic.Uint64
+}
+
+func (f *fakeWeatherRepository) GetWeatherInfo(_ context.Context, _ *entity.CEP) (*entity.WeatherInfo, error) {
+	return &entity.WeatherInfo{Celcius: math.Float64frombits(f.celcius.Load()), Condition: "Sunny", Valid: true}, nil
+}
+
+// sseEvent is an event or a comment read from a stream
+type sseEvent struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+// newStreamServer serves the stream with server timeouts much shorter than the test
+func newStreamServer(t *testing.T, weatherRepository *fakeWeatherRepository) *httptest.Server {
+	hub := live.NewHub(usecase.NewWeatherUseCases(fakeCEPRepository{}, weatherRepository), 20*time.Millisecond)
+	t.Cleanup(func() { hub.Shutdown(context.Background()) })
+
+	router := chi.NewRouter()
+	router.Use(middleware.Logger)
+	router.Get("/weather/{cep}/stream", NewStreamHandler(hub, 2, 30*time.Millisecond).HandleStreamWeatherByCEP)
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openStream(t *testing.T, url, lastEventID string) (*http.Response, <-chan sseEvent) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				ev.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return resp, events
+}
+
+// nextWeatherEvent skips the heartbeats and the retry field up to the next weather event
+func nextWeatherEvent(t *testing.T, events <-chan sseEvent, timeout time.Duration) sseEvent {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev, ok := <-events:
+			require.True(t, ok, "stream closed")
+			if ev.event == weatherEvent {
+				return ev
+			}
+		case <-deadline:
+			t.Fatal("no weather event")
+		}
+	}
+}
+
+func TestStreamOutlivesServerTimeouts(t *testing.T) {
+	weatherRepository := &fakeWeatherRepository{}
+	weatherRepository.celcius.Store(math.Float64bits(25))
+	server := newStreamServer(t, weatherRepository)
+
+	resp, events := openStream(t, server.URL+"/weather/01001000/stream?units=metric", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	first := nextWeatherEvent(t, events, time.Second)
+	var body getWeatherByCEPResponse
+	require.NoError(t, json.Unmarshal([]byte(first.data), &body))
+	assert.Equal(t, 25.0, *body.Celcius)
+	assert.Nil(t, body.Fahrenheit)
+	assert.Equal(t, "São Paulo", body.Location.City)
+
+	// Past the server timeouts the stream keeps sending heartbeats and changes
+	time.Sleep(250 * time.Millisecond)
+	weatherRepository.celcius.Store(math.Float64bits(30))
+
+	second := nextWeatherEvent(t, events, time.Second)
+	assert.NotEqual(t, first.id, second.id)
+	require.NoError(t, json.Unmarshal([]byte(second.data), &body))
+	assert.Equal(t, 30.0, *body.Celcius)
+}
+
+func TestStreamResumesFromLastEventID(t *testing.T) {
+	weatherRepository := &fakeWeatherRepository{}
+	server := newStreamServer(t, weatherRepository)
+
+	_, events := openStream(t, server.URL+"/weather/01001000/stream", "")
+	first := nextWeatherEvent(t, events, time.Second)
+
+	// Conditions didn't change since the last event, so only heartbeats are sent
+	_, resumed := openStream(t, server.URL+"/weather/01001000/stream", first.id)
+	timeout := time.After(100 * time.Millisecond)
+	heartbeats := 0
+	for done := false; !done; {
+		select {
+		case ev := <-resumed:
+			assert.NotEqual(t, weatherEvent, ev.event)
+			if ev.comment == "heartbeat" {
+				heartbeats++
+			}
+		case <-timeout:
+			done = true
+		}
+	}
+	assert.NotZero(t, heartbeats)
+}
+
+func TestStreamInvalidCEP(t *testing.T) {
+	server := newStreamServer(t, &fakeWeatherRepository{})
+
+	resp, err := http.Get(server.URL + "/weather/123/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+}
@@ -2,61 +2,269 @@ package handler
 
 import (
 	"context"
-	"errors"
+	"encoding/xml"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/render"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/units"
 	"github.com/caricciy/go-weather/internal/usecase"
 	"github.com/caricciy/go-weather/internal/util"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// WeatherResponse represents the structure of the weather response
+// WeatherResponse represents the structure of the weather response.
+// Only the fields of the requested unit system are present.
+// Location, Status and WeatherError are only present in partial mode, Debug in debug mode.
 type getWeatherByCEPResponse struct {
-	Celcius    float64 `json:"temp_C"`
-	Fahrenheit float64 `json:"temp_F"`
-	Kelvin     float64 `json:"temp_K"`
+	XMLName      xml.Name          `json:"-" xml:"weather"`
+	Location     *locationResponse `json:"location,omitempty" xml:"location,omitempty"`
+	Celcius      *float64          `json:"temp_C,omitempty" xml:"temp_C,omitempty"`
+	Fahrenheit   *float64          `json:"temp_F,omitempty" xml:"temp_F,omitempty"`
+	Kelvin       *float64          `json:"temp_K,omitempty" xml:"temp_K,omitempty"`
+	WindKph      *float64          `json:"wind_kph,omitempty" xml:"wind_kph,omitempty"`
+	WindMph      *float64          `json:"wind_mph,omitempty" xml:"wind_mph,omitempty"`
+	WindMs       *float64          `json:"wind_ms,omitempty" xml:"wind_ms,omitempty"`
+	PressureMb   *float64          `json:"pressure_mb,omitempty" xml:"pressure_mb,omitempty"`
+	PressureInHg *float64          `json:"pressure_inHg,omitempty" xml:"pressure_inHg,omitempty"`
+	PressurePa   *float64          `json:"pressure_Pa,omitempty" xml:"pressure_Pa,omitempty"`
+	PrecipMm     *float64          `json:"precip_mm,omitempty" xml:"precip_mm,omitempty"`
+	PrecipIn     *float64          `json:"precip_in,omitempty" xml:"precip_in,omitempty"`
+	Condition    string            `json:"condition,omitempty" xml:"condition,omitempty"`
+
+	Status       *componentStatusResponse `json:"status,omitempty" xml:"status,omitempty"`
+	WeatherError *componentErrorResponse  `json:"weather_error,omitempty" xml:"weather_error,omitempty"`
+
+	Debug *debugResponse `json:"debug,omitempty" xml:"debug,omitempty"`
+
+	// lookup is the lookup behind the response, the CSV and protobuf formats describe it in full
+	lookup weatherLookup
+}
+
+type locationResponse struct {
+	City    string `json:"city" xml:"city"`
+	State   string `json:"state,omitempty" xml:"state,omitempty"`
+	Country string `json:"country" xml:"country"`
+}
+
+const (
+	componentOK    = "ok"
+	componentError = "error"
+)
+
+// componentStatusResponse reports the outcome of each step of a partial lookup
+type componentStatusResponse struct {
+	Location string `json:"location" xml:"location"`
+	Weather  string `json:"weather" xml:"weather"`
+}
+
+// componentErrorResponse describes the failure of a step of a partial lookup
+type componentErrorResponse struct {
+	Code   problem.Code `json:"code" xml:"code"`
+	Title  string       `json:"title" xml:"title"`
+	Detail string       `json:"detail" xml:"detail"`
+	Status int          `json:"status" xml:"status"`
 }
 
-type errorResponse struct {
-	Message string `json:"message"`
+// problemResponse is an RFC 7807 problem details body extended with the error code and request ID
+type problemResponse struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      problem.Code `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
 }
 
+// lookupTimeout bounds the lookup of a postal code
+const lookupTimeout = 5 * time.Second
+
 type WeatherHandler struct {
 	cepUseCases *usecase.WeatherUseCases
+	// precision is the number of decimal places of every measurement in the responses
+	precision int
+	// cache and adminToken are swapped by a reload of the configuration while the handler serves requests
+	cache atomic.Pointer[CacheConfig]
+	// adminToken authorizes the debug breakdown of the lookups, which is disabled when it is empty
+	adminToken atomic.Pointer[string]
 }
 
-func NewWeatherHandler(cepUseCases *usecase.WeatherUseCases) *WeatherHandler {
-	return &WeatherHandler{
+func NewWeatherHandler(cepUseCases *usecase.WeatherUseCases, precision int, cache CacheConfig) *WeatherHandler {
+	h := &WeatherHandler{
 		cepUseCases: cepUseCases,
+		precision:   precision,
 	}
+	h.SetCacheConfig(cache)
+	return h
+}
+
+// SetCacheConfig replaces the lifetimes of the responses, it is safe to call while the handler serves requests
+func (h *WeatherHandler) SetCacheConfig(cache CacheConfig) {
+	h.cache.Store(&cache)
 }
 
 // HandleGetWeatherByCEP handles the request to get CEP information
 func (h *WeatherHandler) HandleGetWeatherByCEP(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	cep := chi.URLParam(r, "cep")
+	ctx, end := tracing.Start(r.Context(), "WeatherHandler.HandleGetWeatherByCEP", tracing.CEP(cep))
+	defer end(nil)
+
+	h.handleWeather(w, r.WithContext(ctx), usecase.DefaultCountry, cep)
+}
+
+// HandleGetWeatherByPostalCode handles the request to get weather information for a postal code of a given country
+func (h *WeatherHandler) HandleGetWeatherByPostalCode(w http.ResponseWriter, r *http.Request) {
+	country, postalCode := chi.URLParam(r, "country"), chi.URLParam(r, "postalcode")
+	ctx, end := tracing.Start(r.Context(), "WeatherHandler.HandleGetWeatherByPostalCode", tracing.CEP(postalCode), tracing.KeyCountry.String(country))
+	defer end(nil)
+
+	h.handleWeather(w, r.WithContext(ctx), country, postalCode)
+}
+
+// handleWeather looks up the weather of a postal code.
+// With ?partial=true the resolved location is returned even if the weather step fails,
+// with ?debug=1 and the admin token the response breaks the lookup down into its phases.
+func (h *WeatherHandler) handleWeather(w http.ResponseWriter, r *http.Request, country, postalCode string) {
+	ctx, cancel := context.WithTimeout(r.Context(), lookupTimeout)
 	defer cancel()
 
-	paramCEP := chi.URLParam(r, "cep")
+	format, ok := negotiate(w, r)
+	if !ok {
+		return
+	}
+
+	system, err := units.ParseSystem(r.URL.Query().Get("units"))
+	if err != nil {
+		sendProblem(w, r, problem.CodeUnitsInvalid)
+		return
+	}
+
+	partial, _ := strconv.ParseBool(r.URL.Query().Get("partial"))
 
-	weather, err := h.cepUseCases.GetWeatherByCEP(ctx, paramCEP)
+	report, err := h.cepUseCases.GetWeatherReportByPostalCode(ctx, country, postalCode, partial)
 
 	if err != nil {
-		switch {
-		case errors.Is(err, usecase.ErrInvalidCEP):
-			util.SendJSON(w, errorResponse{"invalid zipcode"}, http.StatusUnprocessableEntity)
-		case errors.Is(err, usecase.ErrCEPNotFound):
-			util.SendJSON(w, errorResponse{"can not find zipcode"}, http.StatusNotFound)
-		default:
-			util.SendJSON(w, errorResponse{"An unexpected error occurred"}, http.StatusInternalServerError)
-		}
+		sendError(w, r, err)
 		return
 	}
-	response := getWeatherByCEPResponse{
-		Celcius:    weather.Celcius,
-		Fahrenheit: weather.Fahrenheit,
-		Kelvin:     weather.Kelvin,
+
+	response := newWeatherResponse(r, report, country, system, h.precision, partial)
+	response.lookup = weatherLookup{postalCode: postalCode, country: country, report: report}
+	if h.debugRequested(r) {
+		response.Debug = newDebugResponse(r)
+		sendDebug(w, r, format, response)
+		return
+	}
+	render.SendCached(w, r, format, response, h.cache.Load().lookupFreshness(response.lookup, time.Now()))
+}
+
+// newWeatherResponse builds the weather response of a report.
+// In partial mode it also describes the location and the outcome of each step.
+func newWeatherResponse(r *http.Request, report *entity.WeatherReport, country string, system units.System, precision int, partial bool) getWeatherByCEPResponse {
+	response := getWeatherByCEPResponse{}
+	if report.Weather != nil {
+		response = weatherResponse(report.Weather, system, precision)
+	}
+
+	if !partial {
+		return response
+	}
+
+	response.Location = &locationResponse{
+		City:    report.Location.Localidade,
+		State:   report.Location.Uf,
+		Country: strings.ToUpper(country),
+	}
+	response.Status = &componentStatusResponse{Location: componentOK, Weather: componentOK}
+	if report.WeatherErr != nil {
+		logError(r, report.WeatherErr)
+		response.Status.Weather = componentError
+		response.WeatherError = newComponentError(r, problem.CodeOf(report.WeatherErr))
 	}
+	return response
+}
+
+// newComponentError describes an error code, localized to the language of the request
+func newComponentError(r *http.Request, code problem.Code) *componentErrorResponse {
+	def := problem.Lookup(code)
+	return &componentErrorResponse{
+		Code:   code,
+		Title:  def.Title,
+		Detail: i18n.Message(i18n.FromContext(r.Context()), def.MessageKey),
+		Status: def.Status,
+	}
+}
+
+func weatherResponse(weather *entity.WeatherInfo, system units.System, precision int) getWeatherByCEPResponse {
+	m := units.Convert(units.Canonical{
+		TempC:      weather.Celcius,
+		WindKph:    weather.WindKph,
+		PressureMb: weather.PressureMb,
+		PrecipMm:   weather.PrecipMm,
+	}, system, precision)
 
-	util.SendJSON(w, response, http.StatusOK)
+	return getWeatherByCEPResponse{
+		Celcius:      m.Celsius,
+		Fahrenheit:   m.Fahrenheit,
+		Kelvin:       m.Kelvin,
+		WindKph:      m.WindKph,
+		WindMph:      m.WindMph,
+		WindMs:       m.WindMs,
+		PressureMb:   m.PressureMb,
+		PressureInHg: m.PressureInHg,
+		PressurePa:   m.PressurePa,
+		PrecipMm:     m.PrecipMm,
+		PrecipIn:     m.PrecipIn,
+		Condition:    weather.Condition,
+	}
+}
+
+// negotiate picks the format of a response, answering 406 when the client accepts none of them
+func negotiate(w http.ResponseWriter, r *http.Request) (render.Format, bool) {
+	format, err := render.Negotiate(r)
+	if err != nil {
+		w.Header().Add("Vary", "Accept")
+		sendProblem(w, r, problem.CodeNotAcceptable)
+		return "", false
+	}
+	return format, true
+}
+
+// sendError logs the full error chain, so the root cause is kept, and sends its problem details
+func sendError(w http.ResponseWriter, r *http.Request, err error) {
+	logError(r, err)
+	sendProblem(w, r, problem.CodeOf(err))
+}
+
+// logError logs server side failures with their full error chain
+func logError(r *http.Request, err error) {
+	code := problem.CodeOf(err)
+	if problem.Lookup(code).Status >= http.StatusInternalServerError {
+		logging.FromContext(r.Context()).Error("Request failed", "code", code, "error", err)
+	}
+}
+
+// sendProblem sends the problem details of an error code, localized to the language of the request
+func sendProblem(w http.ResponseWriter, r *http.Request, code problem.Code) {
+	def := problem.Lookup(code)
+	response := problemResponse{
+		Type:      problem.Type(code),
+		Title:     def.Title,
+		Status:    def.Status,
+		Detail:    i18n.Message(i18n.FromContext(r.Context()), def.MessageKey),
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
 
+	util.SendProblemJSON(w, response, def.Status)
 }
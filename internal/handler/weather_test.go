@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/timing"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newWeatherRouter() *chi.Mux {
+	weatherRepository := &fakeWeatherRepository{}
+	weatherRepository.celcius.Store(math.Float64bits(25))
+	weatherHandler := NewWeatherHandler(usecase.NewWeatherUseCases(fakeCEPRepository{}, weatherRepository), 2, DefaultCacheConfig())
+
+	router := chi.NewRouter()
+	router.Get("/weather", weatherHandler.HandleGetWeatherBatch)
+	router.Get("/weather/{cep}", weatherHandler.HandleGetWeatherByCEP)
+	router.Get("/cep/{cep}", weatherHandler.HandleGetCEP)
+	return router
+}
+
+func getWeather(router http.Handler, target, accept string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestWeatherFormats(t *testing.T) {
+	router := newWeatherRouter()
+
+	type testRow struct {
+		name        string
+		target      string
+		accept      string
+		contentType string
+		expected    string
+	}
+
+	testTable := []testRow{
+		{
+			name:        "json by default",
+			target:      "/weather/20270150?units=metric",
+			contentType: "application/json",
+			expected:    `{"temp_C":25,"condition":"Sunny"}`,
+		},
+		{
+			name:        "xml",
+			target:      "/weather/20270150?units=imperial",
+			accept:      "application/xml",
+			contentType: "application/xml; charset=utf-8",
+			expected:    `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<weather><temp_F>77</temp_F><condition>Sunny</condition></weather>`,
+		},
+		{
+			name:        "csv by parameter",
+			target:      "/weather/20270150?units=metric&format=csv",
+			accept:      "application/json",
+			contentType: "text/csv; charset=utf-8",
+			expected: "cep,city,state,country,temp_C,temp_F,temp_K,wind_kph,wind_mph,wind_ms,pressure_mb,pressure_inHg,pressure_Pa,precip_mm,precip_in,condition,error_code\n" +
+				"20270150,Rio de Janeiro,RJ,BR,25,,,,,,,,,,,Sunny,\n",
+		},
+		{
+			name:        "batch csv",
+			target:      "/weather?ceps=01001000,123&units=si",
+			accept:      "text/csv",
+			contentType: "text/csv; charset=utf-8",
+			expected: "cep,city,state,country,temp_C,temp_F,temp_K,wind_kph,wind_mph,wind_ms,pressure_mb,pressure_inHg,pressure_Pa,precip_mm,precip_in,condition,error_code\n" +
+				"01001000,São Paulo,SP,BR,,,298.15,,,,,,,,,Sunny,\n" +
+				"123,,,,,,,,,,,,,,,,CEP_INVALID\n",
+		},
+		{
+			name:        "batch xml",
+			target:      "/weather?ceps=20270150&ceps=123&units=metric",
+			accept:      "text/xml",
+			contentType: "application/xml; charset=utf-8",
+			expected: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<results>` +
+				`<result><cep>20270150</cep><location><city>Rio de Janeiro</city><state>RJ</state><country>BR</country></location>` +
+				`<weather><temp_C>25</temp_C><condition>Sunny</condition></weather></result>` +
+				`<result><cep>123</cep><error><code>CEP_INVALID</code><title>Invalid CEP</title><detail>invalid zipcode</detail><status>422</status></error></result>` +
+				`</results>`,
+		},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			recorder := getWeather(router, tr.target, tr.accept)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tr.contentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+			assert.Equal(t, tr.expected, recorder.Body.String())
+		})
+	}
+}
+
+func TestWeatherBatchJSON(t *testing.T) {
+	recorder := getWeather(newWeatherRouter(), "/weather?ceps=20270150,99999999&units=metric", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var body batchWeatherResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	require.Len(t, body.Results, 2)
+	assert.Equal(t, "Rio de Janeiro", body.Results[0].Location.City)
+	assert.Equal(t, 25.0, *body.Results[0].Weather.Celcius)
+	assert.Nil(t, body.Results[0].Error)
+	assert.Equal(t, "99999999", body.Results[1].CEP)
+	assert.Equal(t, "CEP_NOT_FOUND", string(body.Results[1].Error.Code))
+}
+
+func TestWeatherProtobuf(t *testing.T) {
+	router := newWeatherRouter()
+
+	recorder := getWeather(router, "/weather/20270150?units=imperial", "application/x-protobuf")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var single weatherv1.GetWeatherByCEPResponse
+	require.NoError(t, proto.Unmarshal(recorder.Body.Bytes(), &single))
+	assert.Equal(t, "Rio de Janeiro", single.GetLocation().GetCity())
+	// Protobuf measurements are in canonical units whatever the unit system
+	assert.Equal(t, 25.0, single.GetWeather().GetTempC())
+
+	recorder = getWeather(router, "/weather?ceps=20270150,123&format=protobuf", "")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var batch weatherv1.BatchGetWeatherByCEPResponse
+	require.NoError(t, proto.Unmarshal(recorder.Body.Bytes(), &batch))
+	require.Len(t, batch.GetResults(), 2)
+	assert.Equal(t, "BR", batch.GetResults()[0].GetPostalCode().GetCountry())
+	assert.Equal(t, "Sunny", batch.GetResults()[0].GetResponse().GetWeather().GetCondition())
+	assert.Equal(t, "CEP_INVALID", batch.GetResults()[1].GetError().GetCode())
+}
+
+func TestWeatherRequestErrors(t *testing.T) {
+	router := newWeatherRouter()
+
+	type testRow struct {
+		name     string
+		target   string
+		accept   string
+		status   int
+		expected string
+	}
+
+	testTable := []testRow{
+		{name: "unsupported accept", target: "/weather/20270150", accept: "application/pdf", status: http.StatusNotAcceptable, expected: "NOT_ACCEPTABLE"},
+		{name: "unsupported format", target: "/weather?ceps=20270150&format=yaml", status: http.StatusNotAcceptable, expected: "NOT_ACCEPTABLE"},
+		{name: "empty batch", target: "/weather?ceps=,", status: http.StatusBadRequest, expected: "BATCH_INVALID"},
+		{name: "batch too large", target: "/weather?ceps=" + strings.Repeat("20270150,", maxBatchSize+1), status: http.StatusBadRequest, expected: "BATCH_INVALID"},
+		{name: "lookup errors stay problem json", target: "/weather/123", accept: "text/csv", status: http.StatusUnprocessableEntity, expected: "CEP_INVALID"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			recorder := getWeather(router, tr.target, tr.accept)
+
+			assert.Equal(t, tr.status, recorder.Code)
+			assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+			var body problemResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			assert.Equal(t, tr.expected, string(body.Code))
+		})
+	}
+}
+
+func TestWeatherDebug(t *testing.T) {
+	weatherRepository := &fakeWeatherRepository{}
+	weatherHandler := NewWeatherHandler(usecase.NewWeatherUseCases(fakeCEPRepository{}, weatherRepository), 2, DefaultCacheConfig())
+	weatherHandler.EnableDebug("admin-token")
+	router := chi.NewRouter()
+	router.Use(timing.Middleware)
+	router.Get("/weather/{cep}", weatherHandler.HandleGetWeatherByCEP)
+	router.Get("/cep/{cep}", weatherHandler.HandleGetCEP)
+
+	type testRow struct {
+		name          string
+		target        string
+		authorization string
+		phases        []string
+	}
+
+	testTable := []testRow{
+		{name: "weather", target: "/weather/20270150?debug=1", authorization: "Bearer admin-token", phases: []string{"validation", "cep", "weather"}},
+		{name: "cep", target: "/cep/20270150?debug=true", authorization: "Bearer admin-token", phases: []string{"validation", "cep"}},
+		{name: "without token", target: "/weather/20270150?debug=1"},
+		{name: "wrong token", target: "/weather/20270150?debug=1", authorization: "Bearer other-token"},
+		{name: "without debug", target: "/weather/20270150", authorization: "Bearer admin-token"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tr.target, nil)
+			r.Header.Set("Authorization", tr.authorization)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, r)
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			assert.Contains(t, recorder.Header().Get("Server-Timing"), "cep;dur=")
+
+			var body struct {
+				Debug *debugResponse `json:"debug"`
+			}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			if tr.phases == nil {
+				assert.Nil(t, body.Debug)
+				assert.NotEqual(t, "no-store", recorder.Header().Get("Cache-Control"))
+				assert.Contains(t, recorder.Header().Get("Server-Timing"), "encode;dur=")
+				return
+			}
+
+			require.NotNil(t, body.Debug)
+			var phases []string
+			for _, step := range body.Debug.Steps {
+				phases = append(phases, step.Phase)
+			}
+			assert.Equal(t, tr.phases, phases)
+			assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+			assert.Empty(t, recorder.Header().Get("ETag"))
+		})
+	}
+}
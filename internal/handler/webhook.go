@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/caricciy/go-weather/internal/util"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// maxWebhookRequestSize bounds the body of a webhook creation
+const maxWebhookRequestSize = 64 << 10
+
+type createWebhookRequest struct {
+	CEP         string             `json:"cep"`
+	Rule        webhookRuleMessage `json:"rule"`
+	CallbackURL string             `json:"callback_url"`
+}
+
+// webhookRuleMessage is a rule such as {"metric": "temp_C", "operator": "gt", "threshold": 35}
+type webhookRuleMessage struct {
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Threshold float64 `json:"threshold"`
+}
+
+// webhookResponse describes a webhook, the secret is only present in the creation response
+type webhookResponse struct {
+	ID          string             `json:"id"`
+	CEP         string             `json:"cep"`
+	Rule        webhookRuleMessage `json:"rule"`
+	CallbackURL string             `json:"callback_url"`
+	Secret      string             `json:"secret,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Firing      bool               `json:"firing"`
+}
+
+type listWebhooksResponse struct {
+	Webhooks []webhookResponse `json:"webhooks"`
+}
+
+type testWebhookResponse struct {
+	Delivered bool `json:"delivered"`
+}
+
+type WebhookHandler struct {
+	webhookUseCases *usecase.WebhookUseCases
+	// adminToken authorizes the management of the webhooks, which is disabled when it is empty.
+	// It may be set again while the handler serves requests.
+	adminToken atomic.Pointer[string]
+}
+
+func NewWebhookHandler(webhookUseCases *usecase.WebhookUseCases) *WebhookHandler {
+	return &WebhookHandler{
+		webhookUseCases: webhookUseCases,
+	}
+}
+
+// SetAdminToken sets the token the requests managing the webhooks must bear, an empty token rejects every request
+func (h *WebhookHandler) SetAdminToken(adminToken string) {
+	h.adminToken.Store(&adminToken)
+}
+
+// RequireAdmin answers 401 to the requests not bearing the admin token in an Authorization: Bearer header.
+// The webhooks make the server post to their callbacks, so only the admin may register, list, test or delete them.
+func (h *WebhookHandler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !bearsAdminToken(r, h.adminToken.Load()) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendProblem(w, r, problem.CodeUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleCreateWebhook registers a webhook and answers its secret, which is not shown again
+func (h *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookRequestSize)).Decode(&req); err != nil {
+		sendProblem(w, r, problem.CodeMessageInvalid)
+		return
+	}
+
+	webhook, err := h.webhookUseCases.CreateWebhook(r.Context(), req.CEP, entity.WebhookRule(req.Rule), req.CallbackURL)
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	response := newWebhookResponse(webhook)
+	response.Secret = webhook.Secret
+	w.Header().Set("Location", "/webhooks/"+webhook.ID)
+	util.SendJSON(w, response, http.StatusCreated)
+}
+
+// HandleListWebhooks lists the webhooks without their secrets
+func (h *WebhookHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookUseCases.ListWebhooks(r.Context())
+	if err != nil {
+		sendError(w, r, err)
+		return
+	}
+
+	response := listWebhooksResponse{Webhooks: make([]webhookResponse, 0, len(webhooks))}
+	for _, webhook := range webhooks {
+		response.Webhooks = append(response.Webhooks, newWebhookResponse(webhook))
+	}
+	util.SendJSON(w, response, http.StatusOK)
+}
+
+func (h *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhookUseCases.DeleteWebhook(r.Context(), chi.URLParam(r, "id")); err != nil {
+		sendError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTestWebhook sends a test delivery to the callback of a webhook
+func (h *WebhookHandler) HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.webhookUseCases.TestWebhook(r.Context(), chi.URLParam(r, "id")); err != nil {
+		sendError(w, r, err)
+		return
+	}
+	util.SendJSON(w, testWebhookResponse{Delivered: true}, http.StatusOK)
+}
+
+func newWebhookResponse(webhook *entity.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:          webhook.ID,
+		CEP:         webhook.CEP,
+		Rule:        webhookRuleMessage(webhook.Rule),
+		CallbackURL: webhook.CallbackURL,
+		CreatedAt:   webhook.CreatedAt,
+		Firing:      webhook.Firing,
+	}
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultProbeInterval is how long the result of a probe is reused before the dependency is probed again
+	DefaultProbeInterval = time.Minute
+	// DefaultProbeTimeout is how long a probe may take before the dependency is considered down
+	DefaultProbeTimeout = 3 * time.Second
+)
+
+// Statuses of the application and of its dependencies
+const (
+	StatusUp       = "UP"
+	StatusDown     = "DOWN"
+	StatusDraining = "DRAINING"
+	// StatusDegraded is a ready application with a non-critical dependency down
+	StatusDegraded = "DEGRADED"
+)
+
+// Probe checks a dependency, returning an error when it cannot be used.
+// A provider answering that the probed resource does not exist is up.
+type Probe func(ctx context.Context) error
+
+// DependencyStatus is the outcome of the last probe of a dependency
+type DependencyStatus struct {
+	Name string
+	// Critical tells whether the application is not ready while the dependency is down
+	Critical  bool
+	Up        bool
+	Latency   time.Duration
+	CheckedAt time.Time
+	// LastError describes the last failure of the dependency, it is kept after the dependency recovers
+	LastError   string
+	LastErrorAt time.Time
+}
+
+// Report is the readiness of the application
+type Report struct {
+	Ready    bool
+	Draining bool
+	// Degraded tells that a non-critical dependency is down, the application is still ready
+	Degraded     bool
+	Dependencies []DependencyStatus
+}
+
+// Checker reports the readiness of the application from probes of its dependencies.
+// Each dependency is probed at most once per interval, concurrent checks share the probe in progress.
+type Checker struct {
+	interval     time.Duration
+	timeout      time.Duration
+	dependencies []*dependency
+	draining     atomic.Bool
+}
+
+type dependency struct {
+	probe Probe
+
+	mu     sync.Mutex
+	status DependencyStatus
+}
+
+// NewChecker creates a checker probing the dependencies every interval, giving up on a probe after timeout
+func NewChecker(interval, timeout time.Duration) *Checker {
+	return &Checker{interval: interval, timeout: timeout}
+}
+
+// AddDependency adds a dependency to the readiness. The application is not ready while a critical dependency is down,
+// a non-critical one is only reported, so a provider serving part of the requests doesn't drain every instance at once.
+// It must be called before the checker starts serving checks.
+func (c *Checker) AddDependency(name string, critical bool, probe Probe) {
+	c.dependencies = append(c.dependencies, &dependency{probe: probe, status: DependencyStatus{Name: name, Critical: critical}})
+}
+
+// Drain makes the application not ready, so load balancers stop routing requests to it before it stops
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check returns the readiness of the application, probing the dependencies whose last probe is older than the interval.
+// The dependencies are not probed once the application is draining.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Draining: true, Dependencies: c.lastStatuses()}
+	}
+
+	report := Report{Ready: true, Dependencies: make([]DependencyStatus, len(c.dependencies))}
+	var wg sync.WaitGroup
+	for i, dep := range c.dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Dependencies[i] = dep.check(ctx, c.interval, c.timeout)
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range report.Dependencies {
+		switch {
+		case status.Up:
+		case status.Critical:
+			report.Ready = false
+		default:
+			report.Degraded = true
+		}
+	}
+	return report
+}
+
+func (c *Checker) lastStatuses() []DependencyStatus {
+	statuses := make([]DependencyStatus, len(c.dependencies))
+	for i, dep := range c.dependencies {
+		dep.mu.Lock()
+		statuses[i] = dep.status
+		dep.mu.Unlock()
+	}
+	return statuses
+}
+
+// check returns the last status of the dependency, probing it first when the status is older than interval
+func (d *dependency) check(ctx context.Context, interval, timeout time.Duration) DependencyStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.status.CheckedAt.IsZero() && time.Since(d.status.CheckedAt) < interval {
+		return d.status
+	}
+
+	// The probe is not bound to the check, a client giving up must not fail the probe of the next checks
+	probeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := d.probe(probeCtx)
+	d.status.Latency = time.Since(start)
+	d.status.CheckedAt = time.Now()
+	d.status.Up = err == nil || errors.Is(err, entity.ErrUpstreamNotFound)
+	if !d.status.Up {
+		d.status.LastError = describe(err)
+		d.status.LastErrorAt = d.status.CheckedAt
+	}
+	return d.status
+}
+
+// describe describes a failed probe without its message, which may hold the URL of the provider and its credentials
+func describe(err error) string {
+	var ue *entity.UpstreamError
+	switch {
+	case errors.As(err, &ue) && ue.StatusCode != 0:
+		return fmt.Sprintf("%v (status %d)", ue.Kind, ue.StatusCode)
+	case errors.As(err, &ue):
+		return ue.Kind.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "probe timeout"
+	default:
+		return "probe failed"
+	}
+}
@@ -0,0 +1,157 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	type testRow struct {
+		name      string
+		err       error
+		ready     bool
+		lastError string
+	}
+
+	testTable := []testRow{
+		{name: "Provider up", ready: true},
+		{name: "Resource not found counts as up", err: &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamNotFound, StatusCode: 404, Err: errors.New("not found")}, ready: true},
+		{name: "Provider unavailable", err: &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamUnavailable, StatusCode: 503, Err: errors.New("GET https://example.com/?key=secret")}, lastError: "upstream unavailable (status 503)"},
+		{name: "Provider unreachable", err: &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamUnavailable, Err: errors.New("connection refused")}, lastError: "upstream unavailable"},
+		{name: "Other failure", err: errors.New("https://example.com/?key=secret"), lastError: "probe failed"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			checker := NewChecker(time.Minute, time.Second)
+			checker.AddDependency("viacep", true, func(context.Context) error { return tr.err })
+
+			report := checker.Check(context.Background())
+
+			assert.Equal(t, tr.ready, report.Ready)
+			assert.False(t, report.Draining)
+			require.Len(t, report.Dependencies, 1)
+			assert.Equal(t, "viacep", report.Dependencies[0].Name)
+			assert.Equal(t, tr.ready, report.Dependencies[0].Up)
+			assert.Equal(t, tr.lastError, report.Dependencies[0].LastError)
+			assert.False(t, report.Dependencies[0].CheckedAt.IsZero())
+		})
+	}
+}
+
+func TestCheckIsNotReadyWhileACriticalDependencyIsDown(t *testing.T) {
+	checker := NewChecker(time.Minute, time.Second)
+	checker.AddDependency("viacep", true, func(context.Context) error { return nil })
+	checker.AddDependency("weatherapi", true, func(context.Context) error { return entity.ErrUpstreamTimeout })
+
+	report := checker.Check(context.Background())
+
+	assert.False(t, report.Ready)
+	require.Len(t, report.Dependencies, 2)
+	assert.True(t, report.Dependencies[0].Up)
+	assert.False(t, report.Dependencies[1].Up)
+}
+
+func TestCheckIsReadyWhileANonCriticalDependencyIsDown(t *testing.T) {
+	checker := NewChecker(time.Minute, time.Second)
+	checker.AddDependency("viacep", true, func(context.Context) error { return nil })
+	checker.AddDependency("zippopotam", false, func(context.Context) error { return entity.ErrUpstreamTimeout })
+
+	report := checker.Check(context.Background())
+
+	assert.True(t, report.Ready)
+	assert.True(t, report.Degraded)
+	require.Len(t, report.Dependencies, 2)
+	assert.True(t, report.Dependencies[0].Critical)
+	assert.False(t, report.Dependencies[1].Critical)
+	assert.False(t, report.Dependencies[1].Up)
+}
+
+func TestCheckReusesTheProbeForTheInterval(t *testing.T) {
+	var calls atomic.Int32
+	checker := NewChecker(50*time.Millisecond, time.Second)
+	checker.AddDependency("viacep", true, func(context.Context) error {
+		calls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	// Concurrent checks share the probe in progress
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.Check(context.Background())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	checker.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCheckKeepsTheLastError(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	checker := NewChecker(time.Nanosecond, time.Second)
+	checker.AddDependency("viacep", true, func(context.Context) error {
+		if failing.Load() {
+			return entity.ErrUpstreamTimeout
+		}
+		return nil
+	})
+
+	failed := checker.Check(context.Background()).Dependencies[0]
+	assert.False(t, failed.Up)
+
+	failing.Store(false)
+	recovered := checker.Check(context.Background()).Dependencies[0]
+	assert.True(t, recovered.Up)
+	assert.Equal(t, failed.LastError, recovered.LastError)
+	assert.Equal(t, failed.LastErrorAt, recovered.LastErrorAt)
+}
+
+func TestCheckTimesOutTheProbe(t *testing.T) {
+	checker := NewChecker(time.Minute, 10*time.Millisecond)
+	checker.AddDependency("viacep", true, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	// The probe outlives a client giving up on the check
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := checker.Check(ctx)
+
+	assert.False(t, report.Ready)
+	assert.Equal(t, "probe timeout", report.Dependencies[0].LastError)
+}
+
+func TestDrain(t *testing.T) {
+	var calls atomic.Int32
+	checker := NewChecker(time.Nanosecond, time.Second)
+	checker.AddDependency("viacep", true, func(context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	assert.True(t, checker.Check(context.Background()).Ready)
+
+	checker.Drain()
+	report := checker.Check(context.Background())
+
+	assert.False(t, report.Ready)
+	assert.True(t, report.Draining)
+	require.Len(t, report.Dependencies, 1)
+	assert.True(t, report.Dependencies[0].Up)
+	assert.Equal(t, int32(1), calls.Load())
+}
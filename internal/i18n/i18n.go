@@ -0,0 +1,181 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Language is a BCP 47 language tag supported by the application
+type Language string
+
+const (
+	English             Language = "en"
+	BrazilianPortuguese Language = "pt-BR"
+)
+
+// DefaultLanguage is used when the client does not ask for a supported language
+const DefaultLanguage = English
+
+// Message keys of the catalog
+const (
+	MsgInvalidZipcode      = "invalid_zipcode"
+	MsgZipcodeNotFound     = "zipcode_not_found"
+	MsgUnsupportedCountry  = "unsupported_country"
+	MsgInvalidUnits        = "invalid_units"
+	MsgWeatherNotFound     = "weather_not_found"
+	MsgInvalidMessage      = "invalid_message"
+	MsgSubscriptionLimit   = "subscription_limit"
+	MsgInvalidWebhook      = "invalid_webhook"
+	MsgWebhookNotFound     = "webhook_not_found"
+	MsgWebhookDelivery     = "webhook_delivery_failed"
+	MsgUnauthorized        = "unauthorized"
+	MsgNotFound            = "not_found"
+	MsgMethodNotAllowed    = "method_not_allowed"
+	MsgInvalidBatch        = "invalid_batch"
+	MsgNotAcceptable       = "not_acceptable"
+	MsgUpstreamTimeout     = "upstream_timeout"
+	MsgUpstreamUnavailable = "upstream_unavailable"
+	MsgUpstreamRateLimited = "upstream_rate_limited"
+	MsgUpstreamBadPayload  = "upstream_bad_payload"
+	MsgUnexpectedError     = "unexpected_error"
+)
+
+var catalog = map[Language]map[string]string{
+	English: {
+		MsgInvalidZipcode:      "invalid zipcode",
+		MsgZipcodeNotFound:     "can not find zipcode",
+		MsgUnsupportedCountry:  "unsupported country",
+		MsgInvalidUnits:        "invalid units",
+		MsgWeatherNotFound:     "can not find weather information for this location",
+		MsgInvalidMessage:      "invalid message",
+		MsgSubscriptionLimit:   "too many subscriptions on this connection",
+		MsgInvalidWebhook:      "invalid webhook, check the CEP, the rule and the callback URL",
+		MsgWebhookNotFound:     "can not find webhook",
+		MsgWebhookDelivery:     "the webhook callback did not accept the delivery",
+		MsgUnauthorized:        "the admin token is missing or invalid",
+		MsgNotFound:            "the requested resource does not exist",
+		MsgMethodNotAllowed:    "the method is not allowed for this resource",
+		MsgInvalidBatch:        "a batch must have between 1 and 100 CEPs",
+		MsgNotAcceptable:       "unsupported response format, use json, xml, csv, msgpack or protobuf",
+		MsgUpstreamTimeout:     "an upstream service took too long to respond",
+		MsgUpstreamUnavailable: "an upstream service is unavailable, try again later",
+		MsgUpstreamRateLimited: "an upstream service is rate limiting requests, try again later",
+		MsgUpstreamBadPayload:  "an upstream service sent an invalid response",
+		MsgUnexpectedError:     "An unexpected error occurred",
+	},
+	BrazilianPortuguese: {
+		MsgInvalidZipcode:      "CEP inválido",
+		MsgZipcodeNotFound:     "não foi possível encontrar o CEP",
+		MsgUnsupportedCountry:  "país não suportado",
+		MsgInvalidUnits:        "sistema de unidades inválido",
+		MsgWeatherNotFound:     "não foi possível encontrar o clima desta localidade",
+		MsgInvalidMessage:      "mensagem inválida",
+		MsgSubscriptionLimit:   "assinaturas demais nesta conexão",
+		MsgInvalidWebhook:      "webhook inválido, verifique o CEP, a regra e a URL de callback",
+		MsgWebhookNotFound:     "não foi possível encontrar o webhook",
+		MsgWebhookDelivery:     "o callback do webhook não aceitou a entrega",
+		MsgUnauthorized:        "o token de administração está ausente ou é inválido",
+		MsgNotFound:            "o recurso solicitado não existe",
+		MsgMethodNotAllowed:    "o método não é permitido para este recurso",
+		MsgInvalidBatch:        "um lote deve ter entre 1 e 100 CEPs",
+		MsgNotAcceptable:       "formato de resposta não suportado, use json, xml, csv, msgpack ou protobuf",
+		MsgUpstreamTimeout:     "um serviço externo demorou demais para responder",
+		MsgUpstreamUnavailable: "um serviço externo está indisponível, tente novamente mais tarde",
+		MsgUpstreamRateLimited: "um serviço externo está limitando as requisições, tente novamente mais tarde",
+		MsgUpstreamBadPayload:  "um serviço externo enviou uma resposta inválida",
+		MsgUnexpectedError:     "Ocorreu um erro inesperado",
+	},
+}
+
+type contextKey struct{}
+
+// Message returns the text of a message key in the given language, falling back to the default language
+func Message(lang Language, key string) string {
+	if msg, ok := catalog[lang][key]; ok {
+		return msg
+	}
+	return catalog[DefaultLanguage][key]
+}
+
+// Parse matches a language tag to a supported language. Any Portuguese variant is served in pt-BR.
+func Parse(tag string) (Language, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	primary, _, _ := strings.Cut(tag, "-")
+	switch primary {
+	case "en":
+		return English, true
+	case "pt":
+		return BrazilianPortuguese, true
+	default:
+		return "", false
+	}
+}
+
+// Negotiate picks the language of a request. The lang query parameter has precedence over Accept-Language.
+func Negotiate(r *http.Request) Language {
+	if lang, ok := Parse(r.URL.Query().Get("lang")); ok {
+		return lang
+	}
+	return FromAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// FromAcceptLanguage picks the supported language with the highest weight in an Accept-Language header
+func FromAcceptLanguage(header string) Language {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || q <= 0 {
+			continue
+		}
+		tags = append(tags, weighted{tag, q})
+	}
+
+	// Stable sort keeps the header order between tags of the same weight
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if lang, ok := Parse(t.tag); ok {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// WithLanguage returns a copy of ctx carrying the language
+func WithLanguage(ctx context.Context, lang Language) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext returns the language carried by ctx or the default language
+func FromContext(ctx context.Context) Language {
+	if lang, ok := ctx.Value(contextKey{}).(Language); ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Middleware negotiates the language of each request and stores it in the request context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := Negotiate(r)
+		w.Header().Set("Content-Language", string(lang))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(WithLanguage(r.Context(), lang)))
+	})
+}
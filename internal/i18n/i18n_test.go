@@ -0,0 +1,70 @@
+package i18n
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type acceptLanguageTestRow struct {
+	name     string
+	header   string
+	expected Language
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	testTable := []acceptLanguageTestRow{
+		{"Empty header", "", DefaultLanguage},
+		{"Brazilian Portuguese", "pt-BR", BrazilianPortuguese},
+		{"Portuguese variant", "pt-PT,pt;q=0.9", BrazilianPortuguese},
+		{"English", "en-US,en;q=0.9", English},
+		{"Weights are honoured", "en;q=0.5,pt-BR;q=0.8", BrazilianPortuguese},
+		{"Unsupported languages are skipped", "fr-FR,de;q=0.9,pt;q=0.1", BrazilianPortuguese},
+		{"Only unsupported languages", "fr-FR,de", DefaultLanguage},
+		{"Excluded language", "pt;q=0,en;q=0.1", English},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, FromAcceptLanguage(tr.header))
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Run("Query parameter has precedence", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/weather/12345678?lang=pt-BR", nil)
+		r.Header.Set("Accept-Language", "en")
+		assert.Equal(t, BrazilianPortuguese, Negotiate(r))
+	})
+
+	t.Run("Unsupported query parameter falls back to header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/weather/12345678?lang=fr", nil)
+		r.Header.Set("Accept-Language", "pt")
+		assert.Equal(t, BrazilianPortuguese, Negotiate(r))
+	})
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "invalid zipcode", Message(English, MsgInvalidZipcode))
+	assert.Equal(t, "CEP inválido", Message(BrazilianPortuguese, MsgInvalidZipcode))
+	assert.Equal(t, "invalid zipcode", Message("fr", MsgInvalidZipcode))
+}
+
+func TestMiddleware(t *testing.T) {
+	var got Language
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "pt-BR")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+
+	assert.Equal(t, BrazilianPortuguese, got)
+	assert.Equal(t, "pt-BR", rec.Header().Get("Content-Language"))
+	assert.Equal(t, DefaultLanguage, FromContext(context.Background()))
+}
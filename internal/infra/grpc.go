@@ -0,0 +1,58 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/config"
+	"github.com/caricciy/go-weather/internal/grpcapi"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// GrpcServer is the gRPC server of the application and its health service
+type GrpcServer struct {
+	*grpc.Server
+	Health *grpchealth.Server
+	Addr   string
+}
+
+// NewGrpcServer initializes the gRPC server with the weather and health services.
+// The reflection service, which lets clients list the services, is only registered when the configuration enables it.
+func NewGrpcServer(cfg *config.Config, uc *usecase.WeatherUseCases) *GrpcServer {
+	server := grpc.NewServer()
+	weatherv1.RegisterWeatherServiceServer(server, grpcapi.NewWeatherServer(uc))
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	healthServer.SetServingStatus(weatherv1.WeatherService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+
+	if cfg.Server.GrpcReflection {
+		reflection.Register(server)
+	}
+
+	return &GrpcServer{Server: server, Health: healthServer, Addr: fmt.Sprintf(":%s", cfg.Server.GrpcPort)}
+}
+
+// Shutdown reports NOT_SERVING to health checks and stops the server once in-flight RPCs complete.
+// Running RPCs are cancelled when ctx is done first.
+func (s *GrpcServer) Shutdown(ctx context.Context) error {
+	s.Health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,31 @@
+package infra
+
+import (
+	"github.com/caricciy/go-weather/internal/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGrpcReflectionIsOptIn(t *testing.T) {
+	type testRow struct {
+		name       string
+		reflection bool
+	}
+
+	testTable := []testRow{
+		{name: "Default"},
+		{name: "Enabled", reflection: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Server.GrpcReflection = tr.reflection
+
+			services := NewGrpcServer(cfg, nil).GetServiceInfo()
+			assert.Contains(t, services, "weather.v1.WeatherService")
+			_, registered := services["grpc.reflection.v1.ServerReflection"]
+			assert.Equal(t, tr.reflection, registered)
+		})
+	}
+}
@@ -1,16 +1,132 @@
 package infra
 
 import (
+	"context"
+	"github.com/caricciy/go-weather/internal/config"
 	"github.com/caricciy/go-weather/internal/data"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/graphqlapi"
 	"github.com/caricciy/go-weather/internal/handler"
+	"github.com/caricciy/go-weather/internal/health"
+	"github.com/caricciy/go-weather/internal/live"
 	"github.com/caricciy/go-weather/internal/usecase"
-	"os"
 )
 
-func NewWeatherHandler() *handler.WeatherHandler {
-	weatherApiKey := os.Getenv("WEATHER_API_KEY")
-	vcs := data.NewViaCEPStore()
-	ws := data.NewWeatherApiStore(weatherApiKey)
+// NewWeatherApiKeyPool creates the pool of the WeatherAPI keys, shared by the lookups and the readiness probe.
+// The keys are replaced by the reloads of the configuration.
+func NewWeatherApiKeyPool(cfg *config.Config, reloader *config.Reloader) *data.APIKeyPool {
+	p := cfg.Providers
+	keys := data.NewWeatherApiKeyPool(p.WeatherApiKeyPool(), p.WeatherApiKeyStrategy, p.WeatherApiKeyCooldown)
+	reloader.OnReload(func(cfg *config.Config) {
+		keys.SetKeys(cfg.Providers.WeatherApiKeyPool())
+	})
+	return keys
+}
+
+// NewWeatherUseCases creates the weather use cases shared by every API, calling WeatherAPI with the keys of the pool
+func NewWeatherUseCases(cfg *config.Config, keys *data.APIKeyPool) *usecase.WeatherUseCases {
+	p := cfg.Providers
+	vcs := data.NewViaCEPStore(p.ViaCEPEndpoint)
+	ws := data.NewWeatherApiStore(p.WeatherApiEndpoint, keys)
 	uc := usecase.NewWeatherUseCases(vcs, ws)
-	return handler.NewWeatherHandler(uc)
+	uc.SetForecastRepository(ws)
+	uc.RegisterPostalCodeRepository("PT", data.NewZippopotamStore(p.ZippopotamEndpoint, "PT"))
+	uc.RegisterPostalCodeRepository("AR", data.NewZippopotamStore(p.ZippopotamEndpoint, "AR"))
+	return uc
+}
+
+// NewReadinessChecker creates the readiness checker of the providers.
+// Each provider is probed at most every probe interval, a probe taking longer than the probe timeout fails.
+// Only ViaCEP is critical: the lookups of the Brazilian CEPs need it, while Zippopotam only serves PT and AR, and the CEP
+// lookups still work without WeatherAPI, whose outages and quotas would otherwise drain every instance at once.
+// The probe of WeatherAPI shares the keys of the lookups, so it knows the keys they quarantined.
+func NewReadinessChecker(cfg *config.Config, keys *data.APIKeyPool) *health.Checker {
+	checker := health.NewChecker(cfg.Health.ProbeInterval, cfg.Health.ProbeTimeout)
+
+	p := cfg.Providers
+	vcs := data.NewViaCEPStore(p.ViaCEPEndpoint)
+	checker.AddDependency("viacep", true, func(ctx context.Context) error {
+		_, err := vcs.GetCEP(ctx, "01001000")
+		return err
+	})
+	zs := data.NewZippopotamStore(p.ZippopotamEndpoint, "PT")
+	checker.AddDependency("zippopotam", false, func(ctx context.Context) error {
+		_, err := zs.GetCEP(ctx, "1000-001")
+		return err
+	})
+	ws := data.NewWeatherApiStore(p.WeatherApiEndpoint, keys)
+	checker.AddDependency("weatherapi", false, func(ctx context.Context) error {
+		_, err := ws.GetWeatherInfo(ctx, &entity.CEP{Localidade: "São Paulo"})
+		return err
+	})
+	return checker
+}
+
+// NewWeatherHandler creates the weather handler. CEP lookups are cached for the CEP max age,
+// and weather until WeatherAPI refreshes its conditions, every weather interval after the observation.
+// Requests bearing the admin token may ask for the timing breakdown of a lookup with ?debug=1.
+// The lifetimes and the admin token are swapped by the reloads of the configuration.
+func NewWeatherHandler(cfg *config.Config, uc *usecase.WeatherUseCases, reloader *config.Reloader) *handler.WeatherHandler {
+	weatherHandler := handler.NewWeatherHandler(uc, cfg.Units.Precision, cacheConfig(cfg))
+	weatherHandler.EnableDebug(cfg.AdminToken)
+	reloader.OnReload(func(cfg *config.Config) {
+		weatherHandler.SetCacheConfig(cacheConfig(cfg))
+		weatherHandler.EnableDebug(cfg.AdminToken)
+	})
+	return weatherHandler
+}
+
+func cacheConfig(cfg *config.Config) handler.CacheConfig {
+	return handler.CacheConfig{
+		CEPMaxAge:       cfg.Cache.CEPMaxAge,
+		WeatherInterval: cfg.Cache.WeatherInterval,
+	}
+}
+
+// NewWebhookUseCases creates the webhook use cases, storing the webhooks in the webhook store file.
+// Deliveries are attempted up to the max attempts, waiting the backoff before the first retry.
+func NewWebhookUseCases(cfg *config.Config, uc *usecase.WeatherUseCases) (*usecase.WebhookUseCases, error) {
+	store, err := data.NewWebhookFileStore(cfg.Webhook.StorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	sender := data.NewWebhookHttpSender(cfg.Webhook.Timeout)
+	return usecase.NewWebhookUseCases(uc, store, sender, cfg.Webhook.MaxAttempts, cfg.Webhook.Backoff), nil
+}
+
+// NewWebhookHandler creates the webhook handler, the webhooks are managed with the admin token, swapped by the reloads of the configuration
+func NewWebhookHandler(cfg *config.Config, uc *usecase.WebhookUseCases, reloader *config.Reloader) *handler.WebhookHandler {
+	webhookHandler := handler.NewWebhookHandler(uc)
+	webhookHandler.SetAdminToken(cfg.AdminToken)
+	reloader.OnReload(func(cfg *config.Config) {
+		webhookHandler.SetAdminToken(cfg.AdminToken)
+	})
+	return webhookHandler
+}
+
+// NewLiveHub creates the hub of the live weather updates, locations are refreshed every stream refresh interval
+func NewLiveHub(cfg *config.Config, uc *usecase.WeatherUseCases) *live.Hub {
+	return live.NewHub(uc, cfg.Stream.RefreshInterval)
+}
+
+// NewStreamHandler creates the SSE handler, idle streams get a heartbeat every stream heartbeat interval
+func NewStreamHandler(cfg *config.Config, hub *live.Hub) *handler.StreamHandler {
+	return handler.NewStreamHandler(hub, cfg.Units.Precision, cfg.Stream.HeartbeatInterval)
+}
+
+// NewSocketHandler creates the WebSocket handler.
+// Sockets are pinged every ping interval, follow up to the max subscriptions CEPs
+// and may be opened from the hosts of the allowed origins. The max subscriptions are swapped by the reloads of the configuration.
+func NewSocketHandler(cfg *config.Config, hub *live.Hub, reloader *config.Reloader) *handler.SocketHandler {
+	socketHandler := handler.NewSocketHandler(hub, cfg.Units.Precision, cfg.Socket.PingInterval, cfg.Socket.MaxSubscriptions, cfg.Socket.AllowedOrigins)
+	reloader.OnReload(func(cfg *config.Config) {
+		socketHandler.SetMaxSubscriptions(cfg.Socket.MaxSubscriptions)
+	})
+	return socketHandler
+}
+
+// NewGraphQLHandler creates the GraphQL handler, introspection is enabled by the configuration
+func NewGraphQLHandler(cfg *config.Config, uc *usecase.WeatherUseCases) *graphqlapi.Handler {
+	return graphqlapi.NewHandler(uc, cfg.GraphQL.Introspection)
 }
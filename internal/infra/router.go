@@ -1,6 +1,14 @@
 package infra
 
 import (
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/caricciy/go-weather/internal/handler"
+	"github.com/caricciy/go-weather/internal/health"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"github.com/caricciy/go-weather/internal/timing"
+	"github.com/caricciy/go-weather/internal/tracing"
 	"github.com/caricciy/go-weather/internal/util"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -12,19 +20,137 @@ func NewAppRouter() *chi.Mux {
 	router := chi.NewRouter()
 
 	// Add middleware
-	router.Use(middleware.Logger, middleware.Recoverer, middleware.RequestID)
+	router.Use(tracing.Middleware, metrics.Middleware, middleware.RequestID, logging.Middleware, i18n.Middleware, handler.Recoverer)
 
-	// Set up health check route
-	router.Get("/health", health)
+	// Unknown routes and methods are answered with problem details, like the errors of the handlers
+	router.NotFound(handler.HandleNotFound)
+	router.MethodNotAllowed(handler.NewMethodNotAllowedHandler(router))
+
+	// Set up the liveness routes, /health is kept for the clients of the former health check
+	router.Get("/health", liveness)
+	router.Get("/health/live", liveness)
+
+	// Set up API documentation routes
+	router.Get("/openapi.json", docs.HandleSpec)
+	router.Get("/docs", docs.HandleUI)
 
 	return router
 }
 
-func health(w http.ResponseWriter, r *http.Request) {
-	status := struct {
-		Status string    `json:"status"`
-		Time   time.Time `json:"time"`
-	}{Status: "UP", Time: time.Now()}
+// RegisterReadinessRoutes registers the readiness check, which fails while a provider is down or the application is draining
+func RegisterReadinessRoutes(router chi.Router, checker *health.Checker) {
+	router.Get("/health/ready", func(w http.ResponseWriter, r *http.Request) {
+		readiness(w, r, checker)
+	})
+}
+
+// RegisterWeatherRoutes registers the weather lookup routes, their responses carry the Server-Timing of the lookup
+func RegisterWeatherRoutes(router chi.Router, weatherHandler *handler.WeatherHandler) {
+	router.Group(func(router chi.Router) {
+		router.Use(timing.Middleware)
+		router.Get("/weather", weatherHandler.HandleGetWeatherBatch)
+		router.Get("/weather/{cep}", weatherHandler.HandleGetWeatherByCEP)
+		router.Get("/weather/{country:[A-Za-z]{2}}/{postalcode}", weatherHandler.HandleGetWeatherByPostalCode)
+		router.Get("/cep/{cep}", weatherHandler.HandleGetCEP)
+		router.Get("/cep/{country:[A-Za-z]{2}}/{postalcode}", weatherHandler.HandleGetPostalCode)
+	})
+}
+
+// RegisterStreamRoutes registers the Server-Sent Events streams of live weather updates
+func RegisterStreamRoutes(router chi.Router, streamHandler *handler.StreamHandler) {
+	router.Get("/weather/{cep}/stream", streamHandler.HandleStreamWeatherByCEP)
+}
+
+// RegisterSocketRoutes registers the WebSocket where clients follow the live weather of several CEPs
+func RegisterSocketRoutes(router chi.Router, socketHandler *handler.SocketHandler) {
+	router.Get("/weather/ws", socketHandler.HandleWeatherSocket)
+}
+
+// RegisterWebhookRoutes registers the management routes of the threshold webhooks, reserved to the admin
+func RegisterWebhookRoutes(router chi.Router, webhookHandler *handler.WebhookHandler) {
+	router.Group(func(router chi.Router) {
+		router.Use(webhookHandler.RequireAdmin)
+		router.Post("/webhooks", webhookHandler.HandleCreateWebhook)
+		router.Get("/webhooks", webhookHandler.HandleListWebhooks)
+		router.Delete("/webhooks/{id}", webhookHandler.HandleDeleteWebhook)
+		router.Post("/webhooks/{id}/test", webhookHandler.HandleTestWebhook)
+	})
+}
+
+// RegisterGraphQLRoutes registers the GraphQL endpoint, which accepts queries by GET and POST
+func RegisterGraphQLRoutes(router chi.Router, graphQLHandler http.Handler) {
+	router.Get("/graphql", graphQLHandler.ServeHTTP)
+	router.Post("/graphql", graphQLHandler.ServeHTTP)
+}
+
+type healthResponse struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// liveness reports that the process is up, whatever the state of the providers
+func liveness(w http.ResponseWriter, r *http.Request) {
+	status := healthResponse{Status: health.StatusUp, Time: time.Now()}
 
 	util.SendJSON(w, status, http.StatusOK)
 }
+
+type readinessResponse struct {
+	Status       string               `json:"status"`
+	Time         time.Time            `json:"time"`
+	Dependencies []dependencyResponse `json:"dependencies"`
+}
+
+type dependencyResponse struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Critical    bool       `json:"critical"`
+	LatencyMs   float64    `json:"latency_ms"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// readiness reports the status of each provider, answering 503 when the application should not receive requests.
+// A non-critical provider down is reported as DEGRADED, with 200.
+func readiness(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	report := checker.Check(r.Context())
+
+	response := readinessResponse{Status: health.StatusUp, Time: time.Now(), Dependencies: []dependencyResponse{}}
+	statusCode := http.StatusOK
+	switch {
+	case report.Draining:
+		response.Status = health.StatusDraining
+		statusCode = http.StatusServiceUnavailable
+	case !report.Ready:
+		response.Status = health.StatusDown
+		statusCode = http.StatusServiceUnavailable
+	case report.Degraded:
+		response.Status = health.StatusDegraded
+	}
+
+	for _, dep := range report.Dependencies {
+		status := health.StatusDown
+		if dep.Up {
+			status = health.StatusUp
+		}
+		response.Dependencies = append(response.Dependencies, dependencyResponse{
+			Name:        dep.Name,
+			Status:      status,
+			Critical:    dep.Critical,
+			LatencyMs:   logging.Milliseconds(dep.Latency),
+			CheckedAt:   optionalTime(dep.CheckedAt),
+			LastError:   dep.LastError,
+			LastErrorAt: optionalTime(dep.LastErrorAt),
+		})
+	}
+
+	util.SendJSON(w, response, statusCode)
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,192 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/handler"
+	"github.com/caricciy/go-weather/internal/health"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// routeParamPattern matches the regexp part of chi route params, e.g. ":[A-Za-z]{2}" in {country:[A-Za-z]{2}}
+var routeParamPattern = regexp.MustCompile(`\{(\w+):[^/]*\}`)
+
+func TestRoutesAreDocumented(t *testing.T) {
+	router := NewAppRouter()
+	RegisterWeatherRoutes(router, handler.NewWeatherHandler(nil, 2, handler.DefaultCacheConfig()))
+	RegisterStreamRoutes(router, handler.NewStreamHandler(nil, 2, 0))
+	RegisterSocketRoutes(router, handler.NewSocketHandler(nil, 2, 0, 0, nil))
+	RegisterWebhookRoutes(router, handler.NewWebhookHandler(nil))
+	RegisterGraphQLRoutes(router, http.NotFoundHandler())
+	RegisterReadinessRoutes(router, health.NewChecker(health.DefaultProbeInterval, health.DefaultProbeTimeout))
+
+	var routes []string
+	err := chi.Walk(router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+routeParamPattern.ReplaceAllString(route, "{$1}"))
+		return nil
+	})
+	assert.NoError(t, err)
+	sort.Strings(routes)
+
+	operations, err := docs.Operations()
+	assert.NoError(t, err)
+	assert.Equal(t, operations, routes)
+}
+
+func TestWebhookRoutesRequireAdmin(t *testing.T) {
+	type testRow struct {
+		name          string
+		adminToken    string
+		authorization string
+	}
+
+	testTable := []testRow{
+		{name: "Without token", adminToken: "admin"},
+		{name: "Wrong token", adminToken: "admin", authorization: "Bearer other"},
+		{name: "Admin token not set", authorization: "Bearer "},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			webhookHandler := handler.NewWebhookHandler(nil)
+			webhookHandler.SetAdminToken(tr.adminToken)
+			router := chi.NewRouter()
+			RegisterWebhookRoutes(router, webhookHandler)
+
+			for _, route := range []string{"POST /webhooks", "GET /webhooks", "DELETE /webhooks/1", "POST /webhooks/1/test"} {
+				method, path, _ := strings.Cut(route, " ")
+				req := httptest.NewRequest(method, path, nil)
+				req.Header.Set("Authorization", tr.authorization)
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, req)
+
+				assert.Equal(t, http.StatusUnauthorized, rr.Code, route)
+				assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"), route)
+				assert.Contains(t, rr.Body.String(), `"code":"UNAUTHORIZED"`, route)
+			}
+		})
+	}
+
+	t.Run("Admin", func(t *testing.T) {
+		webhookHandler := handler.NewWebhookHandler(nil)
+		webhookHandler.SetAdminToken("admin")
+		router := chi.NewRouter()
+		RegisterWebhookRoutes(router, webhookHandler)
+
+		// The invalid creation reaches the handler, which rejects its body
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader("{"))
+		req.Header.Set("Authorization", "Bearer admin")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestRoutingErrorsAreProblems(t *testing.T) {
+	router := NewAppRouter()
+	router.Get("/panic", func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	type testRow struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		code     string
+		allow    []string
+		language string
+	}
+
+	testTable := []testRow{
+		{name: "Unknown route", method: http.MethodGet, target: "/nope", status: http.StatusNotFound, code: "NOT_FOUND", language: "en"},
+		{name: "Method not allowed", method: http.MethodPost, target: "/health", status: http.StatusMethodNotAllowed, code: "METHOD_NOT_ALLOWED", allow: []string{http.MethodGet}, language: "en"},
+		{name: "Panic", method: http.MethodGet, target: "/panic?lang=pt", status: http.StatusInternalServerError, code: "INTERNAL_ERROR", language: "pt-BR"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tr.method, tr.target, nil))
+
+			assert.Equal(t, tr.status, rr.Code)
+			assert.Equal(t, "application/problem+json", rr.Header().Get("Content-Type"))
+			assert.Equal(t, tr.language, rr.Header().Get("Content-Language"))
+			assert.Equal(t, tr.allow, rr.Header().Values("Allow"))
+			assert.Contains(t, rr.Body.String(), `"code":"`+tr.code+`"`)
+		})
+	}
+}
+
+func TestHealthResponseMatchesSpec(t *testing.T) {
+	type testRow struct {
+		schema string
+		value  any
+	}
+
+	testTable := []testRow{
+		{schema: "Health", value: healthResponse{}},
+		{schema: "Readiness", value: readinessResponse{}},
+		{schema: "DependencyStatus", value: dependencyResponse{}},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.schema, func(t *testing.T) {
+			drift, err := docs.SchemaDrift(tr.schema, tr.value)
+			assert.NoError(t, err)
+			assert.Empty(t, drift)
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	type testRow struct {
+		name     string
+		err      error
+		critical bool
+		drain    bool
+		expected int
+		status   string
+	}
+
+	testTable := []testRow{
+		{name: "Ready", critical: true, expected: http.StatusOK, status: "UP"},
+		{name: "Critical provider down", err: entity.ErrUpstreamTimeout, critical: true, expected: http.StatusServiceUnavailable, status: "DOWN"},
+		{name: "Non-critical provider down", err: entity.ErrUpstreamTimeout, expected: http.StatusOK, status: "DEGRADED"},
+		{name: "Draining", critical: true, drain: true, expected: http.StatusServiceUnavailable, status: "DRAINING"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			checker := health.NewChecker(health.DefaultProbeInterval, health.DefaultProbeTimeout)
+			checker.AddDependency("viacep", tr.critical, func(context.Context) error { return tr.err })
+			if tr.drain {
+				checker.Drain()
+			}
+			router := chi.NewRouter()
+			RegisterReadinessRoutes(router, checker)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			assert.Equal(t, tr.expected, rr.Code)
+			var response readinessResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, tr.status, response.Status)
+			require.Len(t, response.Dependencies, 1)
+			assert.Equal(t, "viacep", response.Dependencies[0].Name)
+			assert.Equal(t, tr.critical, response.Dependencies[0].Critical)
+		})
+	}
+}
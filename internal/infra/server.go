@@ -3,30 +3,112 @@ package infra
 import (
 	"context"
 	"fmt"
+	"github.com/caricciy/go-weather/internal/config"
+	"github.com/caricciy/go-weather/internal/health"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/usecase"
 	"github.com/go-chi/chi/v5"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
 
 // NewHttpServer initializes a new HTTP server with configurations
-func NewHttpServer(handler *chi.Mux) *http.Server {
+func NewHttpServer(cfg *config.Config, handler *chi.Mux) *http.Server {
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%s", os.Getenv("PORT")),
+		Addr:         fmt.Sprintf(":%s", cfg.Server.Port),
 		Handler:      handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
 	return server
 }
 
-// WaitForShutdown listens for OS signals and gracefully shuts down the server
-func WaitForShutdown(server *http.Server) {
+// NewMetricsServer initializes the server of the Prometheus metrics, listening on the metrics address.
+// It is kept apart from the API server so the metrics can be reached by the scraper only.
+func NewMetricsServer(cfg *config.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+
+	return &http.Server{
+		Addr:         cfg.Server.MetricsAddr,
+		Handler:      mux,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+}
+
+// SetupTracing installs the tracer provider, exporting the spans to the configured exporter: none, stdout or otlp.
+// The OTLP exporter is configured by the standard OTEL_EXPORTER_OTLP_* variables.
+// The postal codes of the spans are hashed with the CEP hash key, random when it is not set.
+func SetupTracing(ctx context.Context, cfg *config.Config) (ShutdownFunc, error) {
+	tracing.SetCEPHashKey(cfg.Tracing.CEPHashKey)
+	if cfg.Tracing.CEPHashKey == "" && cfg.Tracing.Exporter != tracing.ExporterNone {
+		log.Println("TRACING_CEP_HASH_KEY is not set, the CEP hashes of the spans change on every restart and differ between instances")
+	}
+	return tracing.Setup(ctx, cfg.Tracing.Exporter)
+}
+
+// ShutdownFunc gracefully stops a component of the application, giving up when ctx is done
+type ShutdownFunc func(ctx context.Context) error
+
+// StartWebhookEvaluator evaluates the webhooks every webhook evaluation interval in the background.
+// The returned function stops it, waiting for the deliveries in progress.
+func StartWebhookEvaluator(cfg *config.Config, uc *usecase.WebhookUseCases) ShutdownFunc {
+	interval := cfg.Webhook.EvalInterval
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		uc.RunWebhookEvaluator(ctx, interval)
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// StartConfigReloader reloads the configuration on SIGHUP, and when its files change, checked every reload interval.
+// The returned function stops it.
+func StartConfigReloader(cfg *config.Config, reloader *config.Reloader) ShutdownFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		reloader.Watch(ctx, cfg.Reload.Interval)
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// WaitForShutdown listens for OS signals and gracefully shuts down the server and the other components.
+// The readiness flips to not ready as soon as the signal is received, the server keeps serving for the drain delay
+// so the load balancers notice it before it stops accepting requests. A second signal ends the drain early.
+func WaitForShutdown(cfg *config.Config, server *http.Server, readiness *health.Checker, components ...ShutdownFunc) {
 	// Create a channel to listen for OS signals
 	shudown := make(chan os.Signal, 1)
 	signal.Notify(shudown, os.Interrupt, os.Kill, syscall.SIGTERM)
@@ -34,12 +116,34 @@ func WaitForShutdown(server *http.Server) {
 	// Wait for the shutdown signal
 	<-shudown
 
+	readiness.Drain()
+	drainDelay := cfg.Health.DrainDelay
+	log.Printf("Received shudown signal, draining for %s...", drainDelay)
+	select {
+	case <-time.After(drainDelay):
+	case <-shudown:
+	}
+
 	// Initiate graceful shutdown
-	log.Println("Received shudown signal, shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	// The components are stopped alongside the server so they share the shutdown deadline
+	var wg sync.WaitGroup
+	for _, shutdown := range components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := shutdown(ctx); err != nil {
+				log.Printf("Component forced to shutdown: %v", err)
+			}
+		}()
+	}
+
+	err := server.Shutdown(ctx)
+	wg.Wait()
+	if err != nil {
 		panic("Server forced to shutdown" + err.Error())
 	}
 
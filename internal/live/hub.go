@@ -0,0 +1,327 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"go.opentelemetry.io/otel/trace"
+	"hash/fnv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is how often a location is refreshed when no interval is configured
+const DefaultInterval = 30 * time.Second
+
+// CachePoller is the cache of the pollers in the metrics, a subscription sharing the poller of its location is a hit
+const CachePoller = "live_poller"
+
+// pollTimeout bounds every upstream lookup of a poller
+const pollTimeout = 5 * time.Second
+
+// ErrHubClosed is returned by Subscribe once the hub has been shut down
+var ErrHubClosed = errors.New("live updates hub is closed")
+
+// Update is a snapshot of the weather of a location.
+// ID identifies the snapshot by its content, so it is stable across pollers and restarts.
+type Update struct {
+	ID     string
+	Report *entity.WeatherReport
+	Time   time.Time
+}
+
+// Hub shares one upstream poller between all the subscribers of the same location
+type Hub struct {
+	useCases *usecase.WeatherUseCases
+	interval time.Duration
+
+	mu      sync.Mutex
+	pollers map[key]*poller
+	closed  bool
+}
+
+type key struct {
+	country    string
+	postalCode string
+}
+
+func NewHub(useCases *usecase.WeatherUseCases, interval time.Duration) *Hub {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Hub{
+		useCases: useCases,
+		interval: interval,
+		pollers:  make(map[key]*poller),
+	}
+}
+
+// Interval returns how often the locations are refreshed
+func (h *Hub) Interval() time.Duration {
+	return h.interval
+}
+
+// Subscribe starts receiving the updates of a postal code, sharing the poller of the location if it is already watched.
+// It waits for the first snapshot, so an invalid or unknown postal code is reported as an error.
+func (h *Hub) Subscribe(ctx context.Context, country, postalCode string) (*Subscription, error) {
+	k := key{country: strings.ToUpper(country), postalCode: postalCode}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return nil, ErrHubClosed
+	}
+	p, ok := h.pollers[k]
+	metrics.CacheLookup(CachePoller, ok)
+	trace.SpanFromContext(ctx).SetAttributes(tracing.CacheOutcome(ok))
+	if !ok {
+		p = h.newPoller(k)
+		h.pollers[k] = p
+		go p.run()
+	}
+	sub := &Subscription{hub: h, poller: p, updates: make(chan Update, 1)}
+	p.add(sub.updates)
+	h.mu.Unlock()
+
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		sub.Close()
+		return nil, ctx.Err()
+	}
+
+	if err := p.err(); err != nil {
+		sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Shutdown stops every poller and ends all the subscriptions
+func (h *Hub) Shutdown(_ context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for k, p := range h.pollers {
+		p.close()
+		delete(h.pollers, k)
+	}
+	return nil
+}
+
+// unsubscribe removes a subscriber and stops the poller of the location when nobody is left
+func (h *Hub) unsubscribe(p *poller, updates chan Update) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !p.remove(updates) {
+		return
+	}
+	close(updates)
+	if p.empty() && h.pollers[p.key] == p {
+		p.close()
+		delete(h.pollers, p.key)
+	}
+}
+
+// Subscription receives the updates of a location
+type Subscription struct {
+	hub     *Hub
+	poller  *poller
+	updates chan Update
+	once    sync.Once
+}
+
+// Current returns the latest snapshot of the location
+func (s *Subscription) Current() Update {
+	return s.poller.current()
+}
+
+// Updates delivers the snapshots that differ from the previous one.
+// A slow reader only gets the latest snapshot. The channel is closed when the hub shuts down.
+func (s *Subscription) Updates() <-chan Update {
+	return s.updates
+}
+
+// Close stops receiving updates
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s.poller, s.updates)
+	})
+}
+
+type poller struct {
+	key      key
+	useCases *usecase.WeatherUseCases
+	interval time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan Update]struct{}
+	latest      Update
+	latestErr   error
+
+	ready chan struct{}
+	stop  chan struct{}
+}
+
+func (h *Hub) newPoller(k key) *poller {
+	return &poller{
+		key:         k,
+		useCases:    h.useCases,
+		interval:    h.interval,
+		subscribers: make(map[chan Update]struct{}),
+		ready:       make(chan struct{}),
+		stop:        make(chan struct{}),
+	}
+}
+
+func (p *poller) run() {
+	p.poll()
+	close(p.ready)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.poll()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// poll refreshes the location and broadcasts the snapshot when it changed.
+// Weather failures are part of the snapshot so the subscribers are told about them.
+func (p *poller) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), pollTimeout)
+	defer cancel()
+
+	report, err := p.useCases.GetWeatherReportByPostalCode(ctx, p.key.country, p.key.postalCode, true)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err != nil {
+		// A location that can't be resolved stops the poller from the first lookup,
+		// later failures keep the last snapshot until the next refresh
+		if p.latest.Report == nil {
+			p.latestErr = err
+		}
+		if code := problem.CodeOf(err); problem.Lookup(code).Status >= http.StatusInternalServerError {
+			slog.Warn("Live update lookup failed", "country", p.key.country, "code", code, "error", err)
+		}
+		return
+	}
+
+	update := Update{ID: fingerprint(report), Report: report, Time: time.Now()}
+	if update.ID == p.latest.ID {
+		return
+	}
+	// The first snapshot is read with Current, only the changes are delivered
+	first := p.latest.Report == nil
+	p.latest = update
+	p.latestErr = nil
+	if first {
+		return
+	}
+
+	for updates := range p.subscribers {
+		deliver(updates, update)
+	}
+}
+
+// deliver sends an update without blocking, replacing the pending update of a slow subscriber
+func deliver(updates chan Update, update Update) {
+	select {
+	case updates <- update:
+		return
+	default:
+	}
+	select {
+	case <-updates:
+	default:
+	}
+	select {
+	case updates <- update:
+	default:
+	}
+}
+
+func (p *poller) current() Update {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latest
+}
+
+func (p *poller) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.latestErr
+}
+
+func (p *poller) add(updates chan Update) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscribers[updates] = struct{}{}
+}
+
+func (p *poller) remove(updates chan Update) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.subscribers[updates]; !ok {
+		return false
+	}
+	delete(p.subscribers, updates)
+	return true
+}
+
+func (p *poller) empty() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.subscribers) == 0
+}
+
+// close stops the poller and ends the subscriptions still attached to it
+func (p *poller) close() {
+	close(p.stop)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for updates := range p.subscribers {
+		close(updates)
+		delete(p.subscribers, updates)
+	}
+}
+
+// fingerprint identifies the conditions of a report, two reports with the same conditions have the same fingerprint
+func fingerprint(report *entity.WeatherReport) string {
+	hash := fnv.New64a()
+	if report.Weather != nil {
+		w := report.Weather
+		fmt.Fprintf(hash, "%g|%s|%s|%s|%s", w.Celcius, reading(w.WindKph), reading(w.PressureMb), reading(w.PrecipMm), w.Condition)
+	}
+	if report.WeatherErr != nil {
+		fmt.Fprintf(hash, "|error:%s", problem.CodeOf(report.WeatherErr))
+	}
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// reading formats a reading for a fingerprint, a missing reading is empty
+func reading(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'g', -1, 64)
+}
@@ -0,0 +1,131 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCEPRepository resolves 01001000 and counts its calls
+type fakeCEPRepository struct {
+	calls atomic.Int32
+}
+
+func (f *fakeCEPRepository) GetCEP(_ context.Context, cep string) (*entity.CEP, error) {
+	f.calls.Add(1)
+	if cep == "01001000" {
+		return &entity.CEP{Localidade: "São Paulo", Uf: "SP"}, nil
+	}
+	return nil, &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")}
+}
+
+// fakeWeatherRepository answers the temperature it holds
+type fakeWeatherRepository struct {
+	celcius atomic.Uint64
+}
+
+func (f *fakeWeatherRepository) setCelcius(c float64) {
+	f.celcius.Store(math.Float64bits(c))
+}
+
+func (f *fakeWeatherRepository) GetWeatherInfo(_ context.Context, _ *entity.CEP) (*entity.WeatherInfo, error) {
+	return &entity.WeatherInfo{Celcius: math.Float64frombits(f.celcius.Load()), Condition: "Sunny", Valid: true}, nil
+}
+
+func newTestHub(interval time.Duration) (*Hub, *fakeCEPRepository, *fakeWeatherRepository) {
+	cepRepository := &fakeCEPRepository{}
+	weatherRepository := &fakeWeatherRepository{}
+	weatherRepository.setCelcius(25)
+	return NewHub(usecase.NewWeatherUseCases(cepRepository, weatherRepository), interval), cepRepository, weatherRepository
+}
+
+func TestSubscribersShareThePoller(t *testing.T) {
+	hub, cepRepository, _ := newTestHub(time.Hour)
+	defer hub.Shutdown(context.Background())
+
+	first, err := hub.Subscribe(context.Background(), "BR", "01001000")
+	require.NoError(t, err)
+	second, err := hub.Subscribe(context.Background(), "br", "01001000")
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), cepRepository.calls.Load())
+	assert.Equal(t, first.Current().ID, second.Current().ID)
+	assert.Equal(t, 25.0, first.Current().Report.Weather.Celcius)
+
+	first.Close()
+	assert.Len(t, hub.pollers, 1)
+	second.Close()
+	assert.Empty(t, hub.pollers)
+}
+
+func TestUpdatesOnlyWhenConditionsChange(t *testing.T) {
+	hub, _, weatherRepository := newTestHub(10 * time.Millisecond)
+	defer hub.Shutdown(context.Background())
+
+	sub, err := hub.Subscribe(context.Background(), "BR", "01001000")
+	require.NoError(t, err)
+	defer sub.Close()
+	initial := sub.Current()
+
+	select {
+	case update := <-sub.Updates():
+		t.Fatalf("unexpected update %s with unchanged conditions", update.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	weatherRepository.setCelcius(30)
+
+	select {
+	case update := <-sub.Updates():
+		assert.NotEqual(t, initial.ID, update.ID)
+		assert.Equal(t, 30.0, update.Report.Weather.Celcius)
+	case <-time.After(time.Second):
+		t.Fatal("no update after the conditions changed")
+	}
+}
+
+func TestSubscribeUnknownCEP(t *testing.T) {
+	hub, _, _ := newTestHub(time.Hour)
+	defer hub.Shutdown(context.Background())
+
+	sub, err := hub.Subscribe(context.Background(), "BR", "99999999")
+	assert.Nil(t, sub)
+	assert.ErrorIs(t, err, usecase.ErrCEPNotFound)
+	assert.Empty(t, hub.pollers)
+
+	_, err = hub.Subscribe(context.Background(), "BR", "123")
+	assert.ErrorIs(t, err, usecase.ErrInvalidCEP)
+}
+
+func TestShutdownEndsSubscriptions(t *testing.T) {
+	hub, _, _ := newTestHub(time.Hour)
+
+	sub, err := hub.Subscribe(context.Background(), "BR", "01001000")
+	require.NoError(t, err)
+
+	require.NoError(t, hub.Shutdown(context.Background()))
+	_, ok := <-sub.Updates()
+	assert.False(t, ok)
+	sub.Close()
+
+	_, err = hub.Subscribe(context.Background(), "BR", "01001000")
+	assert.ErrorIs(t, err, ErrHubClosed)
+}
+
+func TestFingerprint(t *testing.T) {
+	sunny := &entity.WeatherReport{Weather: &entity.WeatherInfo{Celcius: 25, Condition: "Sunny"}}
+	sameSunny := &entity.WeatherReport{Location: &entity.CEP{Localidade: "Other"}, Weather: &entity.WeatherInfo{Celcius: 25, Condition: "Sunny"}}
+	cloudy := &entity.WeatherReport{Weather: &entity.WeatherInfo{Celcius: 25, Condition: "Cloudy"}}
+	failed := &entity.WeatherReport{WeatherErr: usecase.ErrUpstreamTimeout}
+
+	assert.Equal(t, fingerprint(sunny), fingerprint(sameSunny))
+	assert.NotEqual(t, fingerprint(sunny), fingerprint(cloudy))
+	assert.NotEqual(t, fingerprint(sunny), fingerprint(failed))
+}
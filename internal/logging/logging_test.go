@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	type testRow struct {
+		value    string
+		expected slog.Level
+		wantErr  bool
+	}
+
+	testTable := []testRow{
+		{value: "", expected: slog.LevelInfo},
+		{value: "debug", expected: slog.LevelDebug},
+		{value: " WARN ", expected: slog.LevelWarn},
+		{value: "error", expected: slog.LevelError},
+		{value: "verbose", expected: slog.LevelInfo, wantErr: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.value, func(t *testing.T) {
+			level, err := ParseLevel(tr.value)
+			assert.Equal(t, tr.wantErr, err != nil)
+			assert.Equal(t, tr.expected, level)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(previous)
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID, Middleware)
+	router.Get("/weather/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Debug("Looking up the weather")
+		if chi.URLParam(r, "cep") == "00000000" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("sunny"))
+	})
+
+	for _, target := range []string{"/weather/20270150", "/weather/00000000"} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.RemoteAddr = "203.0.113.7:51234"
+		router.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 4)
+
+	// The logs of the handler share the request ID of the access log
+	assert.Equal(t, "Looking up the weather", entries[0]["msg"])
+	assert.Equal(t, entries[1]["request_id"], entries[0]["request_id"])
+	assert.NotEqual(t, entries[1]["request_id"], entries[3]["request_id"])
+
+	access := entries[1]
+	assert.Equal(t, "INFO", access["level"])
+	assert.Equal(t, "Request served", access["msg"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/weather/{cep}", access["route"])
+	assert.Equal(t, 200.0, access["status"])
+	assert.Equal(t, 5.0, access["bytes"])
+	assert.Equal(t, "203.0.113.7", access["client_ip"])
+	assert.Contains(t, access, "latency_ms")
+	assert.NotContains(t, buf.String(), "20270150")
+
+	assert.Equal(t, "ERROR", entries[3]["level"])
+	assert.Equal(t, 502.0, entries[3]["status"])
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Same(t, slog.Default(), FromContext(context.Background()))
+
+	logger := slog.New(slog.DiscardHandler)
+	assert.Same(t, logger, FromContext(WithLogger(context.Background(), logger)))
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "goweather"
+
+// Outcomes of the upstream calls
+const (
+	OutcomeOK          = "ok"
+	OutcomeNotFound    = "not_found"
+	OutcomeTimeout     = "timeout"
+	OutcomeUnavailable = "unavailable"
+	OutcomeRateLimited = "rate_limited"
+	OutcomeBadPayload  = "bad_payload"
+	OutcomeError       = "error"
+)
+
+// Results of the cache lookups
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Phases of the connection of the upstream calls
+const (
+	PhaseDNS     = "dns"
+	PhaseConnect = "connect"
+	PhaseTLS     = "tls"
+	PhaseTTFB    = "ttfb"
+)
+
+// upstreamOutcomes maps the kinds of upstream failures to their outcome label
+var upstreamOutcomes = []struct {
+	kind    error
+	outcome string
+}{
+	{entity.ErrUpstreamNotFound, OutcomeNotFound},
+	{entity.ErrUpstreamTimeout, OutcomeTimeout},
+	{entity.ErrUpstreamUnavailable, OutcomeUnavailable},
+	{entity.ErrUpstreamRateLimited, OutcomeRateLimited},
+	{entity.ErrUpstreamBadPayload, OutcomeBadPayload},
+}
+
+// Registry holds the collectors of the application, along with the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of the HTTP requests by route pattern, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	upstreamInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_requests_in_flight",
+		Help:      "Calls in progress to the external providers.",
+	}, []string{"provider"})
+	upstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Duration of the calls to the external providers by provider and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "outcome"})
+	upstreamErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_errors_total",
+		Help:      "Failed calls to the external providers by provider and outcome.",
+	}, []string{"provider", "outcome"})
+	upstreamPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_connection_phase_seconds",
+		Help:      "Duration of the DNS lookup, connect, TLS handshake and time to first byte of the calls to the external providers.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider", "phase"})
+	upstreamConnections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_connections_total",
+		Help:      "Connections used by the calls to the external providers by provider and whether they were reused.",
+	}, []string{"provider", "reused"})
+	upstreamKeyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_key_requests_total",
+		Help:      "Calls to the external providers by provider, fingerprint of the API key and outcome.",
+	}, []string{"provider", "key", "outcome"})
+	upstreamKeyQuarantined = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "upstream_key_quarantined",
+		Help:      "Whether an API key of an external provider is quarantined (1) or in use (0), by provider and fingerprint of the key.",
+	}, []string{"provider", "key"})
+
+	useCaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "usecase_duration_seconds",
+		Help:      "Duration of the use cases by operation and problem code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "code"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Lookups of the caches by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpInFlight, httpDuration,
+		upstreamInFlight, upstreamDuration, upstreamErrors, upstreamPhaseDuration, upstreamConnections,
+		upstreamKeyRequests, upstreamKeyQuarantined,
+		useCaseDuration, cacheRequests,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// StartUpstream records a call to a provider. The returned function ends it with the error of the call.
+func StartUpstream(provider string) func(err error) {
+	start := time.Now()
+	inFlight := upstreamInFlight.WithLabelValues(provider)
+	inFlight.Inc()
+
+	return func(err error) {
+		inFlight.Dec()
+		outcome := UpstreamOutcome(err)
+		upstreamDuration.WithLabelValues(provider, outcome).Observe(time.Since(start).Seconds())
+		if outcome != OutcomeOK {
+			upstreamErrors.WithLabelValues(provider, outcome).Inc()
+		}
+	}
+}
+
+// UpstreamPhase records the duration of a connection phase of a call to a provider
+func UpstreamPhase(provider, phase string, d time.Duration) {
+	upstreamPhaseDuration.WithLabelValues(provider, phase).Observe(d.Seconds())
+}
+
+// UpstreamConnection counts a connection used by a call to a provider
+func UpstreamConnection(provider string, reused bool) {
+	upstreamConnections.WithLabelValues(provider, strconv.FormatBool(reused)).Inc()
+}
+
+// UpstreamKeyRequest counts a call to a provider with the API key of fingerprint key, by the outcome of the call
+func UpstreamKeyRequest(provider, key string, err error) {
+	upstreamKeyRequests.WithLabelValues(provider, key, UpstreamOutcome(err)).Inc()
+}
+
+// UpstreamKeyQuarantined records whether the API key of fingerprint key is quarantined
+func UpstreamKeyQuarantined(provider, key string, quarantined bool) {
+	value := 0.0
+	if quarantined {
+		value = 1
+	}
+	upstreamKeyQuarantined.WithLabelValues(provider, key).Set(value)
+}
+
+// ForgetUpstreamKey drops the series of an API key removed from the configuration
+func ForgetUpstreamKey(provider, key string) {
+	labels := prometheus.Labels{"provider": provider, "key": key}
+	upstreamKeyRequests.DeletePartialMatch(labels)
+	upstreamKeyQuarantined.Delete(labels)
+}
+
+// UpstreamOutcome classifies the error of an upstream call, errors without a known kind are reported as OutcomeError
+func UpstreamOutcome(err error) string {
+	if err == nil {
+		return OutcomeOK
+	}
+	for _, o := range upstreamOutcomes {
+		if errors.Is(err, o.kind) {
+			return o.outcome
+		}
+	}
+	return OutcomeError
+}
+
+// StartUseCase records a use case operation. The returned function ends it with the error of the operation.
+func StartUseCase(operation string) func(err error) {
+	start := time.Now()
+
+	return func(err error) {
+		code := "OK"
+		if err != nil {
+			code = string(problem.CodeOf(err))
+		}
+		useCaseDuration.WithLabelValues(operation, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// CacheLookup counts a lookup of a cache
+func CacheLookup(cache string, hit bool) {
+	result := CacheMiss
+	if hit {
+		result = CacheHit
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
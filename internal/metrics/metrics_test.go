@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUpstreamOutcome(t *testing.T) {
+	type testRow struct {
+		name     string
+		err      error
+		expected string
+	}
+
+	testTable := []testRow{
+		{name: "success", err: nil, expected: OutcomeOK},
+		{name: "not found", err: &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")}, expected: OutcomeNotFound},
+		{name: "wrapped timeout", err: fmt.Errorf("lookup: %w", &entity.UpstreamError{Kind: entity.ErrUpstreamTimeout, Err: context.DeadlineExceeded}), expected: OutcomeTimeout},
+		{name: "rate limited", err: &entity.UpstreamError{Kind: entity.ErrUpstreamRateLimited, StatusCode: 429, Err: errors.New("quota")}, expected: OutcomeRateLimited},
+		{name: "unknown error", err: errors.New("failed to create request"), expected: OutcomeError},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, UpstreamOutcome(tr.err))
+		})
+	}
+}
+
+func TestStartUpstream(t *testing.T) {
+	done := StartUpstream("test-upstream")
+	assert.Equal(t, 1.0, testutil.ToFloat64(upstreamInFlight.WithLabelValues("test-upstream")))
+	done(&entity.UpstreamError{Kind: entity.ErrUpstreamUnavailable, StatusCode: 503, Err: errors.New("down")})
+	StartUpstream("test-upstream")(nil)
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(upstreamInFlight.WithLabelValues("test-upstream")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(upstreamErrors.WithLabelValues("test-upstream", OutcomeUnavailable)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(upstreamErrors.WithLabelValues("test-upstream", OutcomeOK)))
+	assert.Equal(t, 2, testutil.CollectAndCount(upstreamDuration.MustCurryWith(map[string]string{"provider": "test-upstream"})))
+}
+
+func TestUpstreamConnection(t *testing.T) {
+	UpstreamPhase("test-upstream", PhaseConnect, 30*time.Millisecond)
+	UpstreamPhase("test-upstream", PhaseTTFB, 80*time.Millisecond)
+	UpstreamConnection("test-upstream", false)
+	UpstreamConnection("test-upstream", true)
+	UpstreamConnection("test-upstream", true)
+
+	output := scrape(t)
+	assert.Contains(t, output, `goweather_upstream_connection_phase_seconds_count{phase="connect",provider="test-upstream"} 1`)
+	assert.Contains(t, output, `goweather_upstream_connection_phase_seconds_count{phase="ttfb",provider="test-upstream"} 1`)
+	assert.Contains(t, output, `goweather_upstream_connections_total{provider="test-upstream",reused="false"} 1`)
+	assert.Contains(t, output, `goweather_upstream_connections_total{provider="test-upstream",reused="true"} 2`)
+}
+
+func TestUpstreamKey(t *testing.T) {
+	UpstreamKeyRequest("test-upstream", "0123abcd", nil)
+	UpstreamKeyRequest("test-upstream", "0123abcd", &entity.UpstreamError{Kind: entity.ErrUpstreamRateLimited, StatusCode: 403, Err: errors.New("quota")})
+	UpstreamKeyQuarantined("test-upstream", "0123abcd", true)
+
+	output := scrape(t)
+	assert.Contains(t, output, `goweather_upstream_key_requests_total{key="0123abcd",outcome="ok",provider="test-upstream"} 1`)
+	assert.Contains(t, output, `goweather_upstream_key_requests_total{key="0123abcd",outcome="rate_limited",provider="test-upstream"} 1`)
+	assert.Contains(t, output, `goweather_upstream_key_quarantined{key="0123abcd",provider="test-upstream"} 1`)
+
+	ForgetUpstreamKey("test-upstream", "0123abcd")
+	assert.NotContains(t, scrape(t), `key="0123abcd"`)
+}
+
+func TestStartUseCase(t *testing.T) {
+	StartUseCase("test-operation")(nil)
+	StartUseCase("test-operation")(fmt.Errorf("%w: %w", problem.New(problem.CodeCEPNotFound, "cep not found"), errors.New("upstream")))
+
+	output := scrape(t)
+	assert.Contains(t, output, `goweather_usecase_duration_seconds_count{code="OK",operation="test-operation"} 1`)
+	assert.Contains(t, output, `goweather_usecase_duration_seconds_count{code="CEP_NOT_FOUND",operation="test-operation"} 1`)
+}
+
+func TestMiddleware(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/test/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"fresh"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	type testRow struct {
+		target string
+		etag   string
+		status int
+	}
+
+	testTable := []testRow{
+		{target: "/test/1", status: http.StatusOK},
+		{target: "/test/2", etag: `"stale"`, status: http.StatusOK},
+		{target: "/test/3", etag: `"fresh"`, status: http.StatusNotModified},
+		{target: "/unknown/path", status: http.StatusNotFound},
+	}
+
+	for _, tr := range testTable {
+		r := httptest.NewRequest(http.MethodGet, tr.target, nil)
+		if tr.etag != "" {
+			r.Header.Set("If-None-Match", tr.etag)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, r)
+		require.Equal(t, tr.status, recorder.Code)
+	}
+
+	output := scrape(t)
+	assert.Contains(t, output, `goweather_http_request_duration_seconds_count{method="GET",route="/test/{id}",status="200"} 2`)
+	assert.Contains(t, output, `goweather_http_request_duration_seconds_count{method="GET",route="/test/{id}",status="304"} 1`)
+	assert.Contains(t, output, `goweather_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+	assert.Equal(t, 1.0, testutil.ToFloat64(cacheRequests.WithLabelValues(CacheHTTPConditional, CacheHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(cacheRequests.WithLabelValues(CacheHTTPConditional, CacheMiss)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(httpInFlight))
+}
+
+// scrape returns the metrics as served to Prometheus
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "go_goroutines")
+	return recorder.Body.String()
+}
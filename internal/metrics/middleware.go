@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CacheHTTPConditional is the cache of the clients, a conditional request answered with 304 Not Modified is a hit
+const CacheHTTPConditional = "http_conditional"
+
+// unmatchedRoute labels the requests that matched no route, so unknown paths don't create new series
+const unmatchedRoute = "unmatched"
+
+// Middleware records the duration of the requests by route pattern and status, and the requests in flight
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		httpDuration.WithLabelValues(route, r.Method, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			CacheLookup(CacheHTTPConditional, status == http.StatusNotModified)
+		}
+	})
+}
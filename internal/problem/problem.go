@@ -0,0 +1,111 @@
+package problem
+
+import (
+	"errors"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"net/http"
+	"strings"
+)
+
+// Code is a stable machine-readable identifier of an error. Clients match on it, so codes must never change.
+type Code string
+
+const (
+	CodeCEPInvalid         Code = "CEP_INVALID"
+	CodeCEPNotFound        Code = "CEP_NOT_FOUND"
+	CodePostalCodeInvalid  Code = "POSTAL_CODE_INVALID"
+	CodePostalCodeNotFound Code = "POSTAL_CODE_NOT_FOUND"
+	CodeCountryUnsupported Code = "COUNTRY_UNSUPPORTED"
+	CodeUnitsInvalid       Code = "UNITS_INVALID"
+	CodeWeatherNotFound    Code = "WEATHER_NOT_FOUND"
+	CodeMessageInvalid     Code = "MESSAGE_INVALID"
+	CodeSubscriptionLimit  Code = "SUBSCRIPTION_LIMIT_EXCEEDED"
+	CodeWebhookInvalid     Code = "WEBHOOK_INVALID"
+	CodeWebhookNotFound    Code = "WEBHOOK_NOT_FOUND"
+	CodeWebhookDelivery    Code = "WEBHOOK_DELIVERY_FAILED"
+	CodeUnauthorized       Code = "UNAUTHORIZED"
+	CodeNotFound           Code = "NOT_FOUND"
+	CodeMethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+	CodeBatchInvalid       Code = "BATCH_INVALID"
+	CodeNotAcceptable      Code = "NOT_ACCEPTABLE"
+	CodeCEPUpstreamError   Code = "CEP_UPSTREAM_ERROR"
+	CodeWeatherUpstream    Code = "WEATHER_UPSTREAM_ERROR"
+	CodeUpstreamTimeout    Code = "UPSTREAM_TIMEOUT"
+	CodeUpstreamDown       Code = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamRateLimit  Code = "UPSTREAM_RATE_LIMITED"
+	CodeUpstreamBadPayload Code = "UPSTREAM_BAD_PAYLOAD"
+	CodeInternal           Code = "INTERNAL_ERROR"
+)
+
+// Definition describes how an error code is presented to clients
+type Definition struct {
+	Status int
+	Title  string
+	// MessageKey is the i18n key of the localized detail
+	MessageKey string
+}
+
+// catalog is the single source of the error codes, their HTTP status and message
+var catalog = map[Code]Definition{
+	CodeCEPInvalid:         {http.StatusUnprocessableEntity, "Invalid CEP", i18n.MsgInvalidZipcode},
+	CodeCEPNotFound:        {http.StatusNotFound, "CEP not found", i18n.MsgZipcodeNotFound},
+	CodePostalCodeInvalid:  {http.StatusUnprocessableEntity, "Invalid postal code", i18n.MsgInvalidZipcode},
+	CodePostalCodeNotFound: {http.StatusNotFound, "Postal code not found", i18n.MsgZipcodeNotFound},
+	CodeCountryUnsupported: {http.StatusNotFound, "Unsupported country", i18n.MsgUnsupportedCountry},
+	CodeUnitsInvalid:       {http.StatusBadRequest, "Invalid unit system", i18n.MsgInvalidUnits},
+	CodeWeatherNotFound:    {http.StatusNotFound, "Weather not found", i18n.MsgWeatherNotFound},
+	CodeMessageInvalid:     {http.StatusBadRequest, "Invalid message", i18n.MsgInvalidMessage},
+	CodeSubscriptionLimit:  {http.StatusTooManyRequests, "Too many subscriptions", i18n.MsgSubscriptionLimit},
+	CodeWebhookInvalid:     {http.StatusUnprocessableEntity, "Invalid webhook", i18n.MsgInvalidWebhook},
+	CodeWebhookNotFound:    {http.StatusNotFound, "Webhook not found", i18n.MsgWebhookNotFound},
+	CodeWebhookDelivery:    {http.StatusBadGateway, "Webhook delivery failed", i18n.MsgWebhookDelivery},
+	CodeUnauthorized:       {http.StatusUnauthorized, "Unauthorized", i18n.MsgUnauthorized},
+	CodeNotFound:           {http.StatusNotFound, "Not found", i18n.MsgNotFound},
+	CodeMethodNotAllowed:   {http.StatusMethodNotAllowed, "Method not allowed", i18n.MsgMethodNotAllowed},
+	CodeBatchInvalid:       {http.StatusBadRequest, "Invalid batch", i18n.MsgInvalidBatch},
+	CodeNotAcceptable:      {http.StatusNotAcceptable, "Not acceptable", i18n.MsgNotAcceptable},
+	CodeCEPUpstreamError:   {http.StatusBadGateway, "CEP provider error", i18n.MsgUnexpectedError},
+	CodeWeatherUpstream:    {http.StatusBadGateway, "Weather provider error", i18n.MsgUnexpectedError},
+	CodeUpstreamTimeout:    {http.StatusGatewayTimeout, "Upstream timeout", i18n.MsgUpstreamTimeout},
+	CodeUpstreamDown:       {http.StatusServiceUnavailable, "Upstream unavailable", i18n.MsgUpstreamUnavailable},
+	CodeUpstreamRateLimit:  {http.StatusTooManyRequests, "Upstream rate limited", i18n.MsgUpstreamRateLimited},
+	CodeUpstreamBadPayload: {http.StatusBadGateway, "Upstream bad payload", i18n.MsgUpstreamBadPayload},
+	CodeInternal:           {http.StatusInternalServerError, "Internal error", i18n.MsgUnexpectedError},
+}
+
+// Error is an error identified by a code of the catalog
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// New creates an error with a code of the catalog
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CodeOf returns the code of the first Error in the chain of err, or CodeInternal when there is none
+func CodeOf(err error) Code {
+	var pe *Error
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return CodeInternal
+}
+
+// Lookup returns the definition of a code. Unknown codes are described as internal errors.
+func Lookup(code Code) Definition {
+	if def, ok := catalog[code]; ok {
+		return def
+	}
+	return catalog[CodeInternal]
+}
+
+// Type returns the problem type URI of a code
+func Type(code Code) string {
+	return "urn:go-weather:problem:" + strings.ToLower(strings.ReplaceAll(string(code), "_", "-"))
+}
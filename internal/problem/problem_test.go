@@ -0,0 +1,46 @@
+package problem
+
+import (
+	"fmt"
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	errInvalid := New(CodeCEPInvalid, "invalid cep")
+
+	assert.Equal(t, CodeCEPInvalid, CodeOf(errInvalid))
+	assert.Equal(t, CodeCEPInvalid, CodeOf(fmt.Errorf("wrapped: %w", errInvalid)))
+	assert.Equal(t, CodeInternal, CodeOf(fmt.Errorf("plain error")))
+}
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, http.StatusUnprocessableEntity, Lookup(CodeCEPInvalid).Status)
+	assert.Equal(t, http.StatusGatewayTimeout, Lookup(CodeUpstreamTimeout).Status)
+	assert.Equal(t, Lookup(CodeInternal), Lookup("UNKNOWN_CODE"))
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for code, def := range catalog {
+		assert.NotZero(t, def.Status, "status of %s", code)
+		assert.NotEmpty(t, def.Title, "title of %s", code)
+		assert.NotEmpty(t, def.MessageKey, "message key of %s", code)
+	}
+}
+
+func TestType(t *testing.T) {
+	assert.Equal(t, "urn:go-weather:problem:cep-not-found", Type(CodeCEPNotFound))
+}
+
+func TestCatalogMatchesSpec(t *testing.T) {
+	enum, err := docs.SchemaEnum("ErrorCode")
+	assert.NoError(t, err)
+
+	var codes []string
+	for code := range catalog {
+		codes = append(codes, string(code))
+	}
+	assert.ElementsMatch(t, codes, enum)
+}
@@ -0,0 +1,95 @@
+package redact
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces the redacted secrets
+const Placeholder = "REDACTED"
+
+// secretPatterns match the secrets of known shapes, their first group is kept
+var secretPatterns = []*regexp.Regexp{
+	// Credentials in the query string of a URL, such as the key of WeatherAPI
+	regexp.MustCompile(`(?i)([?&](?:key|api_?key|access_?token|token|secret|password)=)[^&#\s"']+`),
+	// Bearer tokens of Authorization headers
+	regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`),
+}
+
+// secretAttrs are the keys of the log attributes holding a secret, their value is never logged
+var secretAttrs = map[string]bool{
+	"api_key":       true,
+	"apikey":        true,
+	"access_token":  true,
+	"token":         true,
+	"secret":        true,
+	"password":      true,
+	"authorization": true,
+}
+
+// Error wraps err so its message does not disclose the secrets, replaced by Placeholder.
+// errors.Is and errors.As still match the wrapped errors, whose messages are not redacted.
+func Error(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+func (e *redactedError) Error() string {
+	return Secrets(e.err.Error(), e.secrets...)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Secrets replaces the secrets in s by Placeholder, as they are and escaped in a URL
+func Secrets(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		for _, form := range []string{secret, url.QueryEscape(secret), url.PathEscape(secret)} {
+			s = strings.ReplaceAll(s, form, Placeholder)
+		}
+	}
+	return s
+}
+
+// Scrub replaces the secrets of known shapes in s by Placeholder, such as the credentials in the query string of a URL
+func Scrub(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+Placeholder)
+	}
+	return s
+}
+
+// ReplaceAttr scrubs the secrets from the log records, it is meant for slog.HandlerOptions.
+// The attributes named after a secret are redacted, the message, strings, errors and stringers are scrubbed.
+func ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if secretAttrs[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Placeholder)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Scrub(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Scrub(v.String()))
+		}
+	}
+	return a
+}
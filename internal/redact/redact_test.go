@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/url"
+	"testing"
+)
+
+const secret = "s3cr3t+key/42"
+
+func TestError(t *testing.T) {
+	urlErr := &url.Error{Op: "Get", URL: "https://api.weatherapi.com/v1/current.json?key=" + url.QueryEscape(secret) + "&q=Paris", Err: context.DeadlineExceeded}
+	err := fmt.Errorf("failed to execute request: %w", Error(urlErr, secret))
+
+	assert.Equal(t, `failed to execute request: Get "https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Paris": context deadline exceeded`, err.Error())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var target *url.Error
+	assert.ErrorAs(t, err, &target)
+
+	assert.Equal(t, "raw REDACTED", Error(errors.New("raw "+secret), secret).Error())
+	assert.NoError(t, Error(nil, secret))
+}
+
+func TestScrub(t *testing.T) {
+	type testRow struct {
+		name     string
+		input    string
+		expected string
+	}
+
+	testTable := []testRow{
+		{name: "query key", input: `Get "https://api.weatherapi.com/v1/current.json?key=abc123&q=Paris"`, expected: `Get "https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Paris"`},
+		{name: "last query parameter", input: "https://example.com/hook?id=1&token=abc123", expected: "https://example.com/hook?id=1&token=REDACTED"},
+		{name: "api_key parameter", input: "https://example.com/?API_KEY=abc123 failed", expected: "https://example.com/?API_KEY=REDACTED failed"},
+		{name: "bearer token", input: "Authorization: Bearer abc.123-xyz=", expected: "Authorization: Bearer REDACTED"},
+		{name: "without secret", input: "https://viacep.com.br/ws/{cep}/json?monkey=1", expected: "https://viacep.com.br/ws/{cep}/json?monkey=1"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			assert.Equal(t, tr.expected, Scrub(tr.input))
+		})
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
+
+func TestReplaceAttr(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr}))
+
+	leakedURL := "https://api.weatherapi.com/v1/current.json?key=" + secret + "&q=Paris"
+	logger.Warn("Upstream call failed: Get "+leakedURL,
+		"error", errors.New("Get \""+leakedURL+"\": timeout"),
+		"url", leakedURL,
+		"endpoint", stringer(leakedURL),
+		slog.Group("request", "api_key", secret, "Authorization", "Bearer "+secret),
+		"key", "0a1b2c3d",
+	)
+
+	output := buf.String()
+	assert.NotContains(t, output, secret)
+	assert.NotContains(t, output, url.QueryEscape(secret))
+	assert.Contains(t, output, `"msg":"Upstream call failed: Get https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Paris"`)
+	assert.Contains(t, output, `"error":"Get \"https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Paris\": timeout"`)
+	assert.Contains(t, output, `"endpoint":"https://api.weatherapi.com/v1/current.json?key=REDACTED&q=Paris"`)
+	assert.Contains(t, output, `"request":{"api_key":"REDACTED","Authorization":"REDACTED"}`)
+	// The fingerprints of the keys are not secret
+	assert.Contains(t, output, `"key":"0a1b2c3d"`)
+	assert.Contains(t, output, `"level":"WARN"`)
+}
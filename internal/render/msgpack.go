@@ -0,0 +1,21 @@
+package render
+
+import (
+	"bytes"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// marshalMessagePack encodes resp as MessagePack with the same fields as its JSON encoding.
+// The wire type of a value follows its Go type, a float64 is always a float64 even when it is whole.
+// Map keys are sorted and struct fields keep their order, so the output, and so its ETag, is deterministic.
+func marshalMessagePack(resp any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	if err := enc.Encode(resp); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
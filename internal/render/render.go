@@ -0,0 +1,326 @@
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/i18n"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/timing"
+	"github.com/caricciy/go-weather/internal/util"
+	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is an encoding of the responses
+type Format string
+
+const (
+	JSON        Format = "json"
+	XML         Format = "xml"
+	CSV         Format = "csv"
+	MessagePack Format = "msgpack"
+	Protobuf    Format = "protobuf"
+)
+
+var ErrNotAcceptable = errors.New("no acceptable response format")
+
+// mediaTypes maps the media types accepted from the clients to their format, in the order of preference of the server
+var mediaTypes = []struct {
+	mediaType string
+	format    Format
+}{
+	{"application/json", JSON},
+	{"application/xml", XML},
+	{"text/xml", XML},
+	{"text/csv", CSV},
+	{"application/msgpack", MessagePack},
+	{"application/x-msgpack", MessagePack},
+	{"application/vnd.msgpack", MessagePack},
+	{"application/x-protobuf", Protobuf},
+	{"application/protobuf", Protobuf},
+	{"application/vnd.google.protobuf", Protobuf},
+}
+
+// contentTypes is the Content-Type sent with each format
+var contentTypes = map[Format]string{
+	JSON:        "application/json",
+	XML:         "application/xml; charset=utf-8",
+	CSV:         "text/csv; charset=utf-8",
+	MessagePack: "application/msgpack",
+	Protobuf:    "application/x-protobuf",
+}
+
+// Table is implemented by the responses that can be written as CSV. The first record is the header.
+type Table interface {
+	CSV() [][]string
+}
+
+// Message is implemented by the responses that can be written as protobuf
+type Message interface {
+	Proto() proto.Message
+}
+
+// Negotiate picks the format of a response from the format query parameter or, in its absence, the Accept header.
+// JSON is used when the client has no preference.
+func Negotiate(r *http.Request) (Format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		format := Format(strings.ToLower(name))
+		if _, ok := contentTypes[format]; !ok {
+			return "", fmt.Errorf("%w: %s", ErrNotAcceptable, name)
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return JSON, nil
+	}
+	if format, ok := negotiateAccept(strings.Join(accept, ",")); ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotAcceptable, strings.Join(accept, ","))
+}
+
+// mediaRange is an entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateAccept picks the format of the most preferred media range of an Accept header.
+// Specific media types are preferred over wildcards of the same quality, and q=0 excludes a media type.
+func negotiateAccept(header string) (Format, bool) {
+	var ranges []mediaRange
+	excluded := make(map[string]bool)
+	for _, entry := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			excluded[mediaType] = true
+			continue
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i].mediaType) > specificity(ranges[j].mediaType)
+	})
+
+	for _, mr := range ranges {
+		for _, mt := range mediaTypes {
+			if !excluded[mt.mediaType] && matches(mr.mediaType, mt.mediaType) {
+				return mt.format, true
+			}
+		}
+	}
+	return "", false
+}
+
+func specificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matches(mediaRange, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(mediaRange, "*")
+	return ok && strings.HasSuffix(prefix, "/") && strings.HasPrefix(mediaType, prefix)
+}
+
+// Send writes resp in the given format with its status. The response varies with the Accept header.
+func Send(w http.ResponseWriter, r *http.Request, format Format, resp any, status int) {
+	w.Header().Add("Vary", "Accept")
+
+	data, err := Encode(format, resp)
+	if err != nil {
+		sendEncodeError(w, r, format, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
+
+// Freshness tells for how long a response may be cached and when its data last changed
+type Freshness struct {
+	// MaxAge is the lifetime of the response, caches revalidate it on every use when it is zero
+	MaxAge time.Duration
+	// LastModified is zero when unknown
+	LastModified time.Time
+}
+
+// SendCached writes a cacheable 200 response in the given format with a strong ETag of its encoding.
+// Conditional requests with If-None-Match or If-Modified-Since are answered with 304 Not Modified.
+// Range requests get the whole response, as the responses are small and encoded on every request.
+func SendCached(w http.ResponseWriter, r *http.Request, format Format, resp any, freshness Freshness) {
+	w.Header().Add("Vary", "Accept")
+
+	// The encode phase encodes the response and derives its ETag, it ends before the headers are written
+	_, stopEncode := timing.Start(r.Context(), timing.PhaseEncode)
+	data, err := Encode(format, resp)
+	if err != nil {
+		stopEncode()
+		sendEncodeError(w, r, format, err)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	stopEncode()
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl(freshness.MaxAge))
+	if !freshness.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", freshness.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, freshness.LastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
+
+// notModified evaluates the preconditions of a GET or HEAD request as RFC 9110 does.
+// If-Modified-Since is ignored when If-None-Match is present, and compared with the second precision of the header.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagMatches compares the entity tags of an If-None-Match header with etag, weakly
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheControl(maxAge time.Duration) string {
+	seconds := int64(maxAge / time.Second)
+	if seconds <= 0 {
+		return "no-cache"
+	}
+	return "public, max-age=" + strconv.FormatInt(seconds, 10)
+}
+
+// problemResponse is the Problem of the API, as the handlers send it
+type problemResponse struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      problem.Code `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// sendEncodeError answers a response that could not be encoded with the internal error of the catalog, as problem details
+func sendEncodeError(w http.ResponseWriter, r *http.Request, format Format, err error) {
+	logging.FromContext(r.Context()).Error("Failed to encode response", "format", format, "error", err)
+
+	def := problem.Lookup(problem.CodeInternal)
+	util.SendProblemJSON(w, problemResponse{
+		Type:      problem.Type(problem.CodeInternal),
+		Title:     def.Title,
+		Status:    def.Status,
+		Detail:    i18n.Message(i18n.FromContext(r.Context()), def.MessageKey),
+		Instance:  r.URL.Path,
+		Code:      problem.CodeInternal,
+		RequestID: middleware.GetReqID(r.Context()),
+	}, def.Status)
+}
+
+// Encode encodes resp in the given format. XML uses the xml tags of resp, MessagePack its json tags.
+func Encode(format Format, resp any) ([]byte, error) {
+	switch format {
+	case JSON:
+		return json.Marshal(resp)
+	case XML:
+		data, err := xml.Marshal(resp)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), data...), nil
+	case CSV:
+		table, ok := resp.(Table)
+		if !ok {
+			return nil, fmt.Errorf("%T can not be written as CSV", resp)
+		}
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		if err := writer.WriteAll(table.CSV()); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case MessagePack:
+		return marshalMessagePack(resp)
+	case Protobuf:
+		message, ok := resp.(Message)
+		if !ok {
+			return nil, fmt.Errorf("%T can not be written as protobuf", resp)
+		}
+		return proto.Marshal(message.Proto())
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrNotAcceptable, format)
+	}
+}
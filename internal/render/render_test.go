@@ -0,0 +1,249 @@
+package render
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"github.com/caricciy/go-weather/internal/docs"
+	"github.com/caricciy/go-weather/internal/grpcapi/weatherv1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	type testRow struct {
+		name     string
+		target   string
+		accept   string
+		expected Format
+		wantErr  bool
+	}
+
+	testTable := []testRow{
+		{name: "no preference", target: "/", expected: JSON},
+		{name: "any type", target: "/", accept: "*/*", expected: JSON},
+		{name: "xml", target: "/", accept: "application/xml", expected: XML},
+		{name: "text xml", target: "/", accept: "text/xml", expected: XML},
+		{name: "csv with charset", target: "/", accept: "text/csv; charset=utf-8", expected: CSV},
+		{name: "msgpack alias", target: "/", accept: "application/x-msgpack", expected: MessagePack},
+		{name: "protobuf", target: "/", accept: "application/x-protobuf", expected: Protobuf},
+		{name: "highest quality wins", target: "/", accept: "application/json;q=0.5, text/csv;q=0.9", expected: CSV},
+		{name: "specific type beats wildcard", target: "/", accept: "*/*, application/xml", expected: XML},
+		{name: "browser", target: "/", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", expected: XML},
+		{name: "type wildcard", target: "/", accept: "text/*", expected: XML},
+		{name: "excluded type", target: "/", accept: "*/*, application/json;q=0", expected: XML},
+		{name: "unsupported type", target: "/", accept: "application/pdf", wantErr: true},
+		{name: "format parameter wins", target: "/?format=CSV", accept: "application/json", expected: CSV},
+		{name: "unsupported format parameter", target: "/?format=yaml", wantErr: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tr.target, nil)
+			if tr.accept != "" {
+				r.Header.Set("Accept", tr.accept)
+			}
+
+			format, err := Negotiate(r)
+			if tr.wantErr {
+				assert.ErrorIs(t, err, ErrNotAcceptable)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tr.expected, format)
+		})
+	}
+}
+
+type testResponse struct {
+	XMLName xml.Name `json:"-" xml:"test"`
+	Name    string   `json:"name" xml:"name"`
+	Value   *float64 `json:"value,omitempty" xml:"value,omitempty"`
+	Tags    []string `json:"tags" xml:"tag"`
+}
+
+func (r testResponse) CSV() [][]string {
+	return [][]string{{"name", "tags"}, {r.Name, "a,b"}}
+}
+
+func (r testResponse) Proto() proto.Message {
+	return &weatherv1.Location{City: r.Name}
+}
+
+func TestEncode(t *testing.T) {
+	value := 1.5
+	resp := testResponse{Name: "São Paulo", Value: &value, Tags: []string{"a", "b"}}
+
+	type testRow struct {
+		format   Format
+		expected string
+	}
+
+	testTable := []testRow{
+		{JSON, `{"name":"São Paulo","value":1.5,"tags":["a","b"]}`},
+		{XML, xml.Header + `<test><name>São Paulo</name><value>1.5</value><tag>a</tag><tag>b</tag></test>`},
+		{CSV, "name,tags\nSão Paulo,\"a,b\"\n"},
+	}
+
+	for _, tr := range testTable {
+		t.Run(string(tr.format), func(t *testing.T) {
+			data, err := Encode(tr.format, resp)
+			require.NoError(t, err)
+			assert.Equal(t, tr.expected, string(data))
+		})
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		data, err := Encode(Protobuf, resp)
+		require.NoError(t, err)
+		var location weatherv1.Location
+		require.NoError(t, proto.Unmarshal(data, &location))
+		assert.Equal(t, "São Paulo", location.City)
+	})
+
+	t.Run("unsupported by the response", func(t *testing.T) {
+		_, err := Encode(CSV, map[string]string{})
+		assert.Error(t, err)
+	})
+}
+
+func TestMessagePack(t *testing.T) {
+	type testRow struct {
+		name     string
+		value    any
+		expected string
+	}
+
+	testTable := []testRow{
+		{"fixmap with sorted keys", map[string]any{"b": 1, "a": true}, "82a161c3a16201"},
+		{"float", 1.5, "cb3ff8000000000000"},
+		{"whole float", float64(2), "cb4000000000000000"},
+		{"float32", float32(1.5), "ca3fc00000"},
+		{"uint", uint(200), "ccc8"},
+		{"struct with json tags", struct {
+			Temp    float64  `json:"temp_C"`
+			Wind    *float64 `json:"wind_kph,omitempty"`
+			Skipped string   `json:"-"`
+			hidden  string
+			Name    string
+		}{Temp: 25, Skipped: "x", hidden: "y", Name: "a"}, "82a674656d705f43cb4039000000000000a44e616d65a161"},
+		{"bin", []byte{1, 2}, "c4020102"},
+		{"negative fixint", -1, "ff"},
+		{"int", 1000, "cd03e8"},
+		{"null and array", []any{nil, "x"}, "92c0a178"},
+		{"str8", string(make([]byte, 40)), "d928" + hex.EncodeToString(make([]byte, 40))},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			data, err := marshalMessagePack(tr.value)
+			require.NoError(t, err)
+			assert.Equal(t, tr.expected, hex.EncodeToString(data))
+		})
+	}
+}
+
+func TestSendUnsupportedResponse(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Send(recorder, httptest.NewRequest(http.MethodGet, "/weather/20270150", nil), Protobuf, map[string]string{}, http.StatusOK)
+
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+	assert.JSONEq(t, `{
+		"type": "urn:go-weather:problem:internal-error",
+		"title": "Internal error",
+		"status": 500,
+		"detail": "An unexpected error occurred",
+		"instance": "/weather/20270150",
+		"code": "INTERNAL_ERROR"
+	}`, recorder.Body.String())
+}
+
+func TestProblemMatchesSpec(t *testing.T) {
+	drift, err := docs.SchemaDrift("Problem", problemResponse{})
+	assert.NoError(t, err)
+	assert.Empty(t, drift)
+}
+
+func TestSendCached(t *testing.T) {
+	resp := testResponse{Name: "São Paulo"}
+	modified := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	first := httptest.NewRecorder()
+	SendCached(first, httptest.NewRequest(http.MethodGet, "/", nil), JSON, resp, Freshness{MaxAge: 90 * time.Second, LastModified: modified})
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "public, max-age=90", first.Header().Get("Cache-Control"))
+	assert.Equal(t, "Thu, 15 Jan 2026 12:00:00 GMT", first.Header().Get("Last-Modified"))
+	assert.JSONEq(t, `{"name":"São Paulo","tags":null}`, first.Body.String())
+
+	type testRow struct {
+		name     string
+		format   Format
+		header   string
+		value    string
+		expected int
+	}
+
+	testTable := []testRow{
+		{name: "matching etag", format: JSON, header: "If-None-Match", value: etag, expected: http.StatusNotModified},
+		{name: "weak matching etag", format: JSON, header: "If-None-Match", value: "W/" + etag, expected: http.StatusNotModified},
+		{name: "etag of another format", format: XML, header: "If-None-Match", value: etag, expected: http.StatusOK},
+		{name: "stale etag", format: JSON, header: "If-None-Match", value: `"stale"`, expected: http.StatusOK},
+		{name: "not modified since", format: JSON, header: "If-Modified-Since", value: "Thu, 15 Jan 2026 12:00:00 GMT", expected: http.StatusNotModified},
+		{name: "modified since", format: JSON, header: "If-Modified-Since", value: "Thu, 15 Jan 2026 11:59:59 GMT", expected: http.StatusOK},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(tr.header, tr.value)
+			recorder := httptest.NewRecorder()
+			SendCached(recorder, r, tr.format, resp, Freshness{MaxAge: 90 * time.Second, LastModified: modified})
+
+			assert.Equal(t, tr.expected, recorder.Code)
+			assert.Equal(t, "public, max-age=90", recorder.Header().Get("Cache-Control"))
+			if tr.expected == http.StatusNotModified {
+				assert.Equal(t, etag, recorder.Header().Get("ETag"))
+				assert.Empty(t, recorder.Body.String())
+			}
+		})
+	}
+
+	t.Run("etag takes precedence over the modification date", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("If-None-Match", `"stale"`)
+		r.Header.Set("If-Modified-Since", "Thu, 15 Jan 2026 12:00:00 GMT")
+		recorder := httptest.NewRecorder()
+		SendCached(recorder, r, JSON, resp, Freshness{MaxAge: 90 * time.Second, LastModified: modified})
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("ranges get the whole response", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Range", "bytes=0-3")
+		r.Header.Set("If-Range", etag)
+		recorder := httptest.NewRecorder()
+		SendCached(recorder, r, JSON, resp, Freshness{MaxAge: 90 * time.Second, LastModified: modified})
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, first.Body.String(), recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get("Accept-Ranges"))
+		assert.Empty(t, recorder.Header().Get("Content-Range"))
+	})
+
+	t.Run("revalidated when not fresh", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		SendCached(recorder, httptest.NewRequest(http.MethodGet, "/", nil), JSON, resp, Freshness{})
+
+		assert.Equal(t, "no-cache", recorder.Header().Get("Cache-Control"))
+		assert.Empty(t, recorder.Header().Get("Last-Modified"))
+	})
+}
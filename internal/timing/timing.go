@@ -0,0 +1,165 @@
+package timing
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Phases of a lookup
+const (
+	PhaseValidation = "validation"
+	PhaseCEP        = "cep"
+	PhaseWeather    = "weather"
+	PhaseEncode     = "encode"
+)
+
+// phaseTotal is the Server-Timing metric of the whole request
+const phaseTotal = "total"
+
+type collectorKey struct{}
+
+type stepKey struct{}
+
+// Step is a timed phase of a request
+type Step struct {
+	Phase string
+	// Provider is the provider that served the phase, empty when the phase did not call one
+	Provider string
+	Duration time.Duration
+}
+
+// Collector collects the steps of a request. It is safe for concurrent use, the lookups of a batch share it.
+type Collector struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// step is a phase in progress, carried in the context of the calls it times
+type step struct {
+	mu       sync.Mutex
+	provider string
+}
+
+// NewContext returns a copy of ctx carrying a new collector
+func NewContext(ctx context.Context) (context.Context, *Collector) {
+	collector := &Collector{}
+	return context.WithValue(ctx, collectorKey{}, collector), collector
+}
+
+// FromContext returns the collector of ctx, nil when the request is not timed
+func FromContext(ctx context.Context) *Collector {
+	collector, _ := ctx.Value(collectorKey{}).(*Collector)
+	return collector
+}
+
+// Start times a phase of the request of ctx. The returned context tells the calls of the phase apart,
+// so they can report their provider with SetProvider, the returned function ends the phase.
+// It is a no-op when the request is not timed.
+func Start(ctx context.Context, phase string) (context.Context, func()) {
+	collector := FromContext(ctx)
+	if collector == nil {
+		return ctx, func() {}
+	}
+
+	start := time.Now()
+	s := &step{}
+	return context.WithValue(ctx, stepKey{}, s), func() {
+		s.mu.Lock()
+		provider := s.provider
+		s.mu.Unlock()
+
+		collector.mu.Lock()
+		defer collector.mu.Unlock()
+		collector.steps = append(collector.steps, Step{Phase: phase, Provider: provider, Duration: time.Since(start)})
+	}
+}
+
+// SetProvider records the provider serving the phase of ctx
+func SetProvider(ctx context.Context, provider string) {
+	if s, ok := ctx.Value(stepKey{}).(*step); ok {
+		s.mu.Lock()
+		s.provider = provider
+		s.mu.Unlock()
+	}
+}
+
+// Steps returns the ended steps, in the order they ended
+func (c *Collector) Steps() []Step {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Step(nil), c.steps...)
+}
+
+// Header formats the steps as a Server-Timing header, with the total duration of the request.
+// The steps of a phase are summed, as the lookups of a batch each time the same phases.
+func (c *Collector) Header(total time.Duration) string {
+	var phases []string
+	durations := map[string]time.Duration{}
+	providers := map[string][]string{}
+	for _, s := range c.Steps() {
+		if _, ok := durations[s.Phase]; !ok {
+			phases = append(phases, s.Phase)
+		}
+		durations[s.Phase] += s.Duration
+		if s.Provider != "" && !slices.Contains(providers[s.Phase], s.Provider) {
+			providers[s.Phase] = append(providers[s.Phase], s.Provider)
+		}
+	}
+
+	metrics := make([]string, 0, len(phases)+1)
+	for _, phase := range phases {
+		metric := phase + ";dur=" + milliseconds(durations[phase])
+		if len(providers[phase]) > 0 {
+			metric += `;desc="` + strings.Join(providers[phase], ",") + `"`
+		}
+		metrics = append(metrics, metric)
+	}
+	metrics = append(metrics, phaseTotal+";dur="+milliseconds(total))
+	return strings.Join(metrics, ", ")
+}
+
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// Middleware times the requests, adding the Server-Timing header to their responses
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, collector := NewContext(r.Context())
+		tw := &timingWriter{ResponseWriter: w, collector: collector, start: time.Now()}
+		next.ServeHTTP(tw, r.WithContext(ctx))
+	})
+}
+
+// timingWriter sets the Server-Timing header when the response headers are written
+type timingWriter struct {
+	http.ResponseWriter
+	collector   *Collector
+	start       time.Time
+	wroteHeader bool
+}
+
+func (w *timingWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.collector.Header(time.Since(w.start)))
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,95 @@
+package timing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	ctx, collector := NewContext(context.Background())
+
+	_, stop := Start(ctx, PhaseValidation)
+	stop()
+	cepCtx, stop := Start(ctx, PhaseCEP)
+	SetProvider(cepCtx, "viacep")
+	stop()
+	// The provider of a phase does not leak to the next phases
+	SetProvider(ctx, "weatherapi")
+	_, stop = Start(ctx, PhaseWeather)
+	stop()
+
+	steps := collector.Steps()
+	require.Len(t, steps, 3)
+	assert.Equal(t, Step{Phase: PhaseValidation, Duration: steps[0].Duration}, steps[0])
+	assert.Equal(t, Step{Phase: PhaseCEP, Provider: "viacep", Duration: steps[1].Duration}, steps[1])
+	assert.Equal(t, Step{Phase: PhaseWeather, Duration: steps[2].Duration}, steps[2])
+}
+
+func TestStartWithoutCollector(t *testing.T) {
+	ctx, stop := Start(context.Background(), PhaseCEP)
+	SetProvider(ctx, "viacep")
+	stop()
+
+	assert.Nil(t, FromContext(ctx))
+}
+
+func TestHeader(t *testing.T) {
+	type testRow struct {
+		name     string
+		steps    []Step
+		expected string
+	}
+
+	testTable := []testRow{
+		{
+			name:     "no step",
+			expected: "total;dur=12.500",
+		},
+		{
+			name: "lookup",
+			steps: []Step{
+				{Phase: PhaseValidation, Duration: 4 * time.Microsecond},
+				{Phase: PhaseCEP, Provider: "viacep", Duration: 5 * time.Millisecond},
+				{Phase: PhaseWeather, Provider: "weatherapi", Duration: 7 * time.Millisecond},
+			},
+			expected: `validation;dur=0.004, cep;dur=5.000;desc="viacep", weather;dur=7.000;desc="weatherapi", total;dur=12.500`,
+		},
+		{
+			name: "phases of a batch are summed",
+			steps: []Step{
+				{Phase: PhaseCEP, Provider: "viacep", Duration: 2 * time.Millisecond},
+				{Phase: PhaseCEP, Provider: "viacep", Duration: 3 * time.Millisecond},
+				{Phase: PhaseCEP, Provider: "zippopotam", Duration: time.Millisecond},
+			},
+			expected: `cep;dur=6.000;desc="viacep,zippopotam", total;dur=12.500`,
+		},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			collector := &Collector{steps: tr.steps}
+			assert.Equal(t, tr.expected, collector.Header(12500*time.Microsecond))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, stop := Start(r.Context(), PhaseCEP)
+		SetProvider(ctx, "viacep")
+		stop()
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/cep/01001000", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Regexp(t, regexp.MustCompile(`^cep;dur=[0-9.]+;desc="viacep", total;dur=[0-9.]+$`), recorder.Header().Get("Server-Timing"))
+}
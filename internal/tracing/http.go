@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strings"
+)
+
+// redactedURLKey is the context key of the URL recorded in place of the URL of an outbound call
+type redactedURLKey struct{}
+
+// Markers holding the redacted URL of a client span and the redacted path of a server span until the span ends
+const (
+	redactedURLMarker  = attribute.Key("goweather.redacted.url")
+	redactedPathMarker = attribute.Key("goweather.redacted.path")
+)
+
+// redactions maps the URL attributes of otelhttp, of the current and the old HTTP semantic conventions, to the marker replacing them
+var redactions = map[attribute.Key]attribute.Key{
+	semconv.URLFullKey:           redactedURLMarker,
+	attribute.Key("http.url"):    redactedURLMarker,
+	semconv.URLPathKey:           redactedPathMarker,
+	attribute.Key("http.target"): redactedPathMarker,
+}
+
+// sensitiveParams are the route params holding a postal code
+var sensitiveParams = map[string]bool{"cep": true, "postalcode": true}
+
+// Middleware extracts the incoming trace context and records a server span of each request, named after its route pattern.
+// The postal codes are removed from the recorded path, and conditional requests are tagged with their cache outcome.
+func Middleware(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		span := trace.SpanFromContext(r.Context())
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()), redactedPathMarker.String(redactedPath(r.URL.Path, rctx.URLParams)))
+		}
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			span.SetAttributes(CacheOutcome(ww.Status() == http.StatusNotModified))
+		}
+	})
+
+	return otelhttp.NewHandler(named, "http.server")
+}
+
+// redactedPath replaces the postal codes of a path by the name of their param
+func redactedPath(path string, params chi.RouteParams) string {
+	segments := strings.Split(path, "/")
+	for i, key := range params.Keys {
+		if !sensitiveParams[key] {
+			continue
+		}
+		for j, segment := range segments {
+			if segment == params.Values[i] {
+				segments[j] = "{" + key + "}"
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// NewTransport instruments the outbound calls made through base, injecting the trace context in their headers
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// WithRedactedURL sets the URL recorded by the spans of the outbound calls made with ctx,
+// for URLs that carry a postal code or a credential
+func WithRedactedURL(ctx context.Context, url string) context.Context {
+	return context.WithValue(ctx, redactedURLKey{}, url)
+}
+
+// redactingProcessor hands the spans to next with their URLs replaced by the redacted ones.
+// otelhttp sets the URL attributes after starting the span, so they are replaced when the span ends.
+type redactingProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+func (p redactingProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	if url, ok := ctx.Value(redactedURLKey{}).(string); ok && span.SpanKind() == trace.SpanKindClient {
+		span.SetAttributes(redactedURLMarker.String(url))
+	}
+	p.next.OnStart(ctx, span)
+}
+
+func (p redactingProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	markers := map[attribute.Key]string{}
+	for _, attr := range span.Attributes() {
+		if attr.Key == redactedURLMarker || attr.Key == redactedPathMarker {
+			markers[attr.Key] = attr.Value.AsString()
+		}
+	}
+	if len(markers) == 0 {
+		p.next.OnEnd(span)
+		return
+	}
+
+	var attrs []attribute.KeyValue
+	for _, attr := range span.Attributes() {
+		if _, ok := markers[attr.Key]; ok {
+			continue
+		}
+		if value, ok := markers[redactions[attr.Key]]; ok {
+			attr = attr.Key.String(value)
+		}
+		attrs = append(attrs, attr)
+	}
+	p.next.OnEnd(redactedSpan{ReadOnlySpan: span, attrs: attrs})
+}
+
+func (p redactingProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p redactingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// redactedSpan is a span with its redacted attributes
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs []attribute.KeyValue
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"sync/atomic"
+)
+
+// Exporters of the spans
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	instrumentationName = "github.com/caricciy/go-weather"
+	serviceName         = "go-weather"
+)
+
+// Attribute keys of the application spans
+const (
+	KeyCEPHash      = attribute.Key("cep.hash")
+	KeyCountry      = attribute.Key("cep.country")
+	KeyProvider     = attribute.Key("upstream.provider")
+	KeyOutcome      = attribute.Key("upstream.outcome")
+	KeyAPIKey       = attribute.Key("upstream.key")
+	KeyProblemCode  = attribute.Key("problem.code")
+	KeyCacheOutcome = attribute.Key("cache.outcome")
+)
+
+// ErrUnknownExporter is returned by Setup for an exporter other than none, stdout and otlp
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Setup installs the W3C trace context propagator and a tracer provider exporting the spans with exporter.
+// The OTLP exporter is configured by the standard OTEL_EXPORTER_OTLP_* variables. With ExporterNone the spans are
+// not recorded, but the incoming trace context is still propagated to the providers.
+// The returned function flushes the pending spans and stops the provider.
+func Setup(ctx context.Context, exporter string) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s exporter: %w", exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	// Variables such as OTEL_SERVICE_NAME take precedence over the default service name
+	if envRes, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		res, _ = resource.Merge(res, envRes)
+	}
+
+	provider := NewTracerProvider(res, sdktrace.NewBatchSpanProcessor(spanExporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider handing the spans to processor once their postal codes and credentials are redacted
+func NewTracerProvider(res *resource.Resource, processor sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(sdktrace.WithResource(res), sdktrace.WithSpanProcessor(redactingProcessor{next: processor}))
+}
+
+// Start starts a span of the application. The returned function ends it, adding attrs and setting the error status on err.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(err error, attrs ...attribute.KeyValue)) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+
+	return ctx, func(err error, attrs ...attribute.KeyValue) {
+		span.SetAttributes(attrs...)
+		if err != nil {
+			span.SetStatus(codes.Error, errorDescription(err))
+		}
+		span.End()
+	}
+}
+
+// errorDescription describes an error by its kind. The message of err is left out, as the errors of the providers
+// carry the URL of the request, with the postal code and the credentials.
+func errorDescription(err error) string {
+	var pe *problem.Error
+	if errors.As(err, &pe) {
+		return string(pe.Code)
+	}
+	var ue *entity.UpstreamError
+	if errors.As(err, &ue) {
+		return fmt.Sprintf("%s: %v", ue.Provider, ue.Kind)
+	}
+	return "error"
+}
+
+// cepHashKey keys the hashes of the postal codes, it is random until SetCEPHashKey sets the key of the deployment
+var cepHashKey atomic.Pointer[[]byte]
+
+func init() {
+	SetCEPHashKey("")
+}
+
+// SetCEPHashKey sets the key of the hashes of the postal codes. Without the key, the few postal codes could be
+// hashed one by one to find the CEP of a hash. An empty key is replaced by a random one, the hashes then change on every restart.
+func SetCEPHashKey(key string) {
+	k := []byte(key)
+	if key == "" {
+		k = make([]byte, 32)
+		_, _ = rand.Read(k)
+	}
+	cepHashKey.Store(&k)
+}
+
+// HashCEP hashes a postal code with the HMAC-SHA256 of the CEP hash key, so spans can be correlated by CEP without revealing it.
+// The hashes are only comparable between the instances sharing the key.
+func HashCEP(cep string) string {
+	mac := hmac.New(sha256.New, *cepHashKey.Load())
+	mac.Write([]byte(cep))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// CEP is the attribute of the hash of a postal code
+func CEP(cep string) attribute.KeyValue {
+	return KeyCEPHash.String(HashCEP(cep))
+}
+
+// CacheOutcome is the attribute of the result of a cache lookup
+func CacheOutcome(hit bool) attribute.KeyValue {
+	if hit {
+		return KeyCacheOutcome.String("hit")
+	}
+	return KeyCacheOutcome.String("miss")
+}
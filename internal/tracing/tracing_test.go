@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordSpans installs a tracer provider recording the ended spans for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := NewTracerProvider(resource.Empty(), recorder)
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+// spanNamed returns the ended span with the given name
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q", name)
+	return nil
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key string) string {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestHashCEP(t *testing.T) {
+	t.Cleanup(func() { SetCEPHashKey("") })
+
+	hash := HashCEP("20270150")
+	assert.Len(t, hash, 16)
+	assert.Equal(t, hash, HashCEP("20270150"))
+	assert.NotEqual(t, hash, HashCEP("01001000"))
+	assert.NotContains(t, hash, "20270150")
+
+	// The hash is keyed, it is not the plain SHA-256 of the CEP that anyone could compute
+	SetCEPHashKey("deployment-key")
+	// First 8 bytes of the HMAC-SHA256 of "20270150" keyed by "deployment-key"
+	assert.Equal(t, "2d07e5753ca80e5f", HashCEP("20270150"))
+	assert.NotEqual(t, hash, HashCEP("20270150"))
+	unkeyed := sha256.Sum256([]byte("20270150"))
+	assert.NotEqual(t, hex.EncodeToString(unkeyed[:8]), HashCEP("20270150"))
+
+	SetCEPHashKey("other-deployment-key")
+	assert.NotEqual(t, "2d07e5753ca80e5f", HashCEP("20270150"))
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), ExporterNone)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), "zipkin")
+	assert.ErrorIs(t, err, ErrUnknownExporter)
+}
+
+func TestStart(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, end := Start(context.Background(), "ok", CEP("20270150"))
+	end(nil, KeyOutcome.String("ok"))
+	_, end = Start(context.Background(), "failed")
+	end(fmt.Errorf("%w: %w", problem.New(problem.CodeCEPUpstreamError, "could not fetch cep information"), &entity.UpstreamError{
+		Provider: "viacep",
+		Kind:     entity.ErrUpstreamTimeout,
+		Err:      errors.New(`Get "https://viacep.com.br/ws/20270150/json": context deadline exceeded`),
+	}))
+
+	ok := spanNamed(t, recorder, "ok")
+	assert.Equal(t, HashCEP("20270150"), attributeValue(ok, string(KeyCEPHash)))
+	assert.Equal(t, "ok", attributeValue(ok, string(KeyOutcome)))
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+
+	failed := spanNamed(t, recorder, "failed")
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "CEP_UPSTREAM_ERROR", failed.Status().Description)
+	assert.Empty(t, failed.Events())
+
+	assert.Equal(t, "viacep: upstream timeout", errorDescription(&entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamTimeout, Err: errors.New("timeout")}))
+	assert.Equal(t, "error", errorDescription(errors.New(`Get "https://api.weatherapi.com/v1/current.json?key=secret"`)))
+}
+
+func TestPropagationAndRedaction(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var upstreamTraceParent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceParent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+
+	router := chi.NewRouter()
+	router.Use(Middleware)
+	router.Get("/weather/{cep}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithRedactedURL(r.Context(), upstream.URL+"/ws/{cep}/json")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/ws/"+chi.URLParam(r, "cep")+"/json", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	r := httptest.NewRequest(http.MethodGet, "/weather/20270150", nil)
+	r.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.Header.Set("If-None-Match", `"etag"`)
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	server := spanNamed(t, recorder, "GET /weather/{cep}")
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, traceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, "/weather/{cep}", attributeValue(server, string(semconv.HTTPRouteKey)))
+	assert.Equal(t, "hit", attributeValue(server, string(KeyCacheOutcome)))
+
+	var clientSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindClient {
+			clientSpan = span
+		}
+	}
+	require.NotNil(t, clientSpan)
+	assert.Equal(t, traceID, clientSpan.SpanContext().TraceID().String())
+	assert.Equal(t, upstream.URL+"/ws/{cep}/json", attributeValue(clientSpan, string(semconv.URLFullKey)))
+	assert.Contains(t, upstreamTraceParent, traceID)
+	assert.Contains(t, upstreamTraceParent, clientSpan.SpanContext().SpanID().String())
+
+	for _, span := range recorder.Ended() {
+		for _, attr := range span.Attributes() {
+			assert.NotContains(t, attr.Value.Emit(), "20270150", "attribute %s of span %s", attr.Key, span.Name())
+		}
+	}
+}
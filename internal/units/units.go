@@ -0,0 +1,137 @@
+package units
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// System is a set of units used to present measurements
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+	SI       System = "si"
+	All      System = "all"
+)
+
+// DefaultPrecision is the number of decimal places used when no precision is configured
+const DefaultPrecision = 2
+
+var ErrUnknownSystem = errors.New("unknown unit system")
+
+// ParseSystem parses a unit system name. An empty name selects All.
+func ParseSystem(name string) (System, error) {
+	switch s := System(strings.ToLower(name)); s {
+	case "":
+		return All, nil
+	case Metric, Imperial, SI, All:
+		return s, nil
+	default:
+		return "", ErrUnknownSystem
+	}
+}
+
+// Canonical holds the measurements in the units they are stored in: °C, km/h, mb (hPa) and mm.
+// Wind, pressure and precipitation are nil when they were not reported.
+type Canonical struct {
+	TempC      float64
+	WindKph    *float64
+	PressureMb *float64
+	PrecipMm   *float64
+}
+
+// Measurements holds the measurements converted to a unit system. Fields outside the system, or not reported, are nil.
+type Measurements struct {
+	Celsius    *float64
+	Fahrenheit *float64
+	Kelvin     *float64
+
+	WindKph *float64
+	WindMph *float64
+	WindMs  *float64
+
+	PressureMb   *float64
+	PressureInHg *float64
+	PressurePa   *float64
+
+	PrecipMm *float64
+	PrecipIn *float64
+}
+
+// Convert converts the canonical measurements to the given system, rounding every value to precision decimal places
+func Convert(c Canonical, system System, precision int) Measurements {
+	value := func(v float64) *float64 {
+		rounded := Round(v, precision)
+		return &rounded
+	}
+	// reading converts a reading that may not have been reported
+	reading := func(v *float64, convert func(float64) float64) *float64 {
+		if v == nil {
+			return nil
+		}
+		return value(convert(*v))
+	}
+	same := func(v float64) float64 { return v }
+
+	var m Measurements
+	if system == Metric || system == All {
+		m.Celsius = value(c.TempC)
+		m.WindKph = reading(c.WindKph, same)
+		m.PressureMb = reading(c.PressureMb, same)
+		m.PrecipMm = reading(c.PrecipMm, same)
+	}
+	if system == Imperial || system == All {
+		m.Fahrenheit = value(CelsiusToFahrenheit(c.TempC))
+		m.WindMph = reading(c.WindKph, KphToMph)
+		m.PressureInHg = reading(c.PressureMb, MillibarToInHg)
+		m.PrecipIn = reading(c.PrecipMm, MillimeterToInch)
+	}
+	if system == SI || system == All {
+		m.Kelvin = value(CelsiusToKelvin(c.TempC))
+		m.WindMs = reading(c.WindKph, KphToMetersPerSecond)
+		m.PressurePa = reading(c.PressureMb, MillibarToPascal)
+		// Millimetres are kept for precipitation, metres would round most readings to zero
+		m.PrecipMm = reading(c.PrecipMm, same)
+	}
+
+	return m
+}
+
+// Round rounds v to the given number of decimal places. A negative precision disables rounding.
+func Round(v float64, precision int) float64 {
+	if precision < 0 {
+		return v
+	}
+	p := math.Pow(10, float64(precision))
+	return math.Round(v*p) / p
+}
+
+func CelsiusToFahrenheit(c float64) float64 {
+	return c*9/5 + 32
+}
+
+func CelsiusToKelvin(c float64) float64 {
+	return c + 273.15
+}
+
+func KphToMph(kph float64) float64 {
+	return kph / 1.609344
+}
+
+func KphToMetersPerSecond(kph float64) float64 {
+	return kph / 3.6
+}
+
+func MillibarToInHg(mb float64) float64 {
+	return mb * 0.0295299830714
+}
+
+func MillibarToPascal(mb float64) float64 {
+	return mb * 100
+}
+
+func MillimeterToInch(mm float64) float64 {
+	return mm / 25.4
+}
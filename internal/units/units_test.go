@@ -0,0 +1,99 @@
+package units
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type parseSystemTestRow struct {
+	name        string
+	input       string
+	expected    System
+	expectedErr error
+}
+
+func TestParseSystem(t *testing.T) {
+	testTable := []parseSystemTestRow{
+		{"Empty defaults to all", "", All, nil},
+		{"Metric", "metric", Metric, nil},
+		{"Imperial uppercase", "IMPERIAL", Imperial, nil},
+		{"SI", "si", SI, nil},
+		{"Unknown", "nautical", "", ErrUnknownSystem},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			system, err := ParseSystem(tr.input)
+			assert.Equal(t, tr.expectedErr, err)
+			assert.Equal(t, tr.expected, system)
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	canonical := Canonical{TempC: 25, WindKph: floatPtr(36), PressureMb: floatPtr(1013.25), PrecipMm: floatPtr(2.54)}
+
+	t.Run("Metric", func(t *testing.T) {
+		m := Convert(canonical, Metric, 2)
+		assert.Equal(t, 25.0, *m.Celsius)
+		assert.Equal(t, 36.0, *m.WindKph)
+		assert.Equal(t, 1013.25, *m.PressureMb)
+		assert.Equal(t, 2.54, *m.PrecipMm)
+		assert.Nil(t, m.Fahrenheit)
+		assert.Nil(t, m.Kelvin)
+	})
+
+	t.Run("Imperial", func(t *testing.T) {
+		m := Convert(canonical, Imperial, 2)
+		assert.Equal(t, 77.0, *m.Fahrenheit)
+		assert.Equal(t, 22.37, *m.WindMph)
+		assert.Equal(t, 29.92, *m.PressureInHg)
+		assert.Equal(t, 0.1, *m.PrecipIn)
+		assert.Nil(t, m.Celsius)
+	})
+
+	t.Run("SI", func(t *testing.T) {
+		m := Convert(canonical, SI, 2)
+		assert.Equal(t, 298.15, *m.Kelvin)
+		assert.Equal(t, 10.0, *m.WindMs)
+		assert.Equal(t, 101325.0, *m.PressurePa)
+		assert.Nil(t, m.Celsius)
+	})
+
+	t.Run("All", func(t *testing.T) {
+		m := Convert(canonical, All, 1)
+		assert.Equal(t, 25.0, *m.Celsius)
+		assert.Equal(t, 77.0, *m.Fahrenheit)
+		assert.Equal(t, 298.2, *m.Kelvin)
+	})
+
+	t.Run("Zero readings are kept", func(t *testing.T) {
+		m := Convert(Canonical{WindKph: floatPtr(0)}, Metric, 2)
+		assert.NotNil(t, m.Celsius)
+		assert.Equal(t, 0.0, *m.Celsius)
+		assert.Equal(t, 0.0, *m.WindKph)
+	})
+
+	t.Run("Missing readings are left out", func(t *testing.T) {
+		m := Convert(Canonical{TempC: 25}, All, 2)
+		assert.Equal(t, 25.0, *m.Celsius)
+		assert.Nil(t, m.WindKph)
+		assert.Nil(t, m.WindMph)
+		assert.Nil(t, m.WindMs)
+		assert.Nil(t, m.PressureMb)
+		assert.Nil(t, m.PressureInHg)
+		assert.Nil(t, m.PressurePa)
+		assert.Nil(t, m.PrecipMm)
+		assert.Nil(t, m.PrecipIn)
+	})
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestRound(t *testing.T) {
+	assert.Equal(t, 1.23, Round(1.2345, 2))
+	assert.Equal(t, 1.0, Round(1.2345, 0))
+	assert.Equal(t, 1.2345, Round(1.2345, -1))
+}
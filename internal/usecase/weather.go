@@ -3,65 +3,253 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/logging"
+	"github.com/caricciy/go-weather/internal/metrics"
+	"github.com/caricciy/go-weather/internal/problem"
+	"github.com/caricciy/go-weather/internal/timing"
+	"github.com/caricciy/go-weather/internal/tracing"
+	"github.com/caricciy/go-weather/internal/units"
 	"github.com/caricciy/go-weather/internal/util"
+	"go.opentelemetry.io/otel/attribute"
+	"strings"
+	"time"
 )
 
+// DefaultCountry is the country used when a postal code is looked up without one
+const DefaultCountry = "BR"
+
+// Errors of the use cases. Their codes come from the problem catalog so every transport reports them alike.
 var (
-	ErrInvalidCEP           = errors.New("invalid cep")
-	ErrCEPNotFound          = errors.New("cep not found")
-	ErrCouldNotFetchCEP     = errors.New("could not fetch cep information")
-	ErrCouldNotFetchWeather = errors.New("could not fetch weather information")
-	ErrWeatherNotFound      = errors.New("weather information not found")
+	ErrInvalidCEP           error = problem.New(problem.CodeCEPInvalid, "invalid cep")
+	ErrCEPNotFound          error = problem.New(problem.CodeCEPNotFound, "cep not found")
+	ErrInvalidPostalCode    error = problem.New(problem.CodePostalCodeInvalid, "invalid postal code")
+	ErrPostalCodeNotFound   error = problem.New(problem.CodePostalCodeNotFound, "postal code not found")
+	ErrUnsupportedCountry   error = problem.New(problem.CodeCountryUnsupported, "unsupported country")
+	ErrCouldNotFetchCEP     error = problem.New(problem.CodeCEPUpstreamError, "could not fetch cep information")
+	ErrCouldNotFetchWeather error = problem.New(problem.CodeWeatherUpstream, "could not fetch weather information")
+	ErrWeatherNotFound      error = problem.New(problem.CodeWeatherNotFound, "weather information not found")
+	ErrUpstreamTimeout      error = problem.New(problem.CodeUpstreamTimeout, "upstream timeout")
+	ErrUpstreamUnavailable  error = problem.New(problem.CodeUpstreamDown, "upstream unavailable")
+	ErrUpstreamRateLimited  error = problem.New(problem.CodeUpstreamRateLimit, "upstream rate limited")
+	ErrUpstreamBadPayload   error = problem.New(problem.CodeUpstreamBadPayload, "upstream bad payload")
 )
 
 type WeatherUseCases struct {
-	cepRepository     entity.CEPRepository
-	weatherRepository entity.WeatherRepository
+	// postalCodeRepositories maps a country (ISO 3166-1 alpha-2) to its postal code provider
+	postalCodeRepositories map[string]entity.CEPRepository
+	weatherRepository      entity.WeatherRepository
+	forecastRepository     entity.ForecastRepository
 }
 
-// NewWeatherUseCases creates a new instance of WeatherUseCases
+// NewWeatherUseCases creates a new instance of WeatherUseCases.
+// The cepRepository is used for Brazilian CEPs, other countries are added with RegisterPostalCodeRepository.
 func NewWeatherUseCases(cepRepository entity.CEPRepository, weatherRepository entity.WeatherRepository) *WeatherUseCases {
 	return &WeatherUseCases{
-		cepRepository:     cepRepository,
-		weatherRepository: weatherRepository,
+		postalCodeRepositories: map[string]entity.CEPRepository{DefaultCountry: cepRepository},
+		weatherRepository:      weatherRepository,
 	}
 }
 
+// RegisterPostalCodeRepository sets the postal code provider of a country.
+// It must be called before the use cases start serving requests.
+func (s *WeatherUseCases) RegisterPostalCodeRepository(country string, repository entity.CEPRepository) {
+	s.postalCodeRepositories[strings.ToUpper(country)] = repository
+}
+
+// SetForecastRepository sets the forecast provider, without one every forecast is reported as not found.
+// It must be called before the use cases start serving requests.
+func (s *WeatherUseCases) SetForecastRepository(repository entity.ForecastRepository) {
+	s.forecastRepository = repository
+}
+
 // GetWeatherByCEP retrieves weather information based on the provided CEP (postal code).
 func (s *WeatherUseCases) GetWeatherByCEP(ctx context.Context, cep string) (*entity.WeatherInfo, error) {
+	return s.GetWeatherByPostalCode(ctx, DefaultCountry, cep)
+}
+
+// GetWeatherByPostalCode retrieves weather information based on a postal code of the given country.
+func (s *WeatherUseCases) GetWeatherByPostalCode(ctx context.Context, country, postalCode string) (*entity.WeatherInfo, error) {
+	report, err := s.GetWeatherReportByPostalCode(ctx, country, postalCode, false)
+	if err != nil {
+		return nil, err
+	}
+	return report.Weather, nil
+}
 
-	if !util.CheckCEPIsValid(cep) {
-		return nil, ErrInvalidCEP
+// GetWeatherReportByCEP retrieves the location and weather of a CEP, see GetWeatherReportByPostalCode.
+func (s *WeatherUseCases) GetWeatherReportByCEP(ctx context.Context, cep string, partial bool) (*entity.WeatherReport, error) {
+	return s.GetWeatherReportByPostalCode(ctx, DefaultCountry, cep, partial)
+}
+
+// GetWeatherReportByPostalCode retrieves the location and weather of a postal code of the given country.
+// When partial is true, a failure of the weather step is reported in WeatherErr instead of failing the lookup,
+// so the resolved location is not lost.
+func (s *WeatherUseCases) GetWeatherReportByPostalCode(ctx context.Context, country, postalCode string, partial bool) (_ *entity.WeatherReport, err error) {
+	ctx, done := startOperation(ctx, "report", "WeatherUseCases.GetWeatherReportByPostalCode", tracing.CEP(postalCode), tracing.KeyCountry.String(country))
+	defer func() { done(err) }()
+
+	location, err := s.GetLocationByPostalCode(ctx, country, postalCode)
+	if err != nil {
+		return nil, err
+	}
+
+	weather, err := s.GetWeatherByLocation(ctx, location)
+	if err != nil {
+		if !partial {
+			return nil, err
+		}
+		return &entity.WeatherReport{Location: location, WeatherErr: err}, nil
+	}
+
+	return &entity.WeatherReport{Location: location, Weather: weather}, nil
+}
+
+// GetLocationByPostalCode validates a postal code and retrieves its location from the country's provider
+func (s *WeatherUseCases) GetLocationByPostalCode(ctx context.Context, country, postalCode string) (_ *entity.CEP, err error) {
+	ctx, done := startOperation(ctx, "location", "WeatherUseCases.GetLocationByPostalCode", tracing.CEP(postalCode), tracing.KeyCountry.String(country))
+	defer func() { done(err) }()
+
+	country = strings.ToUpper(country)
+
+	_, stopValidation := timing.Start(ctx, timing.PhaseValidation)
+	repository, err := s.postalCodeRepository(country, postalCode)
+	stopValidation()
+	if err != nil {
+		return nil, err
 	}
 
 	// Get CEP information
-	c, err := s.cepRepository.GetCEP(ctx, cep)
+	cepCtx, stopCEP := timing.Start(ctx, timing.PhaseCEP)
+	c, err := repository.GetCEP(cepCtx, postalCode)
+	stopCEP()
+
+	notFound := ErrPostalCodeNotFound
+	if country == DefaultCountry {
+		notFound = ErrCEPNotFound
+	}
 
 	if err != nil {
-		return nil, ErrCouldNotFetchCEP
+		return nil, wrapUpstreamError(err, ErrCouldNotFetchCEP, notFound)
 	}
 
 	if c.Localidade == "" {
-		return nil, ErrCEPNotFound
+		return nil, notFound
+	}
+
+	return c, nil
+}
+
+// postalCodeRepository validates a postal code of a country and returns the provider of the country
+func (s *WeatherUseCases) postalCodeRepository(country, postalCode string) (entity.CEPRepository, error) {
+	repository, ok := s.postalCodeRepositories[country]
+	if !ok || !util.IsSupportedCountry(country) {
+		return nil, ErrUnsupportedCountry
 	}
 
+	if !util.CheckPostalCodeIsValid(country, postalCode) {
+		if country == DefaultCountry {
+			return nil, ErrInvalidCEP
+		}
+		return nil, ErrInvalidPostalCode
+	}
+	return repository, nil
+}
+
+// GetWeatherByLocation retrieves the weather of a location resolved by GetLocationByPostalCode
+func (s *WeatherUseCases) GetWeatherByLocation(ctx context.Context, c *entity.CEP) (_ *entity.WeatherInfo, err error) {
+	ctx, done := startOperation(ctx, "weather", "WeatherUseCases.GetWeatherByLocation")
+	defer func() { done(err) }()
+
 	// Get WeatherInfo based on the CEP information
-	stepWeatherInfo, err := s.weatherRepository.GetWeatherInfo(ctx, c)
+	weatherCtx, stopWeather := timing.Start(ctx, timing.PhaseWeather)
+	stepWeatherInfo, err := s.weatherRepository.GetWeatherInfo(weatherCtx, c)
+	stopWeather()
 
 	if err != nil {
-		return nil, ErrCouldNotFetchWeather
+		return nil, wrapUpstreamError(err, ErrCouldNotFetchWeather, ErrWeatherNotFound)
 	}
 
-	if stepWeatherInfo.Fahrenheit == 0 && stepWeatherInfo.Celcius == 0 {
+	if !stepWeatherInfo.Valid {
 		return nil, ErrWeatherNotFound
 	}
 
-	kelvin := stepWeatherInfo.Celcius + 273.15
-
+	// Celsius is the canonical temperature, the other scales are derived from it so they always agree
 	return &entity.WeatherInfo{
-		Fahrenheit: stepWeatherInfo.Fahrenheit,
+		Fahrenheit: units.CelsiusToFahrenheit(stepWeatherInfo.Celcius),
 		Celcius:    stepWeatherInfo.Celcius,
-		Kelvin:     kelvin,
+		Kelvin:     units.CelsiusToKelvin(stepWeatherInfo.Celcius),
+		WindKph:    stepWeatherInfo.WindKph,
+		PressureMb: stepWeatherInfo.PressureMb,
+		PrecipMm:   stepWeatherInfo.PrecipMm,
+		Condition:  stepWeatherInfo.Condition,
+		ObservedAt: stepWeatherInfo.ObservedAt,
+		Valid:      true,
 	}, nil
 }
+
+// MaxForecastDays is the longest forecast WeatherAPI provides, its plans may be limited to fewer days
+const MaxForecastDays = 14
+
+// GetForecastByLocation retrieves the daily forecast of a location resolved by GetLocationByPostalCode, starting today
+func (s *WeatherUseCases) GetForecastByLocation(ctx context.Context, c *entity.CEP, days int) (_ []entity.ForecastDay, err error) {
+	ctx, done := startOperation(ctx, "forecast", "WeatherUseCases.GetForecastByLocation")
+	defer func() { done(err) }()
+
+	if s.forecastRepository == nil {
+		return nil, ErrWeatherNotFound
+	}
+
+	forecastCtx, stopForecast := timing.Start(ctx, timing.PhaseWeather)
+	forecast, err := s.forecastRepository.GetForecast(forecastCtx, c, days)
+	stopForecast()
+
+	if err != nil {
+		return nil, wrapUpstreamError(err, ErrCouldNotFetchWeather, ErrWeatherNotFound)
+	}
+	if len(forecast) == 0 {
+		return nil, ErrWeatherNotFound
+	}
+	return forecast, nil
+}
+
+// startOperation records the metrics, the span and the log of a use case operation, the returned function ends them with the error of the operation
+func startOperation(ctx context.Context, operation, spanName string, attrs ...attribute.KeyValue) (context.Context, func(err error)) {
+	start := time.Now()
+	done := metrics.StartUseCase(operation)
+	ctx, end := tracing.Start(ctx, spanName, attrs...)
+
+	return ctx, func(err error) {
+		done(err)
+		logger := logging.FromContext(ctx).With("operation", operation, "latency_ms", logging.Milliseconds(time.Since(start)))
+		if err != nil {
+			end(err, tracing.KeyProblemCode.String(string(problem.CodeOf(err))))
+			logger.Debug("Use case failed", "code", problem.CodeOf(err))
+			return
+		}
+		end(nil)
+		logger.Debug("Use case completed")
+	}
+}
+
+// wrapUpstreamError classifies a repository failure into a use case error, keeping the original error in the chain.
+// notFound is used when the provider does not know the resource and fallback when the failure has no known kind.
+func wrapUpstreamError(err, fallback, notFound error) error {
+	var useCaseErr error
+	switch {
+	case errors.Is(err, entity.ErrUpstreamNotFound):
+		useCaseErr = notFound
+	case errors.Is(err, entity.ErrUpstreamTimeout), errors.Is(err, context.DeadlineExceeded):
+		useCaseErr = ErrUpstreamTimeout
+	case errors.Is(err, entity.ErrUpstreamRateLimited):
+		useCaseErr = ErrUpstreamRateLimited
+	case errors.Is(err, entity.ErrUpstreamUnavailable):
+		useCaseErr = ErrUpstreamUnavailable
+	case errors.Is(err, entity.ErrUpstreamBadPayload):
+		useCaseErr = ErrUpstreamBadPayload
+	default:
+		useCaseErr = fallback
+	}
+	return fmt.Errorf("%w: %w", useCaseErr, err)
+}
@@ -3,10 +3,13 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 // MockCEPRepository is a mock implementation of the CEPRepository interface.
@@ -29,6 +32,16 @@ func (m *MockWeatherRepository) GetWeatherInfo(ctx context.Context, cep *entity.
 	return args.Get(0).(*entity.WeatherInfo), args.Error(1)
 }
 
+// MockForecastRepository is a mock implementation of the ForecastRepository interface.
+type MockForecastRepository struct {
+	mock.Mock
+}
+
+func (m *MockForecastRepository) GetForecast(ctx context.Context, cep *entity.CEP, days int) ([]entity.ForecastDay, error) {
+	args := m.Called(ctx, cep, days)
+	return args.Get(0).([]entity.ForecastDay), args.Error(1)
+}
+
 type testRow struct {
 	name                          string
 	cep                           string
@@ -52,12 +65,14 @@ func TestGetWeatherByCEP(t *testing.T) {
 			name:          "Valid CEP and Weather Info",
 			cep:           "12345678",
 			mockCEP:       &entity.CEP{Localidade: "São Paulo"},
-			mockWeather:   &entity.WeatherInfo{Fahrenheit: 77.0, Celcius: 25.0},
+			mockWeather:   &entity.WeatherInfo{Fahrenheit: 77.0, Celcius: 25.0, ObservedAt: time.Unix(1768478400, 0), Valid: true},
 			expectedError: nil,
 			expectedResult: &entity.WeatherInfo{
 				Fahrenheit: 77.0,
 				Celcius:    25.0,
 				Kelvin:     298.15,
+				ObservedAt: time.Unix(1768478400, 0),
+				Valid:      true,
 			},
 			mockWeatherRepoShouldBeCalled: true,
 			mockCEPRepoShouldBeCalled:     true,
@@ -74,11 +89,26 @@ func TestGetWeatherByCEP(t *testing.T) {
 			expectedError:             ErrCEPNotFound,
 			mockCEPRepoShouldBeCalled: true,
 		},
+		{
+			name:          "Genuine 0 °C reading",
+			cep:           "88650000",
+			mockCEP:       &entity.CEP{Localidade: "Urubici"},
+			mockWeather:   &entity.WeatherInfo{Fahrenheit: 32.0, Celcius: 0, Valid: true},
+			expectedError: nil,
+			expectedResult: &entity.WeatherInfo{
+				Fahrenheit: 32.0,
+				Celcius:    0,
+				Kelvin:     273.15,
+				Valid:      true,
+			},
+			mockWeatherRepoShouldBeCalled: true,
+			mockCEPRepoShouldBeCalled:     true,
+		},
 		{
 			name:                          "Weather Info Not Found",
 			cep:                           "12345678",
 			mockCEP:                       &entity.CEP{Localidade: "São Paulo"},
-			mockWeather:                   &entity.WeatherInfo{Fahrenheit: 0, Celcius: 0},
+			mockWeather:                   &entity.WeatherInfo{},
 			expectedError:                 ErrWeatherNotFound,
 			mockWeatherRepoShouldBeCalled: true,
 			mockCEPRepoShouldBeCalled:     true,
@@ -90,6 +120,45 @@ func TestGetWeatherByCEP(t *testing.T) {
 			expectedError:             ErrCouldNotFetchCEP,
 			mockCEPRepoShouldBeCalled: true,
 		},
+		{
+			name:                      "Timeout Fetching CEP",
+			cep:                       "12345678",
+			mockCEPError:              fmt.Errorf("failed to execute request: %w", context.DeadlineExceeded),
+			expectedError:             ErrUpstreamTimeout,
+			mockCEPRepoShouldBeCalled: true,
+		},
+		{
+			name:                      "CEP Not Found Upstream",
+			cep:                       "99999999",
+			mockCEPError:              &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")},
+			expectedError:             ErrCEPNotFound,
+			mockCEPRepoShouldBeCalled: true,
+		},
+		{
+			name:                      "CEP Provider Unavailable",
+			cep:                       "12345678",
+			mockCEPError:              &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamUnavailable, StatusCode: 503, Err: errors.New("received status code 503")},
+			expectedError:             ErrUpstreamUnavailable,
+			mockCEPRepoShouldBeCalled: true,
+		},
+		{
+			name:                          "Weather Provider Rate Limited",
+			cep:                           "12345678",
+			mockCEP:                       &entity.CEP{Localidade: "São Paulo"},
+			mockWeatherErr:                &entity.UpstreamError{Provider: "weatherapi", Kind: entity.ErrUpstreamRateLimited, StatusCode: 403, Err: errors.New("quota exceeded")},
+			expectedError:                 ErrUpstreamRateLimited,
+			mockWeatherRepoShouldBeCalled: true,
+			mockCEPRepoShouldBeCalled:     true,
+		},
+		{
+			name:                          "Weather Provider Bad Payload",
+			cep:                           "12345678",
+			mockCEP:                       &entity.CEP{Localidade: "São Paulo"},
+			mockWeatherErr:                &entity.UpstreamError{Provider: "weatherapi", Kind: entity.ErrUpstreamBadPayload, Err: errors.New("missing temperature")},
+			expectedError:                 ErrUpstreamBadPayload,
+			mockWeatherRepoShouldBeCalled: true,
+			mockCEPRepoShouldBeCalled:     true,
+		},
 		{
 			name:                          "Error Fetching Weather Info",
 			cep:                           "12345678",
@@ -117,7 +186,7 @@ func TestGetWeatherByCEP(t *testing.T) {
 			result, err := useCases.GetWeatherByCEP(context.Background(), tr.cep)
 
 			// Assert results
-			assert.Equal(t, tr.expectedError, err)
+			assert.ErrorIs(t, err, tr.expectedError)
 			assert.Equal(t, tr.expectedResult, result)
 
 			// Assert expectations
@@ -131,3 +200,163 @@ func TestGetWeatherByCEP(t *testing.T) {
 		})
 	}
 }
+
+func TestGetWeatherByPostalCode(t *testing.T) {
+	mockCEPRepo := new(MockCEPRepository)
+	mockPostalCodeRepo := new(MockCEPRepository)
+	mockWeatherRepo := new(MockWeatherRepository)
+	useCases := NewWeatherUseCases(mockCEPRepo, mockWeatherRepo)
+	useCases.RegisterPostalCodeRepository("pt", mockPostalCodeRepo)
+
+	t.Run("Valid postal code", func(t *testing.T) {
+		location := &entity.CEP{Localidade: "Lisboa", Country: "PT"}
+		mockPostalCodeRepo.On("GetCEP", mock.Anything, "1000-001").Return(location, nil).Once()
+		mockWeatherRepo.On("GetWeatherInfo", mock.Anything, location).Return(&entity.WeatherInfo{Celcius: 20, Fahrenheit: 68, Valid: true}, nil).Once()
+
+		result, err := useCases.GetWeatherByPostalCode(context.Background(), "PT", "1000-001")
+
+		assert.NoError(t, err)
+		assert.Equal(t, &entity.WeatherInfo{Celcius: 20, Fahrenheit: 68, Kelvin: 293.15, Valid: true}, result)
+		mockPostalCodeRepo.AssertExpectations(t)
+		mockWeatherRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid postal code", func(t *testing.T) {
+		result, err := useCases.GetWeatherByPostalCode(context.Background(), "PT", "1000")
+		assert.Equal(t, ErrInvalidPostalCode, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Postal code not found", func(t *testing.T) {
+		mockPostalCodeRepo.On("GetCEP", mock.Anything, "9999-999").Return(&entity.CEP{Country: "PT"}, nil).Once()
+
+		result, err := useCases.GetWeatherByPostalCode(context.Background(), "pt", "9999-999")
+
+		assert.Equal(t, ErrPostalCodeNotFound, err)
+		assert.Nil(t, result)
+		mockPostalCodeRepo.AssertExpectations(t)
+	})
+
+	t.Run("Country without provider", func(t *testing.T) {
+		result, err := useCases.GetWeatherByPostalCode(context.Background(), "AR", "1601")
+		assert.Equal(t, ErrUnsupportedCountry, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Brazilian CEP keeps CEP errors", func(t *testing.T) {
+		result, err := useCases.GetWeatherByPostalCode(context.Background(), "BR", "1234")
+		assert.Equal(t, ErrInvalidCEP, err)
+		assert.Nil(t, result)
+	})
+}
+
+func TestGetWeatherByCEP_KeepsRootCause(t *testing.T) {
+	mockCEPRepo := new(MockCEPRepository)
+	mockWeatherRepo := new(MockWeatherRepository)
+	useCases := NewWeatherUseCases(mockCEPRepo, mockWeatherRepo)
+
+	rootCause := errors.New("connection refused")
+	upstreamErr := &entity.UpstreamError{Provider: "viacep", Kind: entity.ErrUpstreamUnavailable, Err: rootCause}
+	mockCEPRepo.On("GetCEP", mock.Anything, "12345678").Return((*entity.CEP)(nil), upstreamErr).Once()
+
+	result, err := useCases.GetWeatherByCEP(context.Background(), "12345678")
+
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+	assert.ErrorIs(t, err, rootCause)
+	assert.Equal(t, problem.CodeUpstreamDown, problem.CodeOf(err))
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestGetWeatherReportByCEP(t *testing.T) {
+	location := &entity.CEP{Localidade: "São Paulo", Uf: "SP"}
+	weatherErr := &entity.UpstreamError{Provider: "weatherapi", Kind: entity.ErrUpstreamUnavailable, Err: errors.New("received status code 503")}
+
+	t.Run("Partial report keeps the location", func(t *testing.T) {
+		mockCEPRepo := new(MockCEPRepository)
+		mockWeatherRepo := new(MockWeatherRepository)
+		useCases := NewWeatherUseCases(mockCEPRepo, mockWeatherRepo)
+		mockCEPRepo.On("GetCEP", mock.Anything, "12345678").Return(location, nil).Once()
+		mockWeatherRepo.On("GetWeatherInfo", mock.Anything, location).Return((*entity.WeatherInfo)(nil), weatherErr).Once()
+
+		report, err := useCases.GetWeatherReportByCEP(context.Background(), "12345678", true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, location, report.Location)
+		assert.Nil(t, report.Weather)
+		assert.ErrorIs(t, report.WeatherErr, ErrUpstreamUnavailable)
+	})
+
+	t.Run("Strict report fails", func(t *testing.T) {
+		mockCEPRepo := new(MockCEPRepository)
+		mockWeatherRepo := new(MockWeatherRepository)
+		useCases := NewWeatherUseCases(mockCEPRepo, mockWeatherRepo)
+		mockCEPRepo.On("GetCEP", mock.Anything, "12345678").Return(location, nil).Once()
+		mockWeatherRepo.On("GetWeatherInfo", mock.Anything, location).Return((*entity.WeatherInfo)(nil), weatherErr).Once()
+
+		report, err := useCases.GetWeatherReportByCEP(context.Background(), "12345678", false)
+
+		assert.ErrorIs(t, err, ErrUpstreamUnavailable)
+		assert.Nil(t, report)
+	})
+
+	t.Run("Location failures are never partial", func(t *testing.T) {
+		mockCEPRepo := new(MockCEPRepository)
+		mockWeatherRepo := new(MockWeatherRepository)
+		useCases := NewWeatherUseCases(mockCEPRepo, mockWeatherRepo)
+		mockCEPRepo.On("GetCEP", mock.Anything, "12345678").Return(&entity.CEP{}, nil).Once()
+
+		report, err := useCases.GetWeatherReportByCEP(context.Background(), "12345678", true)
+
+		assert.ErrorIs(t, err, ErrCEPNotFound)
+		assert.Nil(t, report)
+	})
+}
+
+func TestGetForecastByLocation(t *testing.T) {
+	location := &entity.CEP{Localidade: "São Paulo", Uf: "SP"}
+	forecast := []entity.ForecastDay{{Date: "2026-01-15", MaxTempC: 31, MinTempC: 22, AvgTempC: 26, Condition: "Sunny"}}
+
+	t.Run("Forecast of the location", func(t *testing.T) {
+		mockForecastRepo := new(MockForecastRepository)
+		useCases := NewWeatherUseCases(new(MockCEPRepository), new(MockWeatherRepository))
+		useCases.SetForecastRepository(mockForecastRepo)
+		mockForecastRepo.On("GetForecast", mock.Anything, location, 3).Return(forecast, nil).Once()
+
+		result, err := useCases.GetForecastByLocation(context.Background(), location, 3)
+
+		assert.NoError(t, err)
+		assert.Equal(t, forecast, result)
+	})
+
+	t.Run("Upstream failures are classified", func(t *testing.T) {
+		mockForecastRepo := new(MockForecastRepository)
+		useCases := NewWeatherUseCases(new(MockCEPRepository), new(MockWeatherRepository))
+		useCases.SetForecastRepository(mockForecastRepo)
+		upstreamErr := &entity.UpstreamError{Provider: "weatherapi", Kind: entity.ErrUpstreamRateLimited, Err: errors.New("quota exceeded")}
+		mockForecastRepo.On("GetForecast", mock.Anything, location, 3).Return([]entity.ForecastDay(nil), upstreamErr).Once()
+
+		_, err := useCases.GetForecastByLocation(context.Background(), location, 3)
+
+		assert.ErrorIs(t, err, ErrUpstreamRateLimited)
+	})
+
+	t.Run("Empty forecast is not found", func(t *testing.T) {
+		mockForecastRepo := new(MockForecastRepository)
+		useCases := NewWeatherUseCases(new(MockCEPRepository), new(MockWeatherRepository))
+		useCases.SetForecastRepository(mockForecastRepo)
+		mockForecastRepo.On("GetForecast", mock.Anything, location, 3).Return([]entity.ForecastDay{}, nil).Once()
+
+		_, err := useCases.GetForecastByLocation(context.Background(), location, 3)
+
+		assert.ErrorIs(t, err, ErrWeatherNotFound)
+	})
+
+	t.Run("Without provider", func(t *testing.T) {
+		useCases := NewWeatherUseCases(new(MockCEPRepository), new(MockWeatherRepository))
+
+		_, err := useCases.GetForecastByLocation(context.Background(), location, 3)
+
+		assert.ErrorIs(t, err, ErrWeatherNotFound)
+	})
+}
@@ -0,0 +1,282 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/caricciy/go-weather/internal/problem"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultWebhookMaxAttempts is the number of attempts of a delivery before it becomes a dead letter
+	DefaultWebhookMaxAttempts = 5
+	// DefaultWebhookBackoff is the wait before the first retry, it doubles on every attempt
+	DefaultWebhookBackoff = time.Second
+
+	maxWebhookBackoff = time.Minute
+	// webhookLookupTimeout bounds the weather lookup of a CEP during an evaluation
+	webhookLookupTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidWebhook        error = problem.New(problem.CodeWebhookInvalid, "invalid webhook")
+	ErrWebhookNotFound       error = problem.New(problem.CodeWebhookNotFound, "webhook not found")
+	ErrWebhookDeliveryFailed error = problem.New(problem.CodeWebhookDelivery, "webhook delivery failed")
+)
+
+// webhookMetrics reads the measurements a rule can watch, named like the fields of the weather response.
+// They return nil when the provider did not report the measurement.
+var webhookMetrics = map[string]func(*entity.WeatherInfo) *float64{
+	"temp_C":      func(w *entity.WeatherInfo) *float64 { return &w.Celcius },
+	"temp_F":      func(w *entity.WeatherInfo) *float64 { return &w.Fahrenheit },
+	"temp_K":      func(w *entity.WeatherInfo) *float64 { return &w.Kelvin },
+	"wind_kph":    func(w *entity.WeatherInfo) *float64 { return w.WindKph },
+	"pressure_mb": func(w *entity.WeatherInfo) *float64 { return w.PressureMb },
+	"precip_mm":   func(w *entity.WeatherInfo) *float64 { return w.PrecipMm },
+}
+
+var webhookOperators = map[string]func(value, threshold float64) bool{
+	"gt":  func(v, t float64) bool { return v > t },
+	"gte": func(v, t float64) bool { return v >= t },
+	"lt":  func(v, t float64) bool { return v < t },
+	"lte": func(v, t float64) bool { return v <= t },
+}
+
+type WebhookUseCases struct {
+	weatherUseCases *WeatherUseCases
+	repository      entity.WebhookRepository
+	sender          entity.WebhookSender
+	maxAttempts     int
+	backoff         time.Duration
+	// deliveries tracks the deliveries in progress so the evaluator waits for them on shutdown
+	deliveries sync.WaitGroup
+}
+
+func NewWebhookUseCases(weatherUseCases *WeatherUseCases, repository entity.WebhookRepository, sender entity.WebhookSender, maxAttempts int, backoff time.Duration) *WebhookUseCases {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultWebhookBackoff
+	}
+	return &WebhookUseCases{
+		weatherUseCases: weatherUseCases,
+		repository:      repository,
+		sender:          sender,
+		maxAttempts:     maxAttempts,
+		backoff:         backoff,
+	}
+}
+
+// CreateWebhook registers a webhook for an existing CEP. Its secret is generated and only known after the creation.
+func (s *WebhookUseCases) CreateWebhook(ctx context.Context, cep string, rule entity.WebhookRule, callbackURL string) (*entity.Webhook, error) {
+	if err := validateWebhook(rule, callbackURL); err != nil {
+		return nil, err
+	}
+	if _, err := s.weatherUseCases.GetLocationByPostalCode(ctx, DefaultCountry, cep); err != nil {
+		return nil, err
+	}
+
+	webhook := &entity.Webhook{
+		ID:          randomHex(16),
+		CEP:         cep,
+		Rule:        rule,
+		CallbackURL: callbackURL,
+		Secret:      randomHex(32),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repository.CreateWebhook(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (s *WebhookUseCases) ListWebhooks(ctx context.Context) ([]*entity.Webhook, error) {
+	webhooks, err := s.repository.ListWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookUseCases) DeleteWebhook(ctx context.Context, id string) error {
+	return webhookRepositoryError(s.repository.DeleteWebhook(ctx, id))
+}
+
+// TestWebhook makes a single test delivery to the callback of a webhook
+func (s *WebhookUseCases) TestWebhook(ctx context.Context, id string) error {
+	webhook, err := s.repository.GetWebhook(ctx, id)
+	if err != nil {
+		return webhookRepositoryError(err)
+	}
+
+	delivery := &entity.WebhookDelivery{ID: randomHex(16), Event: entity.WebhookEventTest, Webhook: webhook, Time: time.Now()}
+	if err := s.sender.Send(ctx, delivery); err != nil {
+		return fmt.Errorf("%w: %w", ErrWebhookDeliveryFailed, err)
+	}
+	return nil
+}
+
+// EvaluateWebhooks checks the rules of every webhook against the current weather of its CEP.
+// A delivery is started in the background when a rule starts matching. The weather of a CEP is looked up once.
+func (s *WebhookUseCases) EvaluateWebhooks(ctx context.Context) error {
+	webhooks, err := s.repository.ListWebhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	weatherByCEP := make(map[string]*entity.WeatherInfo)
+	for _, webhook := range webhooks {
+		weather, ok := weatherByCEP[webhook.CEP]
+		if !ok {
+			lookupCtx, cancel := context.WithTimeout(ctx, webhookLookupTimeout)
+			weather, err = s.weatherUseCases.GetWeatherByCEP(lookupCtx, webhook.CEP)
+			cancel()
+			if err != nil {
+				slog.Warn("Could not evaluate the webhooks of a CEP", "code", problem.CodeOf(err), "error", err)
+			}
+			weatherByCEP[webhook.CEP] = weather
+		}
+		if weather == nil {
+			continue
+		}
+
+		metric, operator := webhookMetrics[webhook.Rule.Metric], webhookOperators[webhook.Rule.Operator]
+		if metric == nil || operator == nil {
+			slog.Warn("Skipping webhook with an invalid rule", "webhook_id", webhook.ID)
+			continue
+		}
+		value := metric(weather)
+		if value == nil {
+			// A missing reading tells nothing about the rule, the webhook keeps its state until the next one
+			continue
+		}
+		firing := operator(*value, webhook.Rule.Threshold)
+		if firing == webhook.Firing {
+			continue
+		}
+
+		if err := s.repository.SetWebhookFiring(ctx, webhook.ID, firing); err != nil {
+			if !errors.Is(err, entity.ErrWebhookNotFound) {
+				slog.Error("Could not update the state of a webhook", "webhook_id", webhook.ID, "error", err)
+			}
+			continue
+		}
+		if firing {
+			s.deliveries.Add(1)
+			go s.deliver(ctx, &entity.WebhookDelivery{
+				ID:      randomHex(16),
+				Event:   entity.WebhookEventThresholdCrossed,
+				Webhook: webhook,
+				Value:   *value,
+				Weather: weather,
+				Time:    time.Now(),
+			})
+		}
+	}
+	return nil
+}
+
+// RunWebhookEvaluator evaluates the webhooks every interval until ctx is done, then waits for the deliveries in progress
+func (s *WebhookUseCases) RunWebhookEvaluator(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.deliveries.Wait()
+			return
+		case <-ticker.C:
+			if err := s.EvaluateWebhooks(ctx); err != nil {
+				slog.Error("Webhook evaluation failed", "error", err)
+			}
+		}
+	}
+}
+
+// deliver sends a delivery, retrying with an exponential backoff.
+// A delivery that is rejected, runs out of attempts or is interrupted by the shutdown becomes a dead letter.
+func (s *WebhookUseCases) deliver(ctx context.Context, delivery *entity.WebhookDelivery) {
+	defer s.deliveries.Done()
+
+	attempts := 0
+	var err error
+	for attempts < s.maxAttempts {
+		attempts++
+		if err = s.sender.Send(ctx, delivery); err == nil {
+			return
+		}
+		if errors.Is(err, entity.ErrWebhookRejected) || attempts == s.maxAttempts || !sleep(ctx, s.retryDelay(attempts)) {
+			break
+		}
+	}
+
+	slog.Warn("Webhook delivery failed", "webhook_id", delivery.Webhook.ID, "delivery_id", delivery.ID, "attempts", attempts, "error", err)
+	deadLetter := &entity.DeadLetter{Delivery: delivery, Attempts: attempts, LastError: err.Error(), FailedAt: time.Now()}
+	if err := s.repository.AddDeadLetter(context.WithoutCancel(ctx), deadLetter); err != nil {
+		slog.Error("Could not record a dead letter", "webhook_id", delivery.Webhook.ID, "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// retryDelay is the wait after a failed attempt, the backoff doubled for each previous attempt with jitter
+func (s *WebhookUseCases) retryDelay(attempt int) time.Duration {
+	delay := s.backoff << (attempt - 1)
+	if delay <= 0 || delay > maxWebhookBackoff {
+		delay = maxWebhookBackoff
+	}
+	return delay/2 + mathrand.N(delay/2+1)
+}
+
+// sleep waits for d, it returns false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func validateWebhook(rule entity.WebhookRule, callbackURL string) error {
+	if _, ok := webhookMetrics[rule.Metric]; !ok {
+		return fmt.Errorf("%w: unknown metric %q", ErrInvalidWebhook, rule.Metric)
+	}
+	if _, ok := webhookOperators[rule.Operator]; !ok {
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidWebhook, rule.Operator)
+	}
+
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: callback must be an absolute http(s) URL", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+func webhookRepositoryError(err error) error {
+	if errors.Is(err, entity.ErrWebhookNotFound) {
+		return fmt.Errorf("%w: %w", ErrWebhookNotFound, err)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook repository failed: %w", err)
+	}
+	return nil
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
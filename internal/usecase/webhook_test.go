@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"github.com/caricciy/go-weather/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MockWebhookSender is a mock implementation of the WebhookSender interface.
+type MockWebhookSender struct {
+	mock.Mock
+}
+
+func (m *MockWebhookSender) Send(ctx context.Context, delivery *entity.WebhookDelivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+// memoryWebhookRepository keeps the webhooks and dead letters in memory
+type memoryWebhookRepository struct {
+	mu          sync.Mutex
+	webhooks    map[string]entity.Webhook
+	deadLetters []*entity.DeadLetter
+}
+
+func newMemoryWebhookRepository() *memoryWebhookRepository {
+	return &memoryWebhookRepository{webhooks: make(map[string]entity.Webhook)}
+}
+
+func (r *memoryWebhookRepository) CreateWebhook(_ context.Context, webhook *entity.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *memoryWebhookRepository) GetWebhook(_ context.Context, id string) (*entity.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, entity.ErrWebhookNotFound
+	}
+	return &webhook, nil
+}
+
+func (r *memoryWebhookRepository) ListWebhooks(_ context.Context) ([]*entity.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var webhooks []*entity.Webhook
+	for _, webhook := range r.webhooks {
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) DeleteWebhook(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.webhooks[id]; !ok {
+		return entity.ErrWebhookNotFound
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *memoryWebhookRepository) SetWebhookFiring(_ context.Context, id string, firing bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return entity.ErrWebhookNotFound
+	}
+	webhook.Firing = firing
+	r.webhooks[id] = webhook
+	return nil
+}
+
+func (r *memoryWebhookRepository) AddDeadLetter(_ context.Context, deadLetter *entity.DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deadLetters = append(r.deadLetters, deadLetter)
+	return nil
+}
+
+func newTestWebhookUseCases(celcius float64, sender entity.WebhookSender) (*WebhookUseCases, *memoryWebhookRepository) {
+	mockCEPRepo := new(MockCEPRepository)
+	mockCEPRepo.On("GetCEP", mock.Anything, "20270150").Return(&entity.CEP{Localidade: "Rio de Janeiro", Uf: "RJ"}, nil)
+	mockCEPRepo.On("GetCEP", mock.Anything, "99999999").Return((*entity.CEP)(nil), &entity.UpstreamError{Provider: "fake", Kind: entity.ErrUpstreamNotFound, Err: errors.New("cep not found")})
+	mockWeatherRepo := new(MockWeatherRepository)
+	mockWeatherRepo.On("GetWeatherInfo", mock.Anything, mock.Anything).Return(&entity.WeatherInfo{Celcius: celcius, Valid: true}, nil)
+
+	repository := newMemoryWebhookRepository()
+	useCases := NewWebhookUseCases(NewWeatherUseCases(mockCEPRepo, mockWeatherRepo), repository, sender, 3, time.Millisecond)
+	return useCases, repository
+}
+
+func TestCreateWebhook(t *testing.T) {
+	useCases, repository := newTestWebhookUseCases(25, new(MockWebhookSender))
+	aboveHot := entity.WebhookRule{Metric: "temp_C", Operator: "gt", Threshold: 35}
+
+	testTable := []struct {
+		name          string
+		cep           string
+		rule          entity.WebhookRule
+		callbackURL   string
+		expectedError error
+	}{
+		{name: "Valid webhook", cep: "20270150", rule: aboveHot, callbackURL: "https://example.com/hook"},
+		{name: "Unknown metric", cep: "20270150", rule: entity.WebhookRule{Metric: "humidity", Operator: "gt"}, callbackURL: "https://example.com/hook", expectedError: ErrInvalidWebhook},
+		{name: "Unknown operator", cep: "20270150", rule: entity.WebhookRule{Metric: "temp_C", Operator: "above"}, callbackURL: "https://example.com/hook", expectedError: ErrInvalidWebhook},
+		{name: "Relative callback", cep: "20270150", rule: aboveHot, callbackURL: "/hook", expectedError: ErrInvalidWebhook},
+		{name: "Callback scheme", cep: "20270150", rule: aboveHot, callbackURL: "ftp://example.com/hook", expectedError: ErrInvalidWebhook},
+		{name: "Invalid CEP", cep: "123", rule: aboveHot, callbackURL: "https://example.com/hook", expectedError: ErrInvalidCEP},
+		{name: "Unknown CEP", cep: "99999999", rule: aboveHot, callbackURL: "https://example.com/hook", expectedError: ErrCEPNotFound},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			webhook, err := useCases.CreateWebhook(context.Background(), tr.cep, tr.rule, tr.callbackURL)
+			if tr.expectedError != nil {
+				assert.ErrorIs(t, err, tr.expectedError)
+				assert.Nil(t, webhook)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, webhook.ID, 32)
+			assert.Len(t, webhook.Secret, 64)
+			stored, err := repository.GetWebhook(context.Background(), webhook.ID)
+			require.NoError(t, err)
+			assert.Equal(t, webhook.Secret, stored.Secret)
+		})
+	}
+}
+
+func TestEvaluateWebhooksDeliversWhenTheRuleStartsMatching(t *testing.T) {
+	sender := new(MockWebhookSender)
+	sender.On("Send", mock.Anything, mock.Anything).Return(nil)
+	useCases, repository := newTestWebhookUseCases(36.5, sender)
+	ctx := context.Background()
+
+	hot, err := useCases.CreateWebhook(ctx, "20270150", entity.WebhookRule{Metric: "temp_C", Operator: "gt", Threshold: 35}, "https://example.com/hot")
+	require.NoError(t, err)
+	_, err = useCases.CreateWebhook(ctx, "20270150", entity.WebhookRule{Metric: "temp_C", Operator: "lt", Threshold: 5}, "https://example.com/cold")
+	require.NoError(t, err)
+
+	require.NoError(t, useCases.EvaluateWebhooks(ctx))
+	useCases.deliveries.Wait()
+
+	sender.AssertNumberOfCalls(t, "Send", 1)
+	delivery := sender.Calls[0].Arguments.Get(1).(*entity.WebhookDelivery)
+	assert.Equal(t, hot.ID, delivery.Webhook.ID)
+	assert.Equal(t, entity.WebhookEventThresholdCrossed, delivery.Event)
+	assert.Equal(t, 36.5, delivery.Value)
+	stored, _ := repository.GetWebhook(ctx, hot.ID)
+	assert.True(t, stored.Firing)
+
+	// The rule still matches, nothing new to tell
+	require.NoError(t, useCases.EvaluateWebhooks(ctx))
+	useCases.deliveries.Wait()
+	sender.AssertNumberOfCalls(t, "Send", 1)
+	assert.Empty(t, repository.deadLetters)
+}
+
+func TestEvaluateWebhooksIgnoresMissingReadings(t *testing.T) {
+	sender := new(MockWebhookSender)
+	useCases, repository := newTestWebhookUseCases(25, sender)
+	ctx := context.Background()
+
+	// The weather has no wind reading, which must not be taken for calm air
+	calm, err := useCases.CreateWebhook(ctx, "20270150", entity.WebhookRule{Metric: "wind_kph", Operator: "lt", Threshold: 5}, "https://example.com/calm")
+	require.NoError(t, err)
+
+	require.NoError(t, useCases.EvaluateWebhooks(ctx))
+	useCases.deliveries.Wait()
+
+	sender.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	stored, _ := repository.GetWebhook(ctx, calm.ID)
+	assert.False(t, stored.Firing)
+}
+
+func TestWebhookDeliveryRetriesThenDeadLetters(t *testing.T) {
+	testTable := []struct {
+		name             string
+		sendErrors       []error
+		expectedAttempts int
+		expectDeadLetter bool
+	}{
+		{name: "Succeeds after a retry", sendErrors: []error{errors.New("503"), nil}, expectedAttempts: 2},
+		{name: "Runs out of attempts", sendErrors: []error{errors.New("503"), errors.New("503"), errors.New("503")}, expectedAttempts: 3, expectDeadLetter: true},
+		{name: "Rejected", sendErrors: []error{entity.ErrWebhookRejected}, expectedAttempts: 1, expectDeadLetter: true},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			sender := new(MockWebhookSender)
+			for _, err := range tr.sendErrors {
+				sender.On("Send", mock.Anything, mock.Anything).Return(err).Once()
+			}
+			useCases, repository := newTestWebhookUseCases(36.5, sender)
+
+			_, err := useCases.CreateWebhook(context.Background(), "20270150", entity.WebhookRule{Metric: "temp_C", Operator: "gte", Threshold: 35}, "https://example.com/hot")
+			require.NoError(t, err)
+			require.NoError(t, useCases.EvaluateWebhooks(context.Background()))
+			useCases.deliveries.Wait()
+
+			sender.AssertNumberOfCalls(t, "Send", tr.expectedAttempts)
+			if !tr.expectDeadLetter {
+				assert.Empty(t, repository.deadLetters)
+				return
+			}
+			require.Len(t, repository.deadLetters, 1)
+			assert.Equal(t, tr.expectedAttempts, repository.deadLetters[0].Attempts)
+			assert.NotEmpty(t, repository.deadLetters[0].LastError)
+		})
+	}
+}
+
+func TestTestAndDeleteWebhook(t *testing.T) {
+	sender := new(MockWebhookSender)
+	sender.On("Send", mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+	sender.On("Send", mock.Anything, mock.Anything).Return(nil).Once()
+	useCases, _ := newTestWebhookUseCases(25, sender)
+	ctx := context.Background()
+
+	webhook, err := useCases.CreateWebhook(ctx, "20270150", entity.WebhookRule{Metric: "temp_C", Operator: "gt", Threshold: 35}, "https://example.com/hot")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, useCases.TestWebhook(ctx, webhook.ID), ErrWebhookDeliveryFailed)
+	assert.NoError(t, useCases.TestWebhook(ctx, webhook.ID))
+	assert.Equal(t, entity.WebhookEventTest, sender.Calls[1].Arguments.Get(1).(*entity.WebhookDelivery).Event)
+
+	assert.NoError(t, useCases.DeleteWebhook(ctx, webhook.ID))
+	assert.ErrorIs(t, useCases.DeleteWebhook(ctx, webhook.ID), ErrWebhookNotFound)
+	assert.ErrorIs(t, useCases.TestWebhook(ctx, webhook.ID), ErrWebhookNotFound)
+}
@@ -7,7 +7,16 @@ import (
 )
 
 func SendJSON(w http.ResponseWriter, resp any, status int) {
-	w.Header().Set("Content-Type", "application/json")
+	sendJSON(w, resp, status, "application/json")
+}
+
+// SendProblemJSON sends an RFC 7807 problem details response
+func SendProblemJSON(w http.ResponseWriter, resp any, status int) {
+	sendJSON(w, resp, status, "application/problem+json")
+}
+
+func sendJSON(w http.ResponseWriter, resp any, status int, contentType string) {
+	w.Header().Set("Content-Type", contentType)
 	data, err := json.Marshal(resp)
 	if err != nil {
 		slog.Error("Failed to marshal response", "error", err)
@@ -51,6 +51,15 @@ func TestSendJSON(t *testing.T) {
 	}
 }
 
+func TestSendProblemJSON(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	SendProblemJSON(recorder, map[string]any{"status": 404}, http.StatusNotFound)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"status":404}`, recorder.Body.String())
+}
+
 // failingResponseWriter is only overriding the Write method of the http.ResponseWriter interface.
 // When we create an instance of failingResponseWriter and embed a httptest.NewRecorder()
 // as the ResponseWriter, the other methods of the interface, such as Header() and WriteHeader(),
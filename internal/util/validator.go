@@ -1,9 +1,33 @@
 package util
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
+
+// postalCodeRules holds the postal code format accepted for each supported country (ISO 3166-1 alpha-2)
+var postalCodeRules = map[string]*regexp.Regexp{
+	"BR": regexp.MustCompile(`^[0-9]{8}$`),
+	"PT": regexp.MustCompile(`^[0-9]{4}-[0-9]{3}$`),
+	"AR": regexp.MustCompile(`^[0-9]{4}$`),
+}
 
 func CheckCEPIsValid(cep string) bool {
-	//there is no way to have a error in this function, so we can ignore the error
-	check, _ := regexp.MatchString(`^[0-9]{8}$`, cep)
-	return check
+	return CheckPostalCodeIsValid("BR", cep)
+}
+
+// CheckPostalCodeIsValid checks the postal code against the format of the given country.
+// Unknown countries are always invalid.
+func CheckPostalCodeIsValid(country, postalCode string) bool {
+	rule, ok := postalCodeRules[strings.ToUpper(country)]
+	if !ok {
+		return false
+	}
+	return rule.MatchString(postalCode)
+}
+
+// IsSupportedCountry reports whether there is a postal code rule for the given country
+func IsSupportedCountry(country string) bool {
+	_, ok := postalCodeRules[strings.ToUpper(country)]
+	return ok
 }
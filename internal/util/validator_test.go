@@ -28,3 +28,31 @@ func TestCheckCEPIsValid(t *testing.T) {
 		})
 	}
 }
+
+type postalCodeTestRow struct {
+	name       string
+	country    string
+	postalCode string
+	expected   bool
+}
+
+func TestCheckPostalCodeIsValid(t *testing.T) {
+	testTable := []postalCodeTestRow{
+		{"Valid BR CEP", "BR", "12345678", true},
+		{"Valid BR CEP - lowercase country", "br", "12345678", true},
+		{"Invalid BR CEP - with hyphen", "BR", "12345-678", false},
+		{"Valid PT postal code", "PT", "1000-001", true},
+		{"Invalid PT postal code - missing suffix", "PT", "1000", false},
+		{"Valid AR postal code", "AR", "1601", true},
+		{"Invalid AR postal code - too long", "AR", "16011", false},
+		{"Unsupported country", "US", "90210", false},
+		{"Empty country", "", "12345678", false},
+	}
+
+	for _, tr := range testTable {
+		t.Run(tr.name, func(t *testing.T) {
+			result := CheckPostalCodeIsValid(tr.country, tr.postalCode)
+			assert.Equal(t, tr.expected, result, "Expected result for %s/%s to be %v", tr.country, tr.postalCode, tr.expected)
+		})
+	}
+}